@@ -12,7 +12,9 @@ import {
   FormLabelsDocument,
   FormLabelsQuery,
   Label,
-  NewTicket
+  NewTicket,
+  SubmissionChallengeDocument,
+  SubmissionChallengeQuery
 } from "@/lib/graph/generated/graphql";
 import {toast} from "sonner";
 import {LoaderCircle, Send} from "lucide-react";
@@ -22,6 +24,7 @@ import {Button} from "@/components/ui/button";
 import {Textarea} from "@/components/ui/textarea";
 import {Checkbox} from "@/components/ui/checkbox";
 import {defaultLabel} from "@/lib/graph/defaultTypes";
+import {solveChallenge} from "@/lib/proof-of-work";
 
 const TITLE_MAX_LENGTH = 70
 const TEXT_MAX_LENGTH = 3000
@@ -78,13 +81,18 @@ export default function FormUi() {
     setLoading(true);
     const client = getClient();
 
-    const newTicket: NewTicket = {
-      labels: data.labels,
-      originalTitle: data.title.trim(),
-      text: data.text.trim(),
-    };
-
     try {
+      const {submissionChallenge} = await client.request<SubmissionChallengeQuery>(SubmissionChallengeDocument);
+      const solution = await solveChallenge(submissionChallenge.challenge, submissionChallenge.difficulty);
+
+      const newTicket: NewTicket = {
+        labels: data.labels,
+        originalTitle: data.title.trim(),
+        text: data.text.trim(),
+        challenge: submissionChallenge.challenge,
+        challengeSolution: solution,
+      };
+
       await client.request<CreateTicketMutation>(CreateTicketDocument, {ticket: newTicket});
       toast.success("Feedback wurde erfolgreich gesendet");
       setHasTriedToSubmit(false);
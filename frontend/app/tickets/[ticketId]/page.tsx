@@ -33,7 +33,7 @@ export default function TicketPage() {
   const fetchTicketDetail = useCallback(async () => {
     if (!ticketId) return;
     const data = await client.request<TicketsByIdsQuery>(TicketsByIdsDocument, {id: ticketId});
-    const ticketData = data?.tickets?.[0];
+    const ticketData = data?.paginatedTickets.edges[0]?.node;
     setTicket(ticketData ?? null);
     setTicketLabels(ticketData?.labels ?? []);
   }, [ticketId]);
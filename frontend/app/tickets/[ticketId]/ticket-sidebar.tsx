@@ -12,17 +12,12 @@ import {
   BreadcrumbSeparator,
 } from "@/components/ui/breadcrumb";
 import {cn} from "@/lib/utils";
-import React, {useEffect, useState} from "react";
+import React, {useState} from "react";
 import {format} from "date-fns";
 import {useTickets} from "@/components/providers/ticket-provider";
 import {defaultTicketFiltering} from "@/lib/graph/defaultTypes";
-import {
-  getCurrentSemesterTickets,
-  getFilteredTickets,
-  getOlderSemesterTickets,
-  getSortedTickets
-} from "@/lib/ticket-operations";
-import {Ticket, TicketState} from "@/lib/graph/generated/graphql";
+import {getCurrentSemesterTickets, getOlderSemesterTickets} from "@/lib/ticket-operations";
+import {TicketState} from "@/lib/graph/generated/graphql";
 import {Button} from "@/components/ui/button";
 import FilterBar from "@/components/filter-bar";
 import {RotateCcw} from "lucide-react";
@@ -34,25 +29,8 @@ interface TicketSidebarProps {
 export default function TicketSidebar({selectedTicketId}: TicketSidebarProps) {
 
   const router = useRouter();
-  const {tickets, filtering, areFiltersSet, sorting, setFiltering, stateFilterSet} = useTickets()
+  const {tickets, hasMoreTickets, loadMoreTickets, filtering, areFiltersSet, setFiltering} = useTickets()
   const [showFilters, setShowFilters] = useState(false)
-  const [filteredTickets, setFilteredTickets] = useState<Ticket[]>(getFilteredTickets(filtering, tickets))
-  const [sortedTickets, setSortedTickets] = useState<Ticket[]>(getSortedTickets(sorting, filteredTickets))
-
-  useEffect(() => {
-    const newFilteredTickets = getFilteredTickets(filtering, tickets)
-    setFilteredTickets(newFilteredTickets)
-    setSortedTickets(getSortedTickets(sorting, newFilteredTickets))
-  }, [
-    stateFilterSet, filtering.labels.length, filtering.startDate, filtering.endDate, filtering.searchTerm,
-    sorting.field, sorting.orderAscending, filtering, sorting, tickets
-  ])
-
-  useEffect(() => {
-    const newFilteredTickets = getFilteredTickets(filtering, tickets)
-    setFilteredTickets(newFilteredTickets)
-    setSortedTickets(getSortedTickets(sorting, newFilteredTickets))
-  }, [tickets, filtering, sorting])
 
   return (
     <div className="h-[95vh] max-h-[95vh] flex flex-col overflow-hidden">
@@ -129,7 +107,7 @@ export default function TicketSidebar({selectedTicketId}: TicketSidebarProps) {
           <span className={'grow h-0.5 bg-muted-foreground'}/>
         </div>
 
-        {getCurrentSemesterTickets(sortedTickets).map((t) => (
+        {getCurrentSemesterTickets(tickets).map((t) => (
           <div
             key={t.id}
             className={`flex flex-row p-2 cursor-pointer rounded items-center ${
@@ -169,7 +147,7 @@ export default function TicketSidebar({selectedTicketId}: TicketSidebarProps) {
           <span className={'grow h-0.5 bg-muted-foreground'}/>
         </div>
 
-        {getOlderSemesterTickets(sortedTickets).map((t) => (
+        {getOlderSemesterTickets(tickets).map((t) => (
           <div
             key={t.id}
             className={`flex flex-row p-2 cursor-pointer rounded items-center ${
@@ -203,6 +181,11 @@ export default function TicketSidebar({selectedTicketId}: TicketSidebarProps) {
           </div>
         ))}
 
+        {hasMoreTickets && (
+          <Button variant="ghost" className="w-full" onClick={loadMoreTickets} data-cy="ticket-sidebar-load-more">
+            Weitere Tickets laden
+          </Button>
+        )}
       </div>
     </div>
     </div>
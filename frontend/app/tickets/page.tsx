@@ -14,12 +14,7 @@ import {useSidebar} from "@/components/ui/sidebar";
 import {useTickets} from "@/components/providers/ticket-provider";
 import MobileFilterSheet from "@/app/tickets/mobile-filter-sheet";
 import FilterBar from "@/components/filter-bar";
-import {
-  getCurrentSemesterTickets,
-  getFilteredTickets,
-  getOlderSemesterTickets,
-  getSortedTickets
-} from "@/lib/ticket-operations";
+import {getCurrentSemesterTickets, getOlderSemesterTickets} from "@/lib/ticket-operations";
 import {defaultTicketFiltering} from "@/lib/graph/defaultTypes";
 
 
@@ -31,6 +26,9 @@ export type TicketDialogState = {
 export default function TicketPage() {
   const {
     tickets,
+    totalCount,
+    hasMoreTickets,
+    loadMoreTickets,
     filtering,
     areFiltersSet,
     sorting,
@@ -41,8 +39,6 @@ export default function TicketPage() {
   } = useTickets();
   const [dialogState, setDialogState] = useState<TicketDialogState>({mode: null, currentTicket: null});
   const {isMobile} = useSidebar();
-  const [filteredTickets, setFilteredTickets] = useState<(Ticket[])>([]);
-  const [sortedTickets, setSortedTickets] = useState<(Ticket[])>([]);
 
   useEffect(() => {
     triggerTicketRefetch()
@@ -50,28 +46,6 @@ export default function TicketPage() {
     // eslint-disable-next-line react-hooks/exhaustive-deps
   }, []);
 
-  useEffect(() => {
-      const newFilteredTickets = getFilteredTickets(filtering, tickets);
-      setFilteredTickets(newFilteredTickets)
-
-      setSortedTickets(getSortedTickets(sorting, [...newFilteredTickets]))
-    },
-    [
-      tickets,
-      filtering.state.length,
-      filtering.labels.length,
-      filtering.searchTerm,
-      filtering.startDate,
-      filtering.endDate,
-      filtering,
-      sorting
-    ]
-  );
-
-  useEffect(() => {
-    setSortedTickets(getSortedTickets(sorting, [...filteredTickets]))
-  }, [sorting.field, sorting.orderAscending, filteredTickets, sorting]);
-
   useEffect(() => {
     setSorting(prevState => ({
       ...prevState,
@@ -145,7 +119,7 @@ export default function TicketPage() {
         <span className={'grow h-0.5 bg-muted-foreground'}/>
       </div>
 
-      {getCurrentSemesterTickets(sortedTickets).map((ticket) =>
+      {getCurrentSemesterTickets(tickets).map((ticket) =>
           ticket?.id && (
             <div key={ticket.id} className="mx-8 my-4" data-cy={`ticket-card-id-${ticket.id}`}>
               <Link href={`/tickets/${ticket.id}`} passHref>
@@ -160,7 +134,7 @@ export default function TicketPage() {
         <p className={'text-muted-foreground'}>Frühere Semester</p>
         <span className={'grow h-0.5 bg-muted-foreground'}/>
       </div>
-      {getOlderSemesterTickets(sortedTickets).map((ticket) =>
+      {getOlderSemesterTickets(tickets).map((ticket) =>
           ticket?.id && (
             <div key={ticket.id} className="mx-8 my-4" data-cy={`ticket-card-id-${ticket.id}`}>
               <Link href={`/tickets/${ticket.id}`} passHref>
@@ -170,6 +144,14 @@ export default function TicketPage() {
           )
       )}
 
+      {hasMoreTickets && (
+        <div className="mx-8 my-4 flex justify-center">
+          <Button variant="outline" onClick={loadMoreTickets} data-cy="ticket-overview-load-more">
+            Weitere Tickets laden ({tickets.length} von {totalCount})
+          </Button>
+        </div>
+      )}
+
       <ConfirmationDialog
         mode="confirmation"
         description={`Dies wird das Ticket ${dialogState.currentTicket?.title} unwiderruflich löschen`}
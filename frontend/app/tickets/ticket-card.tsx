@@ -2,7 +2,8 @@
 
 import React, {useEffect, useState} from "react";
 import {Card, CardTitle} from "@/components/ui/card";
-import {Label, Ticket, TicketState, UserRole} from "@/lib/graph/generated/graphql";
+import {Label, Ticket, UserRole} from "@/lib/graph/generated/graphql";
+import {TicketState} from "@/lib/ticket-state";
 import {Link, MoreHorizontal, MoreVertical, Trash2} from "lucide-react";
 import {Badge} from "@/components/ui/badge"
 import {DropdownMenu, DropdownMenuContent, DropdownMenuItem, DropdownMenuTrigger,} from "@/components/ui/dropdown-menu";
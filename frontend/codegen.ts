@@ -7,7 +7,13 @@ const config: CodegenConfig = {
   generates: {
     "lib/graph/generated/": {
       preset: "client",
-      plugins: []
+      plugins: [],
+      config: {
+        scalars: {
+          TicketState: "string",
+          Upload: "File",
+        },
+      },
     },
   }
 };
@@ -3,7 +3,7 @@ import {Popover, PopoverContent, PopoverTrigger} from "@/components/ui/popover";
 import {Button} from "@/components/ui/button";
 import {cn} from "@/lib/utils";
 import {Command, CommandGroup, CommandInput, CommandItem} from "@/components/ui/command";
-import {TicketState} from "@/lib/graph/generated/graphql";
+import {TicketState} from "@/lib/ticket-state";
 import {Check} from "lucide-react";
 import LabelSelection from "@/components/label-selection";
 import {DateRangeFilter} from "@/components/date-range-filter";
@@ -1,16 +1,30 @@
 "use client"
 
-import React, {createContext, ReactNode, SetStateAction, useContext, useEffect, useState} from "react";
+import React, {
+  createContext,
+  ReactNode,
+  SetStateAction,
+  useCallback,
+  useContext,
+  useEffect,
+  useRef,
+  useState
+} from "react";
 import {
   AddLabelsToTicketDocument,
-  AllTicketsDocument,
   DeleteTicketDocument, Label,
   LabelToTicketAssignment,
+  PaginatedTicketsDocument,
   RemoveLabelsFromTicketDocument,
-  Ticket, TicketState,
+  SortDirection,
+  Ticket,
+  TicketFilter,
+  TicketSort,
+  TicketSortField,
   UpdateTicket,
   UpdateTicketDocument
 } from "@/lib/graph/generated/graphql";
+import {TicketState} from "@/lib/ticket-state";
 import {getClient} from "@/lib/graph/client";
 import {defaultTicketFiltering, defaultTicketSorting} from "@/lib/graph/defaultTypes";
 import {compareStringSets} from "@/lib/utils";
@@ -31,8 +45,41 @@ export type TicketFiltering = {
   endDate: Date | null;
 }
 
+// tickets are filtered, sorted and paged on the server, more pages are loaded on demand
+const ticketPageSize = 50
+
+// typing in the search field should not send a request per key
+const filterDebounceMs = 300
+
+const sortFields: Record<TicketSortingField, TicketSortField> = {
+  "Erstellt": TicketSortField.CreatedAt,
+  "Geändert": TicketSortField.LastModified,
+  "Titel": TicketSortField.Title,
+}
+
+function toTicketFilter(filtering: TicketFiltering): TicketFilter {
+  return {
+    text: filtering.searchTerm.trim() || undefined,
+    states: filtering.state.length > 0 ? filtering.state : undefined,
+    labelIDs: filtering.labels.length > 0 ? filtering.labels.map(label => label.id) : undefined,
+    createdAfter: filtering.startDate ?? undefined,
+    createdBefore: filtering.endDate ?? undefined,
+  }
+}
+
+function toTicketSort(sorting: TicketSorting): TicketSort {
+  return {
+    field: sortFields[sorting.field],
+    direction: sorting.orderAscending ? SortDirection.Asc : SortDirection.Desc,
+  }
+}
+
 interface TicketsContextType {
   tickets: Ticket[];
+  // number of tickets matching the filters, including the ones not loaded yet
+  totalCount: number
+  hasMoreTickets: boolean
+  loadMoreTickets: () => void
   filtering: TicketFiltering
   stateFilterSet: boolean
   areFiltersSet: boolean
@@ -50,29 +97,54 @@ const TicketsContext = createContext<TicketsContextType | null>(null);
 
 export function TicketsProvider({children}: { children: ReactNode }) {
   const [tickets, setTickets] = useState<Ticket[]>([]);
+  const [totalCount, setTotalCount] = useState(0);
+  const [endCursor, setEndCursor] = useState<string | null>(null);
+  const [hasMoreTickets, setHasMoreTickets] = useState(false);
+  const latestRequest = useRef(0);
   const [refetchKey, setRefetchKey] = useState(false);
   const [sorting, setSorting] = useState(defaultTicketSorting);
   const [filtering, setFiltering] = useState(defaultTicketFiltering);
   const [stateFilterSet, setStateFilterSet] = useState(false)
   const [areFiltersSet, setAreFiltersSet] = useState(false)
 
+  const fetchTickets = useCallback(async (after?: string) => {
+    const request = ++latestRequest.current
+    const client = getClient()
+    const data = await client.request(PaginatedTicketsDocument, {
+      filter: toTicketFilter(filtering),
+      sort: toTicketSort(sorting),
+      first: ticketPageSize,
+      after,
+    })
+
+    // the filters or sorting changed while the request was running
+    if (request !== latestRequest.current) return
+
+    const page = data.paginatedTickets
+    const newTickets: Ticket[] = page.edges.map(({node: ticket}) => ({
+      ...ticket,
+      labels: ticket.labels?.map(label => ({...label})),
+      // DB returns a timestamp which ts cannot compare directly
+      createdAt: new Date(ticket.createdAt),
+      lastModified: new Date(ticket.lastModified),
+    }))
+
+    setTickets(prevTickets => after ? [...prevTickets, ...newTickets] : newTickets);
+    setTotalCount(page.totalCount)
+    setHasMoreTickets(page.pageInfo.hasNextPage)
+    setEndCursor(page.pageInfo.endCursor ?? null)
+  }, [filtering, sorting]);
+
   useEffect(() => {
-    const fetchTickets = async () => {
-      const client = getClient()
-      const data = await client.request(AllTicketsDocument)
-
-      const newTickets: Ticket[] = data.tickets?.filter(ticket => !!ticket).map(ticket => ({
-        ...ticket,
-        labels: ticket.labels?.map(label => ({...label})),
-        // DB returns a timestamp which ts cannot compare directly
-        createdAt: new Date(ticket.createdAt),
-        lastModified: new Date(ticket.lastModified),
-      })) ?? []
-
-      setTickets(newTickets);
+    const timeout = setTimeout(() => void fetchTickets(), filterDebounceMs)
+    return () => clearTimeout(timeout)
+  }, [fetchTickets, refetchKey]);
+
+  function loadMoreTickets() {
+    if (hasMoreTickets && endCursor) {
+      void fetchTickets(endCursor)
     }
-    void fetchTickets();
-  }, [refetchKey]);
+  }
 
   useEffect(() => {
     const originalState = new Set(defaultTicketFiltering.state)
@@ -165,6 +237,9 @@ export function TicketsProvider({children}: { children: ReactNode }) {
     <TicketsContext.Provider
       value={{
         tickets,
+        totalCount,
+        hasMoreTickets,
+        loadMoreTickets,
         filtering,
         stateFilterSet,
         areFiltersSet,
@@ -3,7 +3,8 @@ import * as ticketInfoPane from "../../pages/tickets/ticket-info-pane.po";
 import * as ticketLabelArea from "../../pages/tickets/ticket-label-area.po";
 import * as ticketStatusArea from "../../pages/tickets/ticket-status-area.po";
 import * as confirmationDialog from "../../pages/confirmation-dialog.po";
-import {Label, TicketState, UserRole} from "../../../lib/graph/generated/graphql";
+import {Label, UserRole} from "../../../lib/graph/generated/graphql";
+import {TicketState} from "../../../lib/ticket-state";
 
 
 const roles: UserRole[] = [UserRole.Admin, UserRole.User]
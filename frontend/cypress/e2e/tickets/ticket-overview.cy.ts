@@ -1,7 +1,8 @@
 import * as ticketPage from "../../pages/tickets/ticket-overview.po";
 import {getTodayCalendarLabel, getTodaySuffixForCalendar} from "../../pages/tickets/ticket-overview.po";
 import * as filterBar from "../../pages/tickets/filter-bar.po";
-import {Label, Ticket, TicketState, UserRole} from "../../../lib/graph/generated/graphql";
+import {Label, Ticket, UserRole} from "../../../lib/graph/generated/graphql";
+import {TicketState} from "../../../lib/ticket-state";
 
 const roles: UserRole[] = [UserRole.Admin, UserRole.User]
 
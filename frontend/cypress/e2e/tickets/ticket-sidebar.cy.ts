@@ -1,4 +1,5 @@
-import {Label, Ticket, TicketState, UserRole} from "../../../lib/graph/generated/graphql";
+import {Label, Ticket, UserRole} from "../../../lib/graph/generated/graphql";
+import {TicketState} from "../../../lib/ticket-state";
 import * as ticketSidebar from "../../pages/tickets/ticket-sidebar.po";
 import * as filterBar from "../../pages/tickets/filter-bar.po";
 import * as ticketPage from "../../pages/tickets/ticket-overview.po";
@@ -1,5 +1,5 @@
 import {TicketSortingField} from "@/components/providers/ticket-provider";
-import {TicketState} from "@/lib/graph/generated/graphql";
+import {TicketState} from "@/lib/ticket-state";
 
 export function getTodaySuffixForCalendar () {
   const today = new Date();
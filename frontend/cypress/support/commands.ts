@@ -6,10 +6,11 @@ import {
   NewLabel,
   NewTicket,
   QuestionAnswerPair,
-  TicketState,
   UpdateUser,
   UserRole
 } from "../../lib/graph/generated/graphql";
+import {TicketState} from "../../lib/ticket-state";
+import {solveChallenge} from "../../lib/proof-of-work";
 import * as users from "../fixtures/users.json";
 import {LabelDialogData} from "../pages/labels/label-management.po";
 import {FAQDialogData} from "../pages/faqs/faq-dialog.po";
@@ -491,12 +492,15 @@ Cypress.Commands.add(
   }
 );
 
-Cypress.Commands.add('createTicket', (ticket: NewTicket): Cypress.Chainable<string> => {
-  const newTicket: NewTicket = {
-    originalTitle: ticket.originalTitle,
-    text: ticket.text,
-    labels: ticket.labels,
-  };
+Cypress.Commands.add('createTicket', (ticket: Omit<NewTicket, "challenge" | "challengeSolution">): Cypress.Chainable<string> => {
+  const challengeQuery = `
+    query submissionChallenge {
+      submissionChallenge {
+        challenge
+        difficulty
+      }
+    }
+  `;
 
   const mutation = `
     mutation createTicket($ticket: NewTicket!) {
@@ -511,11 +515,32 @@ Cypress.Commands.add('createTicket', (ticket: NewTicket): Cypress.Chainable<stri
     url: "/api",
     headers: {"Content-Type": "application/json"},
     body: {
-      query: mutation,
-      operationName: "createTicket",
-      variables: {ticket: newTicket},
+      query: challengeQuery,
+      operationName: "submissionChallenge",
     },
-  }).then((res) => res.body.data.createTicket.id as string);
+  }).then((res) => {
+    const {challenge, difficulty} = res.body.data.submissionChallenge;
+    return cy.wrap(solveChallenge(challenge, difficulty), {timeout: 60000}).then((solution) => {
+      const newTicket: NewTicket = {
+        originalTitle: ticket.originalTitle,
+        text: ticket.text,
+        labels: ticket.labels,
+        challenge: challenge,
+        challengeSolution: solution as string,
+      };
+
+      return cy.request({
+        method: "POST",
+        url: "/api",
+        headers: {"Content-Type": "application/json"},
+        body: {
+          query: mutation,
+          operationName: "createTicket",
+          variables: {ticket: newTicket},
+        },
+      }).then((res) => res.body.data.createTicket.id as string);
+    });
+  });
 });
 
 Cypress.Commands.add('deleteTicket', (id: string): Cypress.Chainable<Cypress.Response<any>> => {
@@ -587,7 +612,7 @@ declare global {
 
       getFooterSettings(): Chainable<any>;
 
-      createTicket(ticket: NewTicket): Chainable<string>;
+      createTicket(ticket: Omit<NewTicket, "challenge" | "challengeSolution">): Chainable<string>;
 
       deleteTicket(id: string): Chainable<Cypress.Response<any>>;
     }
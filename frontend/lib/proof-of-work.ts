@@ -0,0 +1,24 @@
+// Solves the proof-of-work of the submission form, see submissionChallenge in the server schema
+export async function solveChallenge(challenge: string, difficulty: number): Promise<string> {
+  const encoder = new TextEncoder();
+
+  for (let counter = 0; ; counter++) {
+    const solution = counter.toString();
+    const hash = await crypto.subtle.digest("SHA-256", encoder.encode(challenge + solution));
+
+    if (leadingZeroBits(new Uint8Array(hash)) >= difficulty) {
+      return solution;
+    }
+  }
+}
+
+function leadingZeroBits(bytes: Uint8Array): number {
+  let zeros = 0;
+  for (const byte of bytes) {
+    if (byte !== 0) {
+      return zeros + Math.clz32(byte) - 24;
+    }
+    zeros += 8;
+  }
+  return zeros;
+}
@@ -1,5 +1,4 @@
 import {Ticket, TicketState} from "@/lib/graph/generated/graphql";
-import {TicketFiltering, TicketSorting} from "@/components/providers/ticket-provider";
 
 export function getTicketStateColor(state: TicketState): string {
   const stateVarMap: Record<TicketState, string> = {
@@ -23,55 +22,6 @@ export function getTicketStateColor(state: TicketState): string {
   return rgbToHex(rgb);
 }
 
-export function getFilteredTickets(filter: TicketFiltering, tickets: Ticket[]) {
-  return tickets.filter(ticket => {
-    if (!ticket) return false;
-
-    const filterSearch = filter.searchTerm.toLowerCase();
-    const matchesTitleOrText =
-      ticket.title.toLowerCase().includes(filterSearch) ||
-      ticket.text.toLowerCase().includes(filterSearch);
-
-    const matchesState =
-      filter.state.length > 0 ? filter.state.includes(ticket.state) : true;
-
-    const matchesLabel =
-      filter.labels.length > 0
-        ? ticket.labels?.some((label) => filter.labels.map(l => l.id).includes(label.id))
-        : true;
-
-    const matchesStartDate = filter.startDate ? new Date(ticket.createdAt) >= filter.startDate : true
-    const matchesEndDate = filter.endDate ? new Date(ticket.createdAt) <= filter.endDate : true
-
-    return matchesTitleOrText && matchesState && matchesLabel && matchesStartDate && matchesEndDate;
-  });
-}
-
-export function getSortedTickets(sorting: TicketSorting, tickets: Ticket[]) {
-  tickets.sort((a, b) => {
-    if (!a || !b) return 0;
-    let valA: string | number = "";
-    let valB: string | number = "";
-
-    if (sorting.field === "Erstellt") {
-      valA = new Date(a.createdAt).getTime();
-      valB = new Date(b.createdAt).getTime();
-    } else if (sorting.field === "Geändert") {
-      valA = new Date(a.lastModified).getTime();
-      valB = new Date(b.lastModified).getTime();
-    } else if (sorting.field === "Titel") {
-      valA = a.title.toLowerCase();
-      valB = b.title.toLowerCase();
-    }
-
-    if (valA < valB) return sorting.orderAscending ? -1 : 1;
-    if (valA > valB) return sorting.orderAscending ? 1 : -1;
-    return 0;
-  });
-
-  return tickets;
-}
-
 export function getCurrentSemesterTickets(tickets: Ticket[]) {
   const now = new Date();
   const year = now.getFullYear();
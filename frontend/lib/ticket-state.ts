@@ -0,0 +1,8 @@
+// Ticket states are configured by admins on the server, these are the ones which always exist
+export const TicketState = {
+  New: "NEW",
+  Open: "OPEN",
+  Closed: "CLOSED",
+} as const;
+
+export type TicketState = string;
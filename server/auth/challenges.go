@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MaxChallengeDifficulty = 32
+	challengeValidity      = 10 * time.Minute
+	challengeNonceEntropy  = 16
+)
+
+var (
+	// challenges are only checked by the instance that issued them,
+	// so the key does not need to survive restarts
+	challengeKey = newChallengeKey()
+
+	usedChallenges   = make(map[string]time.Time)
+	usedChallengesMu sync.Mutex
+)
+
+func newChallengeKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Errorf("error generating challenge key %w", err))
+	}
+	return key
+}
+
+// NewChallenge returns a signed proof-of-work challenge. A solution is any string which,
+// appended to the challenge, gives a SHA-256 hash starting with difficulty zero bits.
+func NewChallenge(difficulty int) (challenge string, expiresAt time.Time, err error) {
+	if difficulty < 0 || difficulty > MaxChallengeDifficulty {
+		return "", time.Time{}, fmt.Errorf("difficulty must be between 0 and %v", MaxChallengeDifficulty)
+	}
+
+	nonce := make([]byte, challengeNonceEntropy)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", time.Time{}, fmt.Errorf("error generating challenge %w", err)
+	}
+
+	expiresAt = time.Now().Add(challengeValidity)
+	payload := fmt.Sprintf("%s.%d.%d", hex.EncodeToString(nonce), difficulty, expiresAt.Unix())
+
+	return payload + "." + signChallenge(payload), expiresAt, nil
+}
+
+// VerifyChallenge checks that the challenge was issued by us, is neither expired nor used
+// before and that the solution is valid. On success the challenge cannot be used again,
+// unless it is handed back with ReleaseChallenge.
+func VerifyChallenge(challenge, solution string) error {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 4 {
+		return fmt.Errorf("invalid challenge")
+	}
+
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(signChallenge(payload))) {
+		return fmt.Errorf("invalid challenge")
+	}
+
+	difficulty, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid challenge")
+	}
+
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid challenge")
+	}
+
+	expiresAt := time.Unix(expires, 0)
+	if time.Now().After(expiresAt) {
+		return fmt.Errorf("challenge expired, please request a new one")
+	}
+
+	sum := sha256.Sum256([]byte(challenge + solution))
+	if leadingZeroBits(sum[:]) < difficulty {
+		return fmt.Errorf("challenge solution is wrong")
+	}
+
+	usedChallengesMu.Lock()
+	defer usedChallengesMu.Unlock()
+
+	now := time.Now()
+	for nonce, expiry := range usedChallenges {
+		if now.After(expiry) {
+			delete(usedChallenges, nonce)
+		}
+	}
+
+	if _, used := usedChallenges[parts[0]]; used {
+		return fmt.Errorf("challenge was already used, please request a new one")
+	}
+	usedChallenges[parts[0]] = expiresAt
+
+	return nil
+}
+
+// ReleaseChallenge makes a verified challenge usable again while it is valid, so that
+// a submission which was rejected can be corrected without solving a new one
+func ReleaseChallenge(challenge string) {
+	nonce, _, _ := strings.Cut(challenge, ".")
+
+	usedChallengesMu.Lock()
+	defer usedChallengesMu.Unlock()
+
+	delete(usedChallenges, nonce)
+}
+
+func signChallenge(payload string) string {
+	mac := hmac.New(sha256.New, challengeKey)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func leadingZeroBits(b []byte) int {
+	zeros := 0
+	for _, v := range b {
+		if v != 0 {
+			return zeros + bits.LeadingZeros8(v)
+		}
+		zeros += 8
+	}
+	return zeros
+}
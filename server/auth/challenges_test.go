@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// solve finds a solution the way the frontend does
+func solve(challenge string, difficulty int) string {
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		sum := sha256.Sum256([]byte(challenge + solution))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return solution
+		}
+	}
+}
+
+// wrongSolution finds a solution which does not meet the difficulty
+func wrongSolution(challenge string, difficulty int) string {
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		sum := sha256.Sum256([]byte(challenge + solution))
+		if leadingZeroBits(sum[:]) < difficulty {
+			return solution
+		}
+	}
+}
+
+func TestVerifyChallenge(t *testing.T) {
+	const difficulty = 8
+
+	challenge, _, err := NewChallenge(difficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(challenge, ".")
+
+	expiredPayload := fmt.Sprintf("%s.%d.%d", parts[0]+"00", difficulty, time.Now().Add(-time.Minute).Unix())
+	expired := expiredPayload + "." + signChallenge(expiredPayload)
+
+	easier := strings.Join([]string{parts[0], "0", parts[2], parts[3]}, ".")
+	otherKey := strings.Join(append(parts[:3:3], strings.Repeat("0", 64)), ".")
+
+	tests := []struct {
+		name      string
+		challenge string
+		solution  string
+		wantErr   string
+	}{
+		{name: "wrong solution", challenge: challenge, solution: wrongSolution(challenge, difficulty), wantErr: "wrong"},
+		{name: "valid", challenge: challenge, solution: solve(challenge, difficulty)},
+		{name: "used twice", challenge: challenge, solution: solve(challenge, difficulty), wantErr: "already used"},
+		{name: "lowered difficulty", challenge: easier, solution: "", wantErr: "invalid"},
+		{name: "other signature", challenge: otherKey, solution: solve(otherKey, difficulty), wantErr: "invalid"},
+		{name: "expired", challenge: expired, solution: solve(expired, difficulty), wantErr: "expired"},
+		{name: "malformed", challenge: "abc.8", solution: "", wantErr: "invalid"},
+		{name: "empty", challenge: "", solution: "", wantErr: "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyChallenge(tt.challenge, tt.solution)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyChallenge() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReleaseChallenge(t *testing.T) {
+	challenge, _, err := NewChallenge(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution := solve(challenge, 4)
+
+	if err := VerifyChallenge(challenge, solution); err != nil {
+		t.Fatal(err)
+	}
+
+	// the submission was rejected, so the challenge may be used for the corrected one
+	ReleaseChallenge(challenge)
+	if err := VerifyChallenge(challenge, solution); err != nil {
+		t.Fatalf("released challenge was not accepted: %v", err)
+	}
+	if err := VerifyChallenge(challenge, solution); err == nil {
+		t.Error("challenge was accepted a third time without being released")
+	}
+
+	// releasing something else has no effect
+	ReleaseChallenge("not a challenge")
+	if err := VerifyChallenge(challenge, solution); err == nil {
+		t.Error("challenge was accepted after releasing another one")
+	}
+}
+
+func TestNewChallengeDifficulty(t *testing.T) {
+	for _, difficulty := range []int{0, 1, MaxChallengeDifficulty} {
+		if _, _, err := NewChallenge(difficulty); err != nil {
+			t.Errorf("NewChallenge(%v): %v", difficulty, err)
+		}
+	}
+	for _, difficulty := range []int{-1, MaxChallengeDifficulty + 1} {
+		if _, _, err := NewChallenge(difficulty); err == nil {
+			t.Errorf("NewChallenge(%v) did not fail", difficulty)
+		}
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x40}, 9},
+		{[]byte{0x00, 0x00, 0x0f}, 20},
+		{[]byte{0x00, 0x00}, 16},
+		{[]byte{}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := leadingZeroBits(tt.b); got != tt.want {
+			t.Errorf("leadingZeroBits(%x) = %v, want %v", tt.b, got, tt.want)
+		}
+	}
+}
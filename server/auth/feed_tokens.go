@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const feedTokenEntropy = 32
+
+// NewFeedToken returns a random token for the feed of a staff member together
+// with its hash, which is the only thing that gets stored
+func NewFeedToken() (token string, hash string, err error) {
+	b := make([]byte, feedTokenEntropy)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("error generating feed token %w", err)
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashFeedToken(token), nil
+}
+
+func HashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
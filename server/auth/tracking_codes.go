@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const trackingCodeEntropy = 20
+
+// NewTrackingCode returns a random tracking code for an anonymous ticket
+// submitter together with the hash which is the only thing that gets stored
+func NewTrackingCode() (code string, hash string, err error) {
+	b := make([]byte, trackingCodeEntropy)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("error generating tracking code %w", err)
+	}
+
+	code = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+
+	return code, HashTrackingCode(code), nil
+}
+
+// HashTrackingCode normalizes a tracking code as typed by a user and hashes it.
+// The code carries enough entropy that a fast hash is sufficient.
+func HashTrackingCode(code string) string {
+	normalized := strings.ToUpper(strings.Join(strings.Fields(code), ""))
+	normalized = strings.ReplaceAll(normalized, "-", "")
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
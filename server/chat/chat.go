@@ -0,0 +1,168 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+const requestTimeout = 10 * time.Second
+
+var client = &http.Client{Timeout: requestTimeout}
+
+// Message is posted as "<Headline>: <Title>" linking to URL, followed by the labels,
+// see events.Ticket
+type Message struct {
+	Headline string
+	Title    string
+	URL      string
+	Labels   []string
+}
+
+func (m Message) plain() string {
+	text := fmt.Sprintf("%v: %v\n", m.Headline, m.Title)
+	if len(m.Labels) > 0 {
+		text += fmt.Sprintf("Labels: %v\n", strings.Join(m.Labels, ", "))
+	}
+	return text + m.URL
+}
+
+func (m Message) html() string {
+	text := fmt.Sprintf("<b>%v:</b> <a href=\"%v\">%v</a>", html.EscapeString(m.Headline), html.EscapeString(m.URL), html.EscapeString(m.Title))
+	if len(m.Labels) > 0 {
+		text += "<br>Labels: " + html.EscapeString(strings.Join(m.Labels, ", "))
+	}
+	return text
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`)
+
+func (m Message) markdown() string {
+	text := fmt.Sprintf("**%v:** [%v](%v)", markdownEscaper.Replace(m.Headline), markdownEscaper.Replace(m.Title), m.URL)
+	if len(m.Labels) > 0 {
+		text += "\nLabels: " + markdownEscaper.Replace(strings.Join(m.Labels, ", "))
+	}
+	return text
+}
+
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (m Message) mrkdwn() string {
+	text := fmt.Sprintf("*%v:* <%v|%v>", mrkdwnEscaper.Replace(m.Headline), m.URL, mrkdwnEscaper.Replace(m.Title))
+	if len(m.Labels) > 0 {
+		text += "\nLabels: " + mrkdwnEscaper.Replace(strings.Join(m.Labels, ", "))
+	}
+	return text
+}
+
+// Send posts the message to the channel
+func Send(ctx context.Context, channel *models.ChatChannel, msg Message) error {
+	switch channel.Platform {
+	case model.ChatPlatformMatrix:
+		return sendMatrix(ctx, channel, msg)
+	case model.ChatPlatformMattermost:
+		return post(ctx, http.MethodPost, string(channel.URL), "", map[string]string{"text": msg.markdown()})
+	case model.ChatPlatformSLACk:
+		return post(ctx, http.MethodPost, string(channel.URL), "", map[string]string{"text": msg.mrkdwn()})
+	default:
+		return fmt.Errorf("unknown chat platform %v", channel.Platform)
+	}
+}
+
+func sendMatrix(ctx context.Context, channel *models.ChatChannel, msg Message) error {
+	txnID := make([]byte, 16)
+	if _, err := rand.Read(txnID); err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimRight(string(channel.URL), "/") +
+		"/_matrix/client/v3/rooms/" + url.PathEscape(channel.RoomID) +
+		"/send/m.room.message/" + hex.EncodeToString(txnID)
+
+	return post(ctx, http.MethodPut, endpoint, string(channel.AccessToken), map[string]string{
+		"msgtype":        "m.notice",
+		"body":           msg.plain(),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": msg.html(),
+	})
+}
+
+func post(ctx context.Context, method, endpoint, token string, content map[string]string) error {
+	body, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// the chat services explain errors in the body, e.g. M_FORBIDDEN if the bot is not in the room
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected response %v: %v", resp.Status, strings.TrimSpace(string(reason)))
+	}
+
+	return nil
+}
+
+// ValidateChannel checks the settings the platform needs, the URL has to be absolute http or https
+func ValidateChannel(channel *models.ChatChannel) error {
+	if strings.TrimSpace(channel.Name) == "" {
+		return fmt.Errorf("the name must not be empty")
+	}
+
+	u, err := url.Parse(string(channel.URL))
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("the url must start with http:// or https://")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("the url must contain a host")
+	}
+
+	if channel.Platform == model.ChatPlatformMatrix {
+		if !strings.HasPrefix(channel.RoomID, "!") {
+			return fmt.Errorf("matrix channels need the internal room ID, starting with !")
+		}
+		if channel.AccessToken == "" {
+			return fmt.Errorf("matrix channels need an access token")
+		}
+	}
+
+	if len(channel.Events) == 0 {
+		return fmt.Errorf("select at least one event")
+	}
+	for _, e := range channel.Events {
+		if e != string(model.NotificationTypeNewTicket) && e != string(model.NotificationTypeStateChanged) {
+			return fmt.Errorf("chat channels support %v and %v only", model.NotificationTypeNewTicket, model.NotificationTypeStateChanged)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+	"github.com/uptrace/bun"
+)
+
+const queueSize = 256
+
+// Notifier posts new tickets and state changes to the chat channels of their labels
+type Notifier struct {
+	DB     *bun.DB
+	Events *events.Bus
+}
+
+type queuedPost struct {
+	channel *models.ChatChannel
+	msg     Message
+}
+
+// Run handles ticket events until ctx is done, the messages are sent in the background
+// so slow chat servers do not hold up the events
+func (n *Notifier) Run(ctx context.Context) {
+	queue := make(chan queuedPost, queueSize)
+	go send(ctx, queue)
+
+	for event := range n.Events.Subscribe(ctx) {
+		if err := n.handle(ctx, event, queue); err != nil {
+			log.Printf("Failed to post ticket %v to chat channels: %v", event.TicketID, err)
+		}
+	}
+}
+
+// send posts the queued messages until ctx is done
+func send(ctx context.Context, queue <-chan queuedPost) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-queue:
+			if err := Send(ctx, p.channel, p.msg); err != nil {
+				log.Printf("Failed to post %v to chat channel %v: %v", p.msg.URL, p.channel.Name, err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) handle(ctx context.Context, event events.TicketEvent, queue chan<- queuedPost) error {
+	switch event.Type {
+	case events.TicketCreated, events.TicketStateChanged, events.TicketLabelled:
+	default:
+		return nil
+	}
+
+	ticket, err := events.Ticket(ctx, n.DB, event.TicketID, false)
+	if err != nil || ticket == nil {
+		return err
+	}
+
+	var labelIDs, labelNames []string
+	for _, l := range ticket.Labels {
+		labelIDs = append(labelIDs, l.ID)
+		labelNames = append(labelNames, l.Name)
+	}
+
+	msg := Message{
+		Title:  ticket.Title,
+		URL:    utils.EnvConfig.PublicURL("/tickets/" + ticket.ID),
+		Labels: labelNames,
+	}
+
+	var channels []*models.ChatChannel
+
+	switch {
+	case event.Type == events.TicketCreated,
+		// released tickets were not posted when they were created
+		event.Type == events.TicketStateChanged && event.OldState == model.TicketStateQuarantine:
+		msg.Headline = "Neues Ticket"
+		channels, err = n.channels(ctx, model.NotificationTypeNewTicket, labelIDs, true)
+	case event.Type == events.TicketStateChanged:
+		msg.Headline = fmt.Sprintf("Status %v → %v", event.OldState, event.NewState)
+		channels, err = n.channels(ctx, model.NotificationTypeStateChanged, labelIDs, true)
+	case event.Type == events.TicketLabelled:
+		msg.Headline = "Ticket mit neuem Label"
+		channels, err = n.channels(ctx, model.NotificationTypeNewTicket, event.LabelIDs, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channels {
+		// the message is dropped if the queue is full
+		select {
+		case queue <- queuedPost{channel: channel, msg: msg}:
+		default:
+			log.Printf("Chat queue is full, dropping post of ticket %v to %v", ticket.ID, channel.Name)
+		}
+	}
+
+	return nil
+}
+
+// channels returns the active channels posted to on the event type which have one of the labels,
+// and with includeUnlabelled also those without a label
+func (n *Notifier) channels(ctx context.Context, eventType model.NotificationType, labelIDs []string, includeUnlabelled bool) ([]*models.ChatChannel, error) {
+	if len(labelIDs) == 0 && !includeUnlabelled {
+		return nil, nil
+	}
+
+	var channels []*models.ChatChannel
+	err := n.DB.NewSelect().Model(&channels).
+		Where("active").
+		Where("? = ANY(events)", string(eventType)).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			if includeUnlabelled {
+				q = q.WhereOr("label_id IS NULL")
+			}
+			if len(labelIDs) > 0 {
+				q = q.WhereOr("label_id IN (?)", bun.In(labelIDs))
+			}
+			return q
+		}).
+		Scan(ctx)
+
+	return channels, err
+}
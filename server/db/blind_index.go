@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+// IndexTicketTexts builds the blind indexes of text and note of all tickets, or only of the ones
+// written before they were introduced. They depend on the encryption key, so all tickets have to
+// be indexed again after rotating it.
+func IndexTicketTexts(ctx context.Context, db *bun.DB, all bool) error {
+	indexed := 0
+	lastID := "00000000-0000-0000-0000-000000000000"
+
+	for {
+		var tickets []*models.Ticket
+		query := db.NewSelect().Model(&tickets).
+			Column("ticket.id", "ticket.text", "ticket.note").
+			WhereAllWithDeleted().
+			Where("ticket.id > ?", lastID).
+			OrderExpr("ticket.id").
+			Limit(rotationBatchSize)
+		if !all {
+			query = query.Where("ticket.text_tokens IS NULL OR ticket.note_tokens IS NULL")
+		}
+
+		if err := query.Scan(ctx); err != nil {
+			return fmt.Errorf("fetching tickets to index: %w", err)
+		}
+		if len(tickets) == 0 {
+			break
+		}
+
+		err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, t := range tickets {
+				// the tokens are set by the BeforeAppendModel hook of tickets
+				if _, err := tx.NewUpdate().Model(t).
+					Column("text_tokens", "note_tokens").
+					WhereAllWithDeleted().
+					WherePK().
+					Exec(ctx); err != nil {
+					return fmt.Errorf("indexing ticket %v: %w", t.ID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		indexed += len(tickets)
+		lastID = tickets[len(tickets)-1].ID
+	}
+
+	if indexed > 0 {
+		log.Printf("Indexed the texts of %v tickets", indexed)
+	}
+	return nil
+}
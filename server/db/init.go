@@ -6,13 +6,14 @@ import (
 	"fmt"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/FachschaftMathPhysInfo/kummerkasten/encryption"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
-	"github.com/uptrace/bun/extra/bundebug"
 )
 
 var (
@@ -27,13 +28,76 @@ var (
 		(*models.Ticket)(nil),
 		(*models.QuestionAnswerPair)(nil),
 		(*models.Session)(nil),
+		(*models.TicketComment)(nil),
+		(*models.TicketCommentRevision)(nil),
+		(*models.TicketMessage)(nil),
+		(*models.TicketEvent)(nil),
+		(*models.TicketStateDefinition)(nil),
+		(*models.TicketStateTransition)(nil),
+		(*models.MergedTicket)(nil),
+		(*models.TicketAttachment)(nil),
+		(*models.NotificationSubscription)(nil),
+		(*models.Webhook)(nil),
+		(*models.WebhookDelivery)(nil),
+		(*models.ChatChannel)(nil),
 	}
 
 	relations = []interface{}{
 		(*models.LabelsToTickets)(nil),
 	}
+
+	extensions = []string{
+		"pg_trgm",
+	}
+
+	// columns added to already existing tables after their first release
+	columns = []column{
+		{(*models.Ticket)(nil), "tracking_code_hash VARCHAR UNIQUE"},
+		{(*models.Ticket)(nil), models.TicketSearchVectorDefinition},
+		{(*models.Ticket)(nil), "assignee_id UUID"},
+		{(*models.Ticket)(nil), "deleted_at TIMESTAMPTZ"},
+		{(*models.Ticket)(nil), "anonymized_at TIMESTAMPTZ"},
+		{(*models.Ticket)(nil), "source VARCHAR NOT NULL DEFAULT 'FORM'"},
+		{(*models.Ticket)(nil), "text_tokens VARCHAR[]"},
+		{(*models.Ticket)(nil), "note_tokens VARCHAR[]"},
+		{(*models.MergedTicket)(nil), "text VARCHAR NOT NULL DEFAULT ''"},
+		{(*models.MergedTicket)(nil), "created_at TIMESTAMPTZ"},
+		{(*models.TicketMessage)(nil), "merged_ticket_id UUID"},
+		{(*models.User)(nil), "digest_frequency VARCHAR NOT NULL DEFAULT 'NONE'"},
+		{(*models.User)(nil), "last_digest_at TIMESTAMPTZ"},
+		{(*models.User)(nil), "feed_token_hash VARCHAR UNIQUE"},
+		{(*models.User)(nil), "feed_token_created_at TIMESTAMPTZ"},
+	}
+
+	indexes = []index{
+		{(*models.Ticket)(nil), "tickets_title_search_vector_idx", "GIN", "title_search_vector"},
+		{(*models.Ticket)(nil), "tickets_title_trgm_idx", "GIN", "title gin_trgm_ops"},
+		{(*models.Ticket)(nil), "tickets_text_tokens_idx", "GIN", "text_tokens"},
+		{(*models.Ticket)(nil), "tickets_note_tokens_idx", "GIN", "note_tokens"},
+	}
+
+	// columns and indexes of earlier releases which held plaintext of now encrypted fields,
+	// dropping a column drops its indexes as well
+	droppedColumns = []column{
+		{(*models.Ticket)(nil), "search_vector"},
+	}
+	droppedIndexes = []string{
+		"tickets_text_trgm_idx",
+	}
 )
 
+type column struct {
+	model      interface{}
+	definition string
+}
+
+type index struct {
+	model  interface{}
+	name   string
+	method string
+	column string
+}
+
 const MaxDbPings = 10
 const PingIntervalDBConnection = 5 * time.Second
 
@@ -62,10 +126,22 @@ func Init(ctx context.Context) (*sql.DB, *bun.DB) {
 		log.Fatal("Error connecting to database: ", err)
 	}
 
+	var oldKeys []string
+	if envConf.OldEncryptionKeys != "" {
+		oldKeys = strings.Split(envConf.OldEncryptionKeys, ",")
+	}
+	if err := encryption.SetKeys(envConf.EncryptionKey, oldKeys); err != nil {
+		log.Fatal("Error setting up encryption: ", err)
+	}
+
 	db = bun.NewDB(sqldb, pgdialect.New())
-	db.AddQueryHook(bundebug.NewQueryHook())
+	db.AddQueryHook(newQueryLogger())
 	db.RegisterModel((*models.LabelsToTickets)(nil))
 
+	if err := createExtensions(ctx, extensions); err != nil {
+		log.Panic("Failed to create extensions: ", err)
+	}
+
 	if err := createTables(ctx, tables); err != nil {
 		log.Panic("Failed to create basic tabels: ", err)
 	}
@@ -78,9 +154,64 @@ func Init(ctx context.Context) (*sql.DB, *bun.DB) {
 
 	log.Println("Basic Database Relations successfully initialized")
 
+	if err := dropColumns(ctx, droppedColumns); err != nil {
+		log.Panic("Failed to drop columns: ", err)
+	}
+
+	if err := dropIndexes(ctx, droppedIndexes); err != nil {
+		log.Panic("Failed to drop indexes: ", err)
+	}
+
+	if err := addColumns(ctx, columns); err != nil {
+		log.Panic("Failed to add missing columns: ", err)
+	}
+
+	if err := createIndexes(ctx, indexes); err != nil {
+		log.Panic("Failed to create indexes: ", err)
+	}
+
+	if err := IndexTicketTexts(ctx, db, false); err != nil {
+		log.Panic("Failed to index ticket texts: ", err)
+	}
+
 	return sqldb, db
 }
 
+// newQueryLogger logs every query in DEV and only slow ones in PROD, unless configured otherwise
+func newQueryLogger() *QueryLogger {
+	mode := envConf.QueryLog
+	if mode == "" {
+		mode = QueryLogOff
+		if envConf.Env == "DEV" {
+			mode = QueryLogVerbose
+		}
+	}
+
+	threshold := DefaultSlowQueryThreshold
+	if envConf.SlowQuery != "" {
+		threshold, err = time.ParseDuration(envConf.SlowQuery)
+		if err != nil {
+			log.Fatalf("Invalid %v: %v", utils.EnvSlowQuery, err)
+		}
+	}
+
+	logger, err := NewQueryLogger(mode, threshold)
+	if err != nil {
+		log.Fatalf("Invalid %v: %v", utils.EnvQueryLog, err)
+	}
+
+	return logger
+}
+
+func createExtensions(ctx context.Context, extensions []string) error {
+	for _, e := range extensions {
+		if _, err := db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS ?", bun.Ident(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func createTables(ctx context.Context, tables []interface{}) error {
 	for _, table := range tables {
 		if _, err := db.NewCreateTable().
@@ -92,3 +223,55 @@ func createTables(ctx context.Context, tables []interface{}) error {
 	}
 	return nil
 }
+
+func addColumns(ctx context.Context, columns []column) error {
+	for _, c := range columns {
+		if _, err := db.NewAddColumn().
+			Model(c.model).
+			ColumnExpr(c.definition).
+			IfNotExists().
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropColumns(ctx context.Context, columns []column) error {
+	for _, c := range columns {
+		if _, err := db.NewDropColumn().
+			Model(c.model).
+			ColumnExpr("IF EXISTS ?", bun.Ident(c.definition)).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(ctx context.Context, indexes []string) error {
+	for _, i := range indexes {
+		if _, err := db.NewDropIndex().
+			Index(i).
+			IfExists().
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createIndexes(ctx context.Context, indexes []index) error {
+	for _, i := range indexes {
+		if _, err := db.NewCreateIndex().
+			Model(i.model).
+			Index(i.name).
+			Using(i.method).
+			ColumnExpr(i.column).
+			IfNotExists().
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
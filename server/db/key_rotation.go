@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/encryption"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+const rotationBatchSize = 500
+
+// encrypted columns of all tables, each needs an id column
+var encryptedColumns = []struct {
+	table  string
+	column string
+}{
+	{"tickets", "text"},
+	{"tickets", "note"},
+	{"ticket_comments", "text"},
+	{"ticket_comment_revisions", "text"},
+	{"ticket_messages", "text"},
+	{"merged_tickets", "text"},
+	{"webhooks", "secret"},
+	{"chat_channels", "url"},
+	{"chat_channels", "access_token"},
+}
+
+// RotateEncryptionKey re-encrypts every value which is not encrypted with the current key yet,
+// including plaintext written before encryption was introduced. Afterwards the old keys can be removed.
+func RotateEncryptionKey(ctx context.Context, db *bun.DB) error {
+	currentPrefix, err := encryption.CurrentPrefix()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range encryptedColumns {
+		rotated := 0
+
+		for {
+			var rows []struct {
+				ID    string
+				Value string
+			}
+
+			// the trash is not skipped, as TableExpr bypasses the soft delete of tickets
+			if err := db.NewSelect().
+				TableExpr("?", bun.Ident(c.table)).
+				ColumnExpr("id").
+				ColumnExpr("? AS value", bun.Ident(c.column)).
+				Where("? IS NOT NULL", bun.Ident(c.column)).
+				Where("? NOT LIKE ?", bun.Ident(c.column), currentPrefix+"%").
+				OrderExpr("id").
+				Limit(rotationBatchSize).
+				Scan(ctx, &rows); err != nil {
+				return fmt.Errorf("fetching %v.%v: %w", c.table, c.column, err)
+			}
+
+			if len(rows) == 0 {
+				break
+			}
+
+			err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+				for _, row := range rows {
+					plaintext, err := encryption.Decrypt(row.Value)
+					if err != nil {
+						return fmt.Errorf("decrypting %v.%v of %v: %w", c.table, c.column, row.ID, err)
+					}
+
+					if _, err := tx.NewUpdate().
+						TableExpr("?", bun.Ident(c.table)).
+						Set("? = ?", bun.Ident(c.column), models.EncryptedString(plaintext)).
+						Where("id = ?", row.ID).
+						Exec(ctx); err != nil {
+						return fmt.Errorf("updating %v.%v of %v: %w", c.table, c.column, row.ID, err)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			rotated += len(rows)
+		}
+
+		log.Printf("Re-encrypted %v values of %v.%v", rotated, c.table, c.column)
+	}
+
+	return IndexTicketTexts(ctx, db, true)
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	QueryLogOff      = "off"
+	QueryLogRedacted = "redacted"
+	QueryLogVerbose  = "verbose"
+
+	DefaultSlowQueryThreshold = 500 * time.Millisecond
+
+	redactedValue = "'<redacted>'"
+)
+
+// QueryLogger writes executed queries to the log. Queries slower than the threshold
+// are always logged, redacted unless the mode is verbose.
+type QueryLogger struct {
+	mode          string
+	slowThreshold time.Duration
+}
+
+// NewQueryLogger creates a query hook, a slow threshold of zero disables the slow query log
+func NewQueryLogger(mode string, slowThreshold time.Duration) (*QueryLogger, error) {
+	switch mode {
+	case QueryLogOff, QueryLogRedacted, QueryLogVerbose:
+	default:
+		return nil, fmt.Errorf("unknown query log mode %q, use %v, %v or %v", mode, QueryLogOff, QueryLogRedacted, QueryLogVerbose)
+	}
+
+	if slowThreshold < 0 {
+		return nil, fmt.Errorf("slow query threshold must not be negative")
+	}
+
+	return &QueryLogger{mode: mode, slowThreshold: slowThreshold}, nil
+}
+
+func (l *QueryLogger) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (l *QueryLogger) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	duration := time.Since(event.StartTime)
+	slow := l.slowThreshold > 0 && duration >= l.slowThreshold
+
+	if l.mode == QueryLogOff && !slow {
+		return
+	}
+
+	query := event.Query
+	if l.mode != QueryLogVerbose {
+		query = RedactQuery(query)
+	}
+
+	prefix := "Query"
+	if slow {
+		prefix = "Slow query"
+	}
+
+	duration = duration.Round(time.Microsecond)
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		log.Printf("%v failed after %v: %v: %v", prefix, duration, event.Err, query)
+		return
+	}
+	log.Printf("%v took %v: %v", prefix, duration, query)
+}
+
+// RedactQuery replaces all string literals of a formatted query. Ticket texts, password
+// hashes, session IDs and mails are all strings, so none of them are left in the result,
+// while the structure of the query, numbers and identifiers stay readable.
+func RedactQuery(query string) string {
+	var out strings.Builder
+	out.Grow(len(query))
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '"':
+			// quoted identifiers are kept, they may contain quotes which must not start a literal
+			end := closingQuote(query, i+1, '"')
+			out.WriteString(query[i:end])
+			i = end - 1
+		case c == '\'':
+			end := closingQuote(query, i+1, '\'')
+			out.WriteString(redactedValue)
+			i = end - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	return out.String()
+}
+
+// closingQuote returns the index after the quote closing the literal which starts at start,
+// doubled quotes are escapes and do not end it
+func closingQuote(query string, start int, quote byte) int {
+	for i := start; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
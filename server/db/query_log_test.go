@@ -0,0 +1,89 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "string literals",
+			query: `SELECT * FROM "users" WHERE "mail" = 'a@example.org' AND "role" = 'ADMIN'`,
+			want:  `SELECT * FROM "users" WHERE "mail" = '<redacted>' AND "role" = '<redacted>'`,
+		},
+		{
+			name:  "numbers and keywords are kept",
+			query: `SELECT "id" FROM "tickets" WHERE "position" > 3 LIMIT 10 OFFSET 20`,
+			want:  `SELECT "id" FROM "tickets" WHERE "position" > 3 LIMIT 10 OFFSET 20`,
+		},
+		{
+			name:  "escaped quotes",
+			query: `INSERT INTO "tickets" ("title") VALUES ('it''s ''quoted''')`,
+			want:  `INSERT INTO "tickets" ("title") VALUES ('<redacted>')`,
+		},
+		{
+			name:  "quotes in identifiers",
+			query: `SELECT "odd'name", "with""quote" FROM "t" WHERE "x" = 'secret'`,
+			want:  `SELECT "odd'name", "with""quote" FROM "t" WHERE "x" = '<redacted>'`,
+		},
+		{
+			name:  "empty literal",
+			query: `UPDATE "tickets" SET "note" = ''`,
+			want:  `UPDATE "tickets" SET "note" = '<redacted>'`,
+		},
+		{
+			name:  "arrays and casts",
+			query: `SELECT * FROM "tickets" WHERE "text_tokens" @> '{"abc","def"}'::varchar[]`,
+			want:  `SELECT * FROM "tickets" WHERE "text_tokens" @> '<redacted>'::varchar[]`,
+		},
+		{
+			name:  "multi-line text",
+			query: "INSERT INTO \"ticket_comments\" (\"text\") VALUES ('first line\nsecond line')",
+			want:  `INSERT INTO "ticket_comments" ("text") VALUES ('<redacted>')`,
+		},
+		{
+			name:  "unterminated literal",
+			query: `SELECT 'secret`,
+			want:  `SELECT '<redacted>'`,
+		},
+		{
+			name:  "unicode",
+			query: `SELECT 'Grüße' AS "Übersicht"`,
+			want:  `SELECT '<redacted>' AS "Übersicht"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactQuery(tt.query); got != tt.want {
+				t.Errorf("RedactQuery(%q)\ngot  %v\nwant %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewQueryLogger(t *testing.T) {
+	tests := []struct {
+		mode          string
+		slowThreshold time.Duration
+		wantErr       bool
+	}{
+		{QueryLogOff, 0, false},
+		{QueryLogRedacted, DefaultSlowQueryThreshold, false},
+		{QueryLogVerbose, time.Second, false},
+		{"all", 0, true},
+		{"", 0, true},
+		{QueryLogOff, -time.Second, true},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewQueryLogger(tt.mode, tt.slowThreshold); (err != nil) != tt.wantErr {
+			t.Errorf("NewQueryLogger(%q, %v) error = %v, wantErr %v", tt.mode, tt.slowThreshold, err, tt.wantErr)
+		}
+	}
+}
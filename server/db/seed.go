@@ -7,8 +7,11 @@ import (
 	"time"
 
 	"github.com/FachschaftMathPhysInfo/kummerkasten/auth"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/filters"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/notifications"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
 	"github.com/uptrace/bun"
 )
@@ -23,6 +26,9 @@ func SeedData(ctx context.Context, db *bun.DB) error {
 	if err := createDefaultLabels(ctx, db); err != nil {
 		return err
 	}
+	if err := createDefaultTicketStates(ctx, db); err != nil {
+		return err
+	}
 
 	if envConf.Env != "PROD" {
 		if err := seedTestData(ctx, db); err != nil {
@@ -145,10 +151,28 @@ func createSettings(ctx context.Context, db *bun.DB) error {
 	settings := []*models.Setting{
 		{Key: contactLinkKey, Value: "https://mathphys.stura.uni-heidelberg.de/kontakt/"},
 		{Key: legalNoticeKey, Value: "https://mathphys.stura.uni-heidelberg.de/"},
+		{Key: models.TrashRetentionDaysKey, Value: "30"},
+		{Key: models.RetentionDaysKey, Value: "0"},
+		{Key: models.RetentionActionKey, Value: "ANONYMIZE"},
+		{Key: models.RetentionExemptLabelsKey, Value: ""},
+		{Key: models.DigestTemplateKey, Value: notifications.DefaultDigestTemplate},
+		{Key: models.DigestStaleDaysKey, Value: "7"},
+		{Key: models.SubmissionChallengeDifficultyKey, Value: "16"},
+		{Key: filters.BannedWordsKey, Value: ""},
+		{Key: filters.MaxLinksKey, Value: "5"},
+		{Key: filters.MaxRepeatedCharactersKey, Value: "20"},
+		{Key: filters.RegexesKey, Value: ""},
+		{Key: middleware.RateLimitSettingPrefix + "CREATE_TICKET", Value: "5/1h"},
+		{Key: middleware.RateLimitSettingPrefix + "LOGIN", Value: "10/15m"},
+		{Key: middleware.RateLimitSettingPrefix + "INBOUND_MAIL", Value: "10/1h"},
+		{Key: models.InboundMailLabelsKey, Value: ""},
 		{Key: aboutSectionTextKey, Value: "Der Kummerkasten ist das Feedbacksammlungssystem der Fachschaft. Er hilft bei Problemen in Vorlesungen (und bei Problemen mit anderen Institutionen, denen Studenten im Unialltag begegnen). \nDen Digitalen Kummerkasten findest du hier. Der analoge Kummerkasten steht im Gang vor dem Fachschaftsraum (bei den Flyern vor der Teeküche)."},
 	}
 
-	keys := []string{contactLinkKey, legalNoticeKey, aboutSectionTextKey}
+	keys := make([]string, 0, len(settings))
+	for _, s := range settings {
+		keys = append(keys, s.Key)
+	}
 	existing := make([]*models.Setting, 0)
 
 	if err := db.NewSelect().
@@ -212,6 +236,54 @@ func createDefaultLabels(ctx context.Context, db *bun.DB) error {
 	return nil
 }
 
+// createDefaultTicketStates sets up the workflow which was hard coded before states became configurable,
+// where tickets may move freely between all states
+func createDefaultTicketStates(ctx context.Context, db *bun.DB) error {
+	states := []*models.TicketStateDefinition{
+		{Key: model.TicketStateNew, Name: "Neu", Color: "#c0392b", Position: 0},
+		{Key: model.TicketStateOpen, Name: "Offen", Color: "#d68910", Position: 1},
+		{Key: model.TicketStateClosed, Name: "Geschlossen", Color: "#229954", Position: 2},
+	}
+
+	if err := insertData(ctx, db, (*models.TicketStateDefinition)(nil), states, "Ticket States"); err != nil {
+		return err
+	}
+
+	var transitions []*models.TicketStateTransition
+	for _, from := range states {
+		for _, to := range states {
+			if from.Key != to.Key {
+				transitions = append(transitions, &models.TicketStateTransition{FromKey: from.Key, ToKey: to.Key})
+			}
+		}
+	}
+
+	if err := insertData(ctx, db, (*models.TicketStateTransition)(nil), transitions, "Ticket State Transitions"); err != nil {
+		return err
+	}
+
+	return createQuarantineState(ctx, db)
+}
+
+// createQuarantineState adds the state of filtered tickets, which may be released or closed by admins.
+// It is created separately, as it was added after the other states were already seeded.
+func createQuarantineState(ctx context.Context, db *bun.DB) error {
+	quarantine := &models.TicketStateDefinition{Key: model.TicketStateQuarantine, Name: "Quarantäne", Color: "#7d3c98", Position: 3}
+	if _, err := db.NewInsert().Model(quarantine).On("CONFLICT DO NOTHING").Exec(ctx); err != nil {
+		return fmt.Errorf("Quarantine State: %s", err)
+	}
+
+	transitions := []*models.TicketStateTransition{
+		{FromKey: model.TicketStateQuarantine, ToKey: model.TicketStateNew},
+		{FromKey: model.TicketStateQuarantine, ToKey: model.TicketStateClosed},
+	}
+	if _, err := db.NewInsert().Model(&transitions).On("CONFLICT DO NOTHING").Exec(ctx); err != nil {
+		return fmt.Errorf("Quarantine State Transitions: %s", err)
+	}
+
+	return nil
+}
+
 func seedTestData(ctx context.Context, db *bun.DB) error {
 
 	if err := seedTestUsers(ctx, db); err != nil {
@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenLength is the number of hex characters kept of each keyed hash
+const tokenLength = 16
+
+// words which are too common to find anything
+var stopWords = map[string]struct{}{
+	"aber": {}, "als": {}, "am": {}, "an": {}, "auch": {}, "auf": {}, "aus": {}, "bei": {}, "bin": {},
+	"bis": {}, "da": {}, "das": {}, "dass": {}, "dem": {}, "den": {}, "der": {}, "des": {}, "die": {},
+	"doch": {}, "du": {}, "ein": {}, "eine": {}, "einem": {}, "einen": {}, "einer": {}, "er": {}, "es": {},
+	"fur": {}, "hat": {}, "ich": {}, "ihr": {}, "im": {}, "in": {}, "ist": {}, "mit": {}, "nach": {},
+	"nicht": {}, "noch": {}, "nur": {}, "oder": {}, "sehr": {}, "sich": {}, "sie": {}, "sind": {},
+	"so": {}, "um": {}, "und": {}, "uns": {}, "von": {}, "vor": {}, "war": {}, "was": {}, "wenn": {},
+	"wie": {}, "wir": {}, "wird": {}, "zu": {}, "zum": {}, "zur": {},
+	"and": {}, "the": {}, "is": {}, "of": {}, "to": {}, "or": {},
+}
+
+// suffixes stripped from words, so that e.g. Prüfung and Prüfungen match
+var suffixes = []string{"ern", "em", "en", "er", "es", "e", "s"}
+
+// BlindIndex returns keyed hashes of the words of a text, sorted and without duplicates. They allow
+// to find encrypted texts by words without storing the words, and change with the encryption key.
+// Words are lowercased, umlauts folded and common German endings stripped, stop words are left out.
+func BlindIndex(text string) ([]string, error) {
+	if current == nil {
+		return nil, ErrNoKey
+	}
+
+	tokens := []string{}
+	seen := make(map[string]struct{})
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		word = fold(word)
+		if _, stop := stopWords[word]; stop || utf8.RuneCountInString(word) < 2 {
+			continue
+		}
+		word = stem(word)
+		if _, duplicate := seen[word]; duplicate {
+			continue
+		}
+		seen[word] = struct{}{}
+
+		mac := hmac.New(sha256.New, current.index)
+		mac.Write([]byte(word))
+		tokens = append(tokens, hex.EncodeToString(mac.Sum(nil))[:tokenLength])
+	}
+
+	slices.Sort(tokens)
+	return tokens, nil
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func fold(word string) string {
+	return strings.NewReplacer("ä", "a", "ö", "o", "ü", "u", "ß", "ss").Replace(word)
+}
+
+// stem strips one ending, keeping at least three letters
+func stem(word string) string {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) && utf8.RuneCountInString(word)-len(suffix) >= 3 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
@@ -0,0 +1,88 @@
+package encryption
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBlindIndex(t *testing.T) {
+	if err := SetKeys(testKey('a'), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := func(text string) []string {
+		t.Helper()
+		got, err := BlindIndex(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"case", "Prüfung", "PRÜFUNG", true},
+		{"plural", "Prüfung", "Prüfungen", true},
+		{"umlauts", "Prüfung", "Prufung", true},
+		{"sharp s", "Straße", "Strasse", true},
+		{"separators", "Mathe-Klausur, Analysis!", "analysis mathe klausur", true},
+		{"stop words", "die Klausur und der Raum", "Klausur Raum", true},
+		{"duplicates", "Raum Raum Räume", "Raum", true},
+		{"short words kept", "Tee", "Tees", true},
+		{"different words", "Klausur", "Vorlesung", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := tokens(tt.a), tokens(tt.b)
+			if slices.Equal(a, b) != tt.same {
+				t.Errorf("BlindIndex(%q) = %v, BlindIndex(%q) = %v, same %v", tt.a, a, tt.b, b, tt.same)
+			}
+		})
+	}
+
+	if got := tokens("ab ich und x"); len(got) != 1 {
+		t.Errorf("got %v tokens, want only the one of ab", got)
+	}
+	if got := tokens(""); got == nil || len(got) != 0 {
+		t.Errorf("BlindIndex(\"\") = %#v, want an empty slice", got)
+	}
+
+	got := tokens("Zebra Apfel Mango")
+	if !slices.IsSorted(got) || len(got) != 3 || len(got[0]) != tokenLength {
+		t.Errorf("tokens %v are not sorted hashes of length %v", got, tokenLength)
+	}
+
+	// the tokens change with the key, so they do not reveal words across installations
+	if err := SetKeys(testKey('b'), nil); err != nil {
+		t.Fatal(err)
+	}
+	if other := tokens("Zebra Apfel Mango"); slices.Equal(other, got) {
+		t.Error("the tokens do not depend on the key")
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"prufungen", "prufung"},
+		{"raume", "raum"},
+		{"kindern", "kind"},
+		{"tees", "tee"},
+		// at least three letters are kept
+		{"see", "see"},
+		{"es", "es"},
+		{"klausur", "klausur"},
+	}
+
+	for _, tt := range tests {
+		if got := stem(tt.word); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
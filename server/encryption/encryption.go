@@ -0,0 +1,139 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length of the AES-256 keys, which are passed base64 encoded
+const KeySize = 32
+
+// prefix of encrypted values, followed by the ID of the key, a colon and the base64 encoded nonce and ciphertext.
+// Values without it were written before encryption was introduced and are returned as they are.
+const prefix = "enc:v1:"
+
+var ErrNoKey = errors.New("encryption keys are not set up")
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+	// index is the key of the blind index, derived so that it differs from the encryption key
+	index []byte
+}
+
+var (
+	current *key
+	keys    = map[string]*key{}
+)
+
+// SetKeys sets the key new values are encrypted with. Old keys are only used to
+// decrypt values which were not rotated to the current key yet.
+func SetKeys(currentKey string, oldKeys []string) error {
+	k, err := parseKey(currentKey)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+
+	all := map[string]*key{k.id: k}
+	for i, old := range oldKeys {
+		o, err := parseKey(old)
+		if err != nil {
+			return fmt.Errorf("invalid old encryption key %v: %w", i+1, err)
+		}
+		all[o.id] = o
+	}
+
+	current = k
+	keys = all
+	return nil
+}
+
+func parseKey(encoded string) (*key, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("not base64 encoded")
+	}
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("has %v bytes instead of %v", len(raw), KeySize)
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// the ID tells which key a value was encrypted with, it must not reveal the key
+	sum := sha256.Sum256(append([]byte("kummerkasten key id "), raw...))
+
+	mac := hmac.New(sha256.New, raw)
+	mac.Write([]byte("kummerkasten blind index"))
+
+	return &key{id: hex.EncodeToString(sum[:4]), aead: aead, index: mac.Sum(nil)}, nil
+}
+
+// Encrypt seals the plaintext with the current key and a random nonce
+func Encrypt(plaintext string) (string, error) {
+	if current == nil {
+		return "", ErrNoKey
+	}
+
+	nonce := make([]byte, current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := current.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return prefix + current.id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value written by Encrypt with whichever known key it was encrypted with
+func Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return value, nil
+	}
+
+	id, encoded, found := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !found {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+
+	k, ok := keys[id]
+	if !ok {
+		return "", fmt.Errorf("value is encrypted with unknown key %v", id)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting value with key %v: %w", id, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// CurrentPrefix is how values encrypted with the current key start,
+// everything else has to be re-encrypted when rotating keys
+func CurrentPrefix() (string, error) {
+	if current == nil {
+		return "", ErrNoKey
+	}
+	return prefix + current.id + ":", nil
+}
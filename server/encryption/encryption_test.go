@@ -0,0 +1,134 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), KeySize)))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	if err := SetKeys(testKey('a'), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, plaintext := range []string{"", "Der Beamer im Hörsaal ist kaputt", strings.Repeat("x", 10000), "enc:v1:"} {
+		encrypted, err := Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, sealed, _ := strings.Cut(strings.TrimPrefix(encrypted, prefix), ":"); plaintext != "" && strings.Contains(sealed, plaintext) {
+			t.Errorf("%q contains the plaintext", encrypted)
+		}
+
+		decrypted, err := Decrypt(encrypted)
+		if err != nil {
+			t.Fatalf("Decrypt(Encrypt(%q)): %v", plaintext, err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q", plaintext, decrypted)
+		}
+	}
+
+	first, _ := Encrypt("same")
+	second, _ := Encrypt("same")
+	if first == second {
+		t.Error("encrypting twice gives the same value, the nonce is not random")
+	}
+}
+
+func TestDecryptKeys(t *testing.T) {
+	if err := SetKeys(testKey('a'), nil); err != nil {
+		t.Fatal(err)
+	}
+	withA, _ := Encrypt("secret")
+	prefixA, _ := CurrentPrefix()
+
+	if err := SetKeys(testKey('b'), []string{testKey('a')}); err != nil {
+		t.Fatal(err)
+	}
+	withB, _ := Encrypt("secret")
+	prefixB, _ := CurrentPrefix()
+
+	if prefixA == prefixB {
+		t.Fatalf("both keys have the prefix %v", prefixA)
+	}
+	if !strings.HasPrefix(withA, prefixA) || !strings.HasPrefix(withB, prefixB) {
+		t.Errorf("values are not marked with the ID of their key: %v, %v", withA, withB)
+	}
+
+	// a value of a removed key, and one which was changed in the database
+	tampered := withB[:len(withB)-2] + "AA"
+	if tampered == withB {
+		tampered = withB[:len(withB)-2] + "BB"
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "current key", value: withB, want: "secret"},
+		{name: "old key", value: withA, want: "secret"},
+		{name: "plaintext from before the encryption", value: "not encrypted", want: "not encrypted"},
+		{name: "unknown key", value: "enc:v1:00000000:" + withB[len(prefixB):], wantErr: true},
+		{name: "missing key ID", value: "enc:v1:abc", wantErr: true},
+		{name: "invalid base64", value: prefixB + "!!!", wantErr: true},
+		{name: "too short", value: prefixB + "AAAA", wantErr: true},
+		{name: "tampered", value: tampered, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decrypt(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Decrypt(%q) = %q, expected an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Decrypt(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	// without the old key its values cannot be read anymore
+	if err := SetKeys(testKey('b'), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(withA); err == nil {
+		t.Error("decrypted a value of a removed key")
+	}
+}
+
+func TestSetKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		old     []string
+		wantErr bool
+	}{
+		{name: "valid", current: testKey('a'), old: []string{testKey('b')}},
+		{name: "surrounding whitespace", current: " " + testKey('a') + "\n"},
+		{name: "not base64", current: "not a key!", wantErr: true},
+		{name: "too short", current: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "invalid old key", current: testKey('a'), old: []string{"short"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetKeys(tt.current, tt.old)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
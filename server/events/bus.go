@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+)
+
+type Type string
+
+const (
+	TicketCreated      Type = "TICKET_CREATED"
+	TicketUpdated      Type = "TICKET_UPDATED"
+	TicketStateChanged Type = "TICKET_STATE_CHANGED"
+	TicketLabelled     Type = "TICKET_LABELLED"
+)
+
+// TicketEvent notifies about a change of a ticket which was committed to the database
+type TicketEvent struct {
+	Type     Type
+	TicketID string
+	OldState model.TicketState
+	NewState model.TicketState
+	// LabelIDs contains the labels added to the ticket on TicketLabelled
+	LabelIDs []string
+}
+
+const subscriberBufferSize = 64
+
+// Bus fans ticket events out to everything that reacts to ticket changes.
+// A nil Bus silently drops all events.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	events   chan TicketEvent
+	done     <-chan struct{}
+	blocking bool
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*subscriber]struct{})}
+}
+
+// Publish hands the events to all subscribers, events for subscribers which do not
+// keep up are dropped unless they subscribed with SubscribeBlocking
+func (b *Bus) Publish(events ...TicketEvent) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, event := range events {
+		for s := range b.subscribers {
+			if s.blocking {
+				select {
+				case s.events <- event:
+				case <-s.done:
+				}
+				continue
+			}
+
+			select {
+			case s.events <- event:
+			default:
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel receiving all published events until ctx is done
+func (b *Bus) Subscribe(ctx context.Context) <-chan TicketEvent {
+	return b.subscribe(ctx, false)
+}
+
+// SubscribeBlocking is like Subscribe, but Publish waits for the subscriber instead of
+// dropping events, for subscribers which must not miss any
+func (b *Bus) SubscribeBlocking(ctx context.Context) <-chan TicketEvent {
+	return b.subscribe(ctx, true)
+}
+
+func (b *Bus) subscribe(ctx context.Context, blocking bool) <-chan TicketEvent {
+	s := &subscriber{
+		events:   make(chan TicketEvent, subscriberBufferSize),
+		done:     ctx.Done(),
+		blocking: blocking,
+	}
+
+	if b == nil {
+		close(s.events)
+		return s.events
+	}
+
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		delete(b.subscribers, s)
+		close(s.events)
+		b.mu.Unlock()
+	}()
+
+	return s.events
+}
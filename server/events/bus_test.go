@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSubscribeBlocking(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := NewBus()
+	lossy := bus.Subscribe(ctx)
+	blocking := bus.SubscribeBlocking(ctx)
+
+	const published = 3 * subscriberBufferSize
+	go func() {
+		for i := 0; i < published; i++ {
+			bus.Publish(TicketEvent{Type: TicketUpdated, TicketID: fmt.Sprint(i)})
+		}
+	}()
+
+	for i := 0; i < published; i++ {
+		event := <-blocking
+		if event.TicketID != fmt.Sprint(i) {
+			t.Fatalf("event %v has ticket %v", i, event.TicketID)
+		}
+	}
+
+	if len(lossy) != subscriberBufferSize {
+		t.Errorf("the lossy subscriber buffered %v events, want %v", len(lossy), subscriberBufferSize)
+	}
+}
+
+func TestSubscribeBlockingDoesNotBlockAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	bus := NewBus()
+	bus.SubscribeBlocking(ctx)
+	cancel()
+
+	// publishing must neither block on the gone subscriber nor panic on its closed channel
+	for i := 0; i < 2*subscriberBufferSize; i++ {
+		bus.Publish(TicketEvent{Type: TicketUpdated})
+	}
+}
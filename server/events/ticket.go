@@ -0,0 +1,29 @@
+package events
+
+import (
+	"context"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+// Ticket loads the ticket of an event with its labels for the mails, chat messages, feed entries
+// and webhooks telling about it. None of them contain the ticket text, as they are neither
+// encrypted nor access controlled, they only link to the ticket.
+// It returns nil if the ticket has been deleted in the meantime, and for quarantined tickets
+// unless withQuarantine is set, as quarantined tickets are for admins only.
+func Ticket(ctx context.Context, db bun.IDB, ticketID string, withQuarantine bool) (*models.Ticket, error) {
+	var tickets []*models.Ticket
+	if err := db.NewSelect().Model(&tickets).
+		Relation("Labels").
+		Where("ticket.id = ?", ticketID).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(tickets) == 0 || (tickets[0].State == model.TicketStateQuarantine && !withQuarantine) {
+		return nil, nil
+	}
+	return tickets[0], nil
+}
@@ -0,0 +1,74 @@
+// Package export writes tables row by row as CSV, JSON Lines or XLSX,
+// so that large exports never have to be held in memory
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV   = "CSV"
+	FormatJSONL = "JSONL"
+	FormatXLSX  = "XLSX"
+)
+
+// Writer writes the header once and then the rows. A value is a string, a []string,
+// a time.Time (zero for empty) or nil. Close has to be called to complete the file.
+type Writer interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []any) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown export format %v", format)
+	}
+}
+
+// ContentType and Extension of the files of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/jsonl; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+func Extension(format string) string {
+	return "." + strings.ToLower(format)
+}
+
+// text formats a value for the formats without types
+func text(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ", ")
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,250 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testDate = time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+func write(t *testing.T, format string, rows ...[]any) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader([]string{"title", "labels", "created_at"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"Beamer kaputt", "Beamer kaputt"},
+		{"=HYPERLINK(\"http://evil.example\")", "'=HYPERLINK(\"http://evil.example\")"},
+		{"+49 123", "'+49 123"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=b", "a=b"},
+		{"'quoted", "'quoted"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeFormula(tt.value); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	got := write(t, FormatCSV,
+		[]any{"=1+1", []string{"Hörsaal", "Mensa"}, testDate},
+		[]any{"Komma, \"Zitat\"", nil, time.Time{}},
+	)
+
+	want := "title,labels,created_at\n" +
+		"'=1+1,\"Hörsaal, Mensa\",2026-03-04T05:06:07Z\n" +
+		"\"Komma, \"\"Zitat\"\"\",,\n"
+	if string(got) != want {
+		t.Errorf("got\n%v\nwant\n%v", string(got), want)
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	got := write(t, FormatJSONL,
+		[]any{"=1+1", []string{"Hörsaal"}, testDate},
+		[]any{"Zweites", nil, time.Time{}},
+	)
+
+	// formulas are only escaped for spreadsheets, the keys keep the order of the columns
+	want := `{"title":"=1+1","labels":["Hörsaal"],"created_at":"2026-03-04T05:06:07Z"}` + "\n" +
+		`{"title":"Zweites","labels":null,"created_at":null}` + "\n"
+	if string(got) != want {
+		t.Errorf("got\n%v\nwant\n%v", string(got), want)
+	}
+}
+
+type xlsxCell struct {
+	Ref  string `xml:"r,attr"`
+	Type string `xml:"t,attr"`
+	Text string `xml:"is>t"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Ref   int        `xml:"r,attr"`
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX checks the parts of the file and returns the cells of the sheet by reference
+func readXLSX(t *testing.T, data []byte) map[string]xlsxCell {
+	t.Helper()
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip file: %v", err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = content
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		content, ok := files[name]
+		if !ok {
+			t.Fatalf("%v is missing", name)
+		}
+		// all parts have to be well-formed
+		decoder := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%v is not well-formed: %v", name, err)
+			}
+		}
+	}
+
+	var sheet xlsxSheet
+	if err := xml.Unmarshal(files["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatal(err)
+	}
+
+	cells := make(map[string]xlsxCell)
+	for i, row := range sheet.Rows {
+		if row.Ref != i+1 {
+			t.Errorf("row %v has the reference %v", i+1, row.Ref)
+		}
+		for _, c := range row.Cells {
+			if c.Type != "inlineStr" {
+				t.Errorf("cell %v has the type %q", c.Ref, c.Type)
+			}
+			cells[c.Ref] = c
+		}
+	}
+	return cells
+}
+
+func TestXLSX(t *testing.T) {
+	cells := readXLSX(t, write(t, FormatXLSX,
+		[]any{"<Tür> & \"Fenster\"", []string{"Hörsaal", "Mensa"}, testDate},
+		[]any{"  Leerzeichen  ", nil, time.Time{}},
+		[]any{"=1+1", nil, nil},
+	))
+
+	want := map[string]string{
+		"A1": "title", "B1": "labels", "C1": "created_at",
+		"A2": "<Tür> & \"Fenster\"", "B2": "Hörsaal, Mensa", "C2": "2026-03-04T05:06:07Z",
+		"A3": "  Leerzeichen  ",
+		// inline strings are never evaluated, so formulas need no escaping
+		"A4": "=1+1",
+	}
+
+	for ref, text := range want {
+		if cells[ref].Text != text {
+			t.Errorf("cell %v is %q, want %q", ref, cells[ref].Text, text)
+		}
+	}
+	for ref := range cells {
+		if _, ok := want[ref]; !ok {
+			t.Errorf("unexpected cell %v", ref)
+		}
+	}
+}
+
+func TestXLSXInvalidCharacters(t *testing.T) {
+	cells := readXLSX(t, write(t, FormatXLSX, []any{"a\x00b\x1bc", nil, nil}))
+
+	if got := cells["A2"].Text; !strings.HasPrefix(got, "a") || !strings.HasSuffix(got, "c") {
+		t.Errorf("cell is %q", got)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+
+	for _, tt := range tests {
+		if got := columnName(tt.i); got != tt.want {
+			t.Errorf("columnName(%v) = %v, want %v", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("PDF", io.Discard); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestTruncateCell(t *testing.T) {
+	ascii := strings.Repeat("a", xlsxMaxCellLength)
+	umlauts := strings.Repeat("ü", xlsxMaxCellLength)
+	// emojis take two UTF-16 code units
+	emojis := strings.Repeat("😀", xlsxMaxCellLength/2)
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"short", "Beamer", "Beamer"},
+		{"at the limit", ascii, ascii},
+		{"over the limit", ascii + "b", ascii},
+		// the bytes exceed the limit, but the characters do not
+		{"multi-byte characters at the limit", umlauts, umlauts},
+		{"multi-byte characters over the limit", umlauts + "ü", umlauts},
+		{"surrogate pairs are not split", emojis + "😀", emojis},
+		{"surrogate pairs filling the cell", emojis + "a", emojis + "a"},
+	}
+
+	for _, tt := range tests {
+		got := truncateCell(tt.value)
+		if got != tt.want {
+			t.Errorf("%v: got %v bytes, want %v", tt.name, len(got), len(tt.want))
+		}
+	}
+}
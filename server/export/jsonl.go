@@ -0,0 +1,59 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type jsonlWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteHeader only remembers the keys, every line is a complete object
+func (j *jsonlWriter) WriteHeader(columns []string) error {
+	j.columns = columns
+	return nil
+}
+
+func (j *jsonlWriter) WriteRow(values []any) error {
+	// the object is written by hand to keep the order of the columns
+	if err := j.w.WriteByte('{'); err != nil {
+		return err
+	}
+
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok && t.IsZero() {
+			v = nil
+		}
+
+		key, err := json.Marshal(j.columns[i])
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			_ = j.w.WriteByte(',')
+		}
+		_, _ = j.w.Write(key)
+		_ = j.w.WriteByte(':')
+		_, _ = j.w.Write(value)
+	}
+
+	_, err := j.w.WriteString("}\n")
+	return err
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,182 @@
+// Package filters checks new tickets for spam and abuse before they are shown to anyone
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Setting keys the filters are configured with, a missing or empty setting disables the filter
+const (
+	SettingPrefix            = "FILTER_"
+	BannedWordsKey           = SettingPrefix + "BANNED_WORDS"
+	MaxLinksKey              = SettingPrefix + "MAX_LINKS"
+	MaxRepeatedCharactersKey = SettingPrefix + "MAX_REPEATED_CHARACTERS"
+	RegexesKey               = SettingPrefix + "REGEXES"
+)
+
+// Submission is the content of a new ticket as entered by the submitter
+type Submission struct {
+	Title string
+	Text  string
+}
+
+func (s Submission) content() string {
+	return s.Title + "\n" + s.Text
+}
+
+// Filter decides whether a submission has to be reviewed by an admin before it is shown.
+// The returned reason is stored in the history of the ticket.
+type Filter interface {
+	Check(s Submission) (matched bool, reason string)
+}
+
+// Pipeline runs all of its filters on a submission
+type Pipeline []Filter
+
+// Run returns the reasons of all filters matching the submission
+func (p Pipeline) Run(s Submission) []string {
+	var reasons []string
+	for _, f := range p {
+		if matched, reason := f.Check(s); matched {
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons
+}
+
+// BannedWords matches submissions containing one of the words, ignoring case
+type BannedWords struct {
+	Words []string
+}
+
+func (f BannedWords) Check(s Submission) (bool, string) {
+	content := strings.ToLower(s.content())
+	for _, word := range f.Words {
+		if strings.Contains(content, strings.ToLower(word)) {
+			return true, fmt.Sprintf("contains banned word %q", word)
+		}
+	}
+	return false, ""
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)`)
+
+// LinkCount matches submissions with more than Max links
+type LinkCount struct {
+	Max int
+}
+
+func (f LinkCount) Check(s Submission) (bool, string) {
+	count := len(linkPattern.FindAllStringIndex(s.content(), -1))
+	if count > f.Max {
+		return true, fmt.Sprintf("contains %v links, at most %v are allowed", count, f.Max)
+	}
+	return false, ""
+}
+
+// RepeatedCharacters matches submissions repeating a character more than Max times in a row
+type RepeatedCharacters struct {
+	Max int
+}
+
+func (f RepeatedCharacters) Check(s Submission) (bool, string) {
+	var last rune = utf8.RuneError
+	repeated := 0
+
+	for _, c := range s.content() {
+		if c == last {
+			repeated++
+		} else {
+			last = c
+			repeated = 1
+		}
+
+		if repeated > f.Max {
+			return true, fmt.Sprintf("repeats %q more than %v times", c, f.Max)
+		}
+	}
+	return false, ""
+}
+
+// Regexes matches submissions matching one of the patterns
+type Regexes struct {
+	Patterns []*regexp.Regexp
+}
+
+func (f Regexes) Check(s Submission) (bool, string) {
+	content := s.content()
+	for _, pattern := range f.Patterns {
+		if pattern.MatchString(content) {
+			return true, fmt.Sprintf("matches rule %q", pattern.String())
+		}
+	}
+	return false, ""
+}
+
+// NewPipeline builds the filters from the settings, mapping their keys to values
+func NewPipeline(settings map[string]string) (Pipeline, error) {
+	var pipeline Pipeline
+
+	if words := lines(settings[BannedWordsKey]); len(words) > 0 {
+		pipeline = append(pipeline, BannedWords{Words: words})
+	}
+
+	if value := strings.TrimSpace(settings[MaxLinksKey]); value != "" {
+		limit, err := parseLimit(MaxLinksKey, value)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, LinkCount{Max: limit})
+	}
+
+	if value := strings.TrimSpace(settings[MaxRepeatedCharactersKey]); value != "" {
+		limit, err := parseLimit(MaxRepeatedCharactersKey, value)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, RepeatedCharacters{Max: limit})
+	}
+
+	if rules := lines(settings[RegexesKey]); len(rules) > 0 {
+		var patterns []*regexp.Regexp
+		for _, rule := range rules {
+			pattern, err := regexp.Compile(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule %q in %v: %w", rule, RegexesKey, err)
+			}
+			patterns = append(patterns, pattern)
+		}
+		pipeline = append(pipeline, Regexes{Patterns: patterns})
+	}
+
+	return pipeline, nil
+}
+
+// ValidateSetting checks the value of a filter setting before it is stored
+func ValidateSetting(key, value string) error {
+	_, err := NewPipeline(map[string]string{key: value})
+	return err
+}
+
+// lines splits a list setting, which has one entry per line
+func lines(value string) []string {
+	var entries []string
+	for _, line := range strings.Split(value, "\n") {
+		if entry := strings.TrimSpace(line); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func parseLimit(key, value string) (int, error) {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("%v must be a number of at least 0", key)
+	}
+	return limit, nil
+}
@@ -0,0 +1,165 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	MaxAttachments    = 5
+	MaxAttachmentSize = 5 << 20
+
+	// AttachmentPath is where ServeAttachment is mounted, followed by the ID of the attachment
+	AttachmentPath = "/api/attachments/"
+)
+
+// the content type is sniffed from the file, what the client claims is ignored
+var attachmentExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+type preparedAttachment struct {
+	attachment *models.TicketAttachment
+	data       []byte
+}
+
+// prepareAttachments validates the uploads and removes their metadata. The file names are
+// replaced as well, as they often contain the name of the submitter or a timestamp.
+func prepareAttachments(ticketID string, uploads []*graphql.Upload) ([]*preparedAttachment, error) {
+	if len(uploads) > MaxAttachments {
+		return nil, fmt.Errorf("at most %v attachments are allowed", MaxAttachments)
+	}
+
+	var prepared []*preparedAttachment
+	for i, upload := range uploads {
+		if upload.Size > MaxAttachmentSize {
+			return nil, fmt.Errorf("attachment %v exceeds max size of %v MiB", i+1, MaxAttachmentSize>>20)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(upload.File, MaxAttachmentSize+1))
+		if err != nil {
+			log.Printf("Failed to read attachment: %v", err)
+			return nil, ErrInternal
+		}
+		if len(data) > MaxAttachmentSize {
+			return nil, fmt.Errorf("attachment %v exceeds max size of %v MiB", i+1, MaxAttachmentSize>>20)
+		}
+
+		contentType := http.DetectContentType(data)
+		extension, ok := attachmentExtensions[contentType]
+		if !ok {
+			return nil, fmt.Errorf("attachment %v is not a JPEG or PNG image", i+1)
+		}
+
+		stripped, err := storage.StripMetadata(contentType, data)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %v could not be read: %w", i+1, err)
+		}
+
+		prepared = append(prepared, &preparedAttachment{
+			attachment: &models.TicketAttachment{
+				ID:          uuid.New().String(),
+				TicketID:    ticketID,
+				FileName:    fmt.Sprintf("attachment-%d%s", i+1, extension),
+				ContentType: contentType,
+				Size:        int64(len(stripped)),
+				StorageKey:  uuid.New().String(),
+			},
+			data: stripped,
+		})
+	}
+
+	return prepared, nil
+}
+
+// storeAttachments puts the files into the storage, nothing is left behind if one of them fails
+func (r *Resolver) storeAttachments(ctx context.Context, prepared []*preparedAttachment) error {
+	var stored []string
+	for _, p := range prepared {
+		if err := r.Storage.Put(ctx, p.attachment.StorageKey, p.data, p.attachment.ContentType); err != nil {
+			r.deleteStoredAttachments(ctx, stored)
+			return err
+		}
+		stored = append(stored, p.attachment.StorageKey)
+	}
+	return nil
+}
+
+func (r *Resolver) deleteStoredAttachments(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := r.Storage.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete attachment %v from storage: %v", key, err)
+		}
+	}
+}
+
+// ServeAttachment sends an attachment to logged in users who may see its ticket
+func (r *Resolver) ServeAttachment(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	if user, ok := ctx.Value(middleware.UserKey).(*model.User); !ok || user == nil {
+		http.Error(w, "access denied", http.StatusUnauthorized)
+		return
+	}
+
+	id := chi.URLParam(req, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	var attachments []*models.TicketAttachment
+	if err := r.DB.NewSelect().Model(&attachments).
+		Where("ticket_attachment.id = ?", id).
+		Where("ticket_attachment.ticket_id IN (?)", r.DB.NewSelect().Model((*models.Ticket)(nil)).
+			Column("ticket.id").
+			Apply(withoutQuarantine(ctx))).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch attachment %v: %v", id, err)
+		http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(attachments) == 0 {
+		http.NotFound(w, req)
+		return
+	}
+
+	attachment := attachments[0]
+
+	file, err := r.Storage.Get(ctx, attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Printf("Attachment %v is missing in the storage", id)
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read attachment %v from storage: %v", id, err)
+		http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Failed to send attachment %v: %v", id, err)
+	}
+}
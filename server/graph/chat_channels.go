@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"context"
+	"log"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+// setChatChannelLabel binds the channel to the label, an empty id removes the label
+func (r *Resolver) setChatChannelLabel(ctx context.Context, channel *models.ChatChannel, id string) error {
+	channel.LabelID = ""
+	channel.Label = nil
+
+	if id == "" {
+		return nil
+	}
+
+	var labels []*models.Label
+	if err := r.DB.NewSelect().Model(&labels).Where("id = ?", id).Scan(ctx); err != nil {
+		log.Printf("Failed to fetch label %v: %v", id, err)
+		return ErrInternal
+	}
+	if len(labels) == 0 {
+		return ErrNotFound
+	}
+
+	channel.LabelID = labels[0].ID
+	channel.Label = labels[0]
+	return nil
+}
+
+func chatChannelEvents(selected []model.NotificationType) []string {
+	seen := make(map[model.NotificationType]struct{})
+	var result []string
+	for _, e := range selected {
+		if _, ok := seen[e]; ok {
+			continue
+		}
+		seen[e] = struct{}{}
+		result = append(result, string(e))
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+)
+
+const MaxCommentLength = 3000
+
+// commentInput returns the user writing a comment and its trimmed text, or an error if the
+// request is not authenticated or the text is empty or too long
+func commentInput(ctx context.Context, text string) (*model.User, string, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return nil, "", fmt.Errorf("access denied")
+	}
+
+	text = strings.TrimSpace(text)
+	if len(text) == 0 || len(text) > MaxCommentLength {
+		return nil, "", fmt.Errorf("comment cannot be empty, or longer than %v", MaxCommentLength)
+	}
+
+	return user, text, nil
+}
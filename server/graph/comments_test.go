@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+)
+
+func TestCommentInput(t *testing.T) {
+	user := &model.User{ID: "u"}
+	authenticated := context.WithValue(context.Background(), middleware.UserKey, user)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		text     string
+		wantText string
+		wantErr  bool
+	}{
+		{"trimmed", authenticated, "  Beamer geht wieder \n", "Beamer geht wieder", false},
+		{"max length", authenticated, strings.Repeat("a", MaxCommentLength), strings.Repeat("a", MaxCommentLength), false},
+		{"too long", authenticated, strings.Repeat("a", MaxCommentLength+1), "", true},
+		{"empty", authenticated, " \t", "", true},
+		{"unauthenticated", context.Background(), "Beamer geht wieder", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUser, gotText, err := commentInput(tt.ctx, tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (gotUser != user || gotText != tt.wantText) {
+				t.Errorf("got %v %q, want %v %q", gotUser, gotText, user, tt.wantText)
+			}
+		})
+	}
+}
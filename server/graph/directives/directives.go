@@ -6,6 +6,7 @@ import (
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"log"
 )
 
 func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role *model.UserRole) (res interface{}, err error) {
@@ -45,3 +46,14 @@ func OnlySelf(ctx context.Context, obj interface{}, next graphql.Resolver) (res
 
 	return nil, fmt.Errorf("denied: can only update self")
 }
+
+// RateLimit rejects the request once the client exceeded the limit configured for the operation
+func RateLimit(limiter *middleware.RateLimiter) func(ctx context.Context, obj interface{}, next graphql.Resolver, operation string) (res interface{}, err error) {
+	return func(ctx context.Context, obj interface{}, next graphql.Resolver, operation string) (interface{}, error) {
+		if !limiter.Allow(ctx, operation) {
+			log.Printf("Rate limit of %v exceeded", operation)
+			return nil, fmt.Errorf("too many requests, please try again later")
+		}
+		return next(ctx)
+	}
+}
@@ -0,0 +1,292 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/export"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/utils"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	env "github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+	"github.com/uptrace/bun"
+)
+
+// ExportPath is where ticket exports are downloaded, it is part of the API and needs a session
+const ExportPath = "/api/export"
+
+// defaultExportColumns leave out the text, which is only exported when asked for
+var defaultExportColumns = []model.ExportColumn{
+	model.ExportColumnID,
+	model.ExportColumnTitle,
+	model.ExportColumnState,
+	model.ExportColumnLabels,
+	model.ExportColumnAssignee,
+	model.ExportColumnNote,
+	model.ExportColumnSource,
+	model.ExportColumnCreatedAt,
+	model.ExportColumnLastModified,
+}
+
+// exportTicket is a ticket with the names of its labels and assignee, which are
+// selected by subqueries, so that the rows can be streamed one by one
+type exportTicket struct {
+	models.Ticket `bun:",extend"`
+
+	LabelNames   []string `bun:",array,scanonly"`
+	AssigneeName string   `bun:",scanonly"`
+}
+
+// exportURL encodes the export into the query of the export path,
+// the same parameters are read by parseExport
+func exportURL(format model.ExportFormat, columns []model.ExportColumn, filter *model.TicketFilter) string {
+	values := url.Values{}
+	values.Set("format", format.String())
+	for _, c := range columns {
+		values.Add("column", c.String())
+	}
+
+	if filter != nil {
+		values["id"] = filter.Ids
+		for _, s := range filter.States {
+			values.Add("state", s.String())
+		}
+		values["label"] = filter.LabelIDs
+		values["assignee"] = filter.AssigneeIDs
+
+		for key, t := range map[string]*time.Time{
+			"createdAfter":       filter.CreatedAfter,
+			"createdBefore":      filter.CreatedBefore,
+			"lastModifiedAfter":  filter.LastModifiedAfter,
+			"lastModifiedBefore": filter.LastModifiedBefore,
+		} {
+			if t != nil {
+				values.Set(key, t.Format(time.RFC3339Nano))
+			}
+		}
+
+		if filter.Text != nil {
+			values.Set("text", *filter.Text)
+		}
+		if filter.AssignedToMe != nil && *filter.AssignedToMe {
+			values.Set("assignedToMe", "true")
+		}
+	}
+
+	return env.EnvConfig.PublicURL(ExportPath + "?" + values.Encode())
+}
+
+// exportParams returns the non-empty values of a parameter, exportURL repeats parameters with several values
+func exportParams(params []string) []string {
+	var values []string
+	for _, param := range params {
+		if param = strings.TrimSpace(param); param != "" {
+			values = append(values, param)
+		}
+	}
+	return values
+}
+
+// parseExport reads the parameters written by exportURL
+func parseExport(values url.Values) (model.ExportFormat, []model.ExportColumn, *model.TicketFilter, error) {
+	format := model.ExportFormat(strings.ToUpper(values.Get("format")))
+	if !format.IsValid() {
+		return "", nil, nil, fmt.Errorf("invalid export format %q", values.Get("format"))
+	}
+
+	var columns []model.ExportColumn
+	for _, value := range exportParams(values["column"]) {
+		c := model.ExportColumn(strings.ToUpper(value))
+		if !c.IsValid() {
+			return "", nil, nil, fmt.Errorf("invalid export column %q", value)
+		}
+		columns = append(columns, c)
+	}
+	if len(columns) == 0 {
+		columns = defaultExportColumns
+	}
+
+	filter := &model.TicketFilter{
+		Ids:         exportParams(values["id"]),
+		LabelIDs:    exportParams(values["label"]),
+		AssigneeIDs: exportParams(values["assignee"]),
+	}
+
+	for _, value := range exportParams(values["state"]) {
+		s := model.TicketState(strings.ToUpper(value))
+		if !s.IsValid() {
+			return "", nil, nil, fmt.Errorf("invalid ticket state %q", value)
+		}
+		filter.States = append(filter.States, s)
+	}
+
+	for key, t := range map[string]**time.Time{
+		"createdAfter":       &filter.CreatedAfter,
+		"createdBefore":      &filter.CreatedBefore,
+		"lastModifiedAfter":  &filter.LastModifiedAfter,
+		"lastModifiedBefore": &filter.LastModifiedBefore,
+	} {
+		if value := values.Get(key); value != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return "", nil, nil, fmt.Errorf("invalid time %q for %v", value, key)
+			}
+			*t = &parsed
+		}
+	}
+
+	if text := values.Get("text"); text != "" {
+		filter.Text = &text
+	}
+	if values.Get("assignedToMe") == "true" {
+		assignedToMe := true
+		filter.AssignedToMe = &assignedToMe
+	}
+
+	return format, columns, filter, nil
+}
+
+// ServeExport streams the tickets matching the filter of the query to logged in users,
+// see exportURL for the parameters. Rows are written while they are read from the
+// database, errors after the first row can only be logged.
+func (r *Resolver) ServeExport(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	if user, ok := ctx.Value(middleware.UserKey).(*model.User); !ok || user == nil {
+		http.Error(w, "access denied", http.StatusUnauthorized)
+		return
+	}
+
+	format, columns, filter, err := parseExport(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := r.exportQuery(ctx, columns, withAssignedToMe(ctx, filter)).Rows(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch tickets for export: %v", err)
+		http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+
+	fileName := "tickets-" + time.Now().Format("2006-01-02") + export.Extension(string(format))
+	w.Header().Set("Content-Type", export.ContentType(string(format)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	writer, err := export.NewWriter(string(format), w)
+	if err != nil {
+		log.Printf("Failed to start export: %v", err)
+		http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = strings.ToLower(c.String())
+	}
+	if err := writer.WriteHeader(header); err != nil {
+		log.Printf("Failed to write export: %v", err)
+		return
+	}
+
+	for rows.Next() {
+		var ticket exportTicket
+		if err := r.DB.ScanRow(ctx, rows, &ticket); err != nil {
+			log.Printf("Failed to read ticket for export: %v", err)
+			return
+		}
+
+		if err := writer.WriteRow(exportValues(&ticket, columns)); err != nil {
+			log.Printf("Failed to write export: %v", err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to read tickets for export: %v", err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Printf("Failed to write export: %v", err)
+	}
+}
+
+// exportQuery selects only the columns of the export, so that texts are not decrypted without need
+func (r *Resolver) exportQuery(ctx context.Context, columns []model.ExportColumn, filter *model.TicketFilter) *bun.SelectQuery {
+	query := r.DB.NewSelect().Model((*exportTicket)(nil)).
+		Column("ticket.id").
+		Apply(withoutQuarantine(ctx)).
+		Order("ticket.created_at ASC", "ticket.id ASC")
+
+	for _, c := range columns {
+		switch c {
+		case model.ExportColumnTitle:
+			query = query.Column("ticket.title")
+		case model.ExportColumnOriginalTitle:
+			query = query.Column("ticket.original_title")
+		case model.ExportColumnText:
+			query = query.Column("ticket.text")
+		case model.ExportColumnNote:
+			query = query.Column("ticket.note")
+		case model.ExportColumnState:
+			query = query.Column("ticket.state")
+		case model.ExportColumnLabels:
+			query = query.ColumnExpr(`ARRAY(SELECT label.name FROM labels AS label
+				JOIN labels_to_tickets AS ltt ON ltt.label_id = label.id
+				WHERE ltt.ticket_id = ticket.id ORDER BY label.name) AS label_names`)
+		case model.ExportColumnAssignee:
+			query = query.ColumnExpr(`(SELECT trim(u.firstname || ' ' || u.lastname) FROM users AS u
+				WHERE u.id = ticket.assignee_id) AS assignee_name`)
+		case model.ExportColumnSource:
+			query = query.Column("ticket.source")
+		case model.ExportColumnCreatedAt:
+			query = query.Column("ticket.created_at")
+		case model.ExportColumnLastModified:
+			query = query.Column("ticket.last_modified")
+		}
+	}
+
+	return utils.ApplyTicketFilter(query, filter)
+}
+
+func exportValues(t *exportTicket, columns []model.ExportColumn) []any {
+	values := make([]any, len(columns))
+	for i, c := range columns {
+		switch c {
+		case model.ExportColumnID:
+			values[i] = t.ID
+		case model.ExportColumnTitle:
+			values[i] = t.Title
+		case model.ExportColumnOriginalTitle:
+			values[i] = t.OriginalTitle
+		case model.ExportColumnText:
+			values[i] = string(t.Text)
+		case model.ExportColumnNote:
+			values[i] = string(t.Note)
+		case model.ExportColumnState:
+			values[i] = string(t.State)
+		case model.ExportColumnLabels:
+			values[i] = append([]string{}, t.LabelNames...)
+		case model.ExportColumnAssignee:
+			values[i] = t.AssigneeName
+		case model.ExportColumnSource:
+			values[i] = string(t.Source)
+		case model.ExportColumnCreatedAt:
+			values[i] = t.CreatedAt
+		case model.ExportColumnLastModified:
+			values[i] = t.LastModified
+		}
+	}
+	return values
+}
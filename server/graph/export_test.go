@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+)
+
+func TestExportURLRoundTrip(t *testing.T) {
+	after := time.Date(2026, 3, 4, 5, 6, 7, 8, time.UTC)
+	text := "Beamer, kaputt"
+	assignedToMe := true
+
+	filter := &model.TicketFilter{
+		Ids:          []string{"a", "b"},
+		States:       []model.TicketState{model.TicketStateNew, "IN_PROGRESS"},
+		LabelIDs:     []string{"l"},
+		AssigneeIDs:  []string{"u"},
+		CreatedAfter: &after,
+		Text:         &text,
+		AssignedToMe: &assignedToMe,
+	}
+	columns := []model.ExportColumn{model.ExportColumnID, model.ExportColumnTitle}
+
+	u, err := url.Parse(exportURL(model.ExportFormatXlsx, columns, filter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format, gotColumns, gotFilter, err := parseExport(u.Query())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != model.ExportFormatXlsx || !reflect.DeepEqual(gotColumns, columns) {
+		t.Errorf("got %v %v", format, gotColumns)
+	}
+	// the commas of the text must not split it
+	if !reflect.DeepEqual(gotFilter, filter) {
+		t.Errorf("got the filter %+v, want %+v", gotFilter, filter)
+	}
+}
+
+func TestParseExport(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{name: "default columns", query: "format=csv"},
+		{name: "empty values are skipped", query: "format=JSONL&id=&id=a&state="},
+		{name: "unknown format", query: "format=pdf", wantErr: true},
+		{name: "unknown column", query: "format=CSV&column=PASSWORD", wantErr: true},
+		{name: "invalid state", query: "format=CSV&state=new!", wantErr: true},
+		{name: "invalid time", query: "format=CSV&createdAfter=yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			_, columns, filter, err := parseExport(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseExport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (len(columns) == 0 || len(filter.States) != 0 || len(filter.Ids) > 1) {
+				t.Errorf("got columns %v and filter %+v", columns, filter)
+			}
+		})
+	}
+}
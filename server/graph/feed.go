@@ -0,0 +1,252 @@
+package graph
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/auth"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+	"github.com/uptrace/bun"
+)
+
+// FeedPath is where the Atom feed is served, next to the API
+const FeedPath = "/feed"
+
+const feedEntryLimit = 50
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+}
+
+// ServeFeed lists new tickets and state changes as Atom feed. Feed readers cannot log in,
+// so the user is identified by the token in the query, which is revoked by creating a new one.
+// The feed never contains ticket texts, see events.Ticket.
+// The query parameters label and state filter the tickets, both may be repeated.
+func (r *Resolver) ServeFeed(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	query := req.URL.Query()
+	token := query.Get("token")
+	if token == "" {
+		http.Error(w, "access denied", http.StatusUnauthorized)
+		return
+	}
+
+	var users []*models.User
+	if err := r.DB.NewSelect().Model(&users).
+		Where("feed_token_hash = ?", auth.HashFeedToken(token)).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch user of feed token: %v", err)
+		http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(users) == 0 {
+		http.Error(w, "access denied", http.StatusUnauthorized)
+		return
+	}
+
+	// isAdmin and withoutQuarantine read the user from the context
+	ctx = context.WithValue(ctx, middleware.UserKey, toGQLUser(users[0]))
+
+	feed, err := r.feed(ctx, feedValues(query["label"]), feedValues(query["state"]))
+	if err != nil {
+		log.Printf("Failed to build feed: %v", err)
+		http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Printf("Failed to write feed: %v", err)
+	}
+}
+
+// feedValues accepts repeated and comma separated query parameters
+func feedValues(params []string) []string {
+	var values []string
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func (r *Resolver) feed(ctx context.Context, labelNames, states []string) (*atomFeed, error) {
+	var ticketEvents []*models.TicketEvent
+	query := r.DB.NewSelect().Model(&ticketEvents).
+		Relation("Ticket", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Column("id", "title", "state")
+		}).
+		Where("ticket_event.type IN (?)", bun.In([]model.TicketEventType{model.TicketEventTypeCreated, model.TicketEventTypeStateChanged})).
+		// the join leaves out tickets in the trash
+		Where("ticket.id IS NOT NULL").
+		Apply(withoutQuarantine(ctx)).
+		Order("ticket_event.created_at DESC").
+		Limit(feedEntryLimit)
+
+	if !isAdmin(ctx) {
+		query = query.
+			Where("COALESCE(ticket_event.old_value, '') != ?", model.TicketStateQuarantine).
+			Where("COALESCE(ticket_event.new_value, '') != ?", model.TicketStateQuarantine)
+	}
+
+	if len(states) > 0 {
+		query = query.Where("ticket.state IN (?)", bun.In(states))
+	}
+
+	if len(labelNames) > 0 {
+		var lowered []string
+		for _, name := range labelNames {
+			lowered = append(lowered, strings.ToLower(name))
+		}
+
+		query = query.Where("ticket.id IN (?)", r.DB.NewSelect().Model((*models.LabelsToTickets)(nil)).
+			Column("ltt.ticket_id").
+			Join("JOIN labels AS label ON label.id = ltt.label_id").
+			Where("LOWER(label.name) IN (?)", bun.In(lowered)))
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	stateNames, labels, err := r.feedDetails(ctx, ticketEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	stateName := func(key string) string {
+		if name, ok := stateNames[key]; ok {
+			return name
+		}
+		return key
+	}
+
+	feed := &atomFeed{
+		ID:      utils.EnvConfig.PublicURL(FeedPath),
+		Title:   "Kummerkasten",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: "Kummerkasten"},
+		Link:    atomLink{Href: utils.EnvConfig.PublicURL("/tickets")},
+		Entries: []atomEntry{},
+	}
+	if len(ticketEvents) > 0 {
+		feed.Updated = ticketEvents[0].CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	for _, e := range ticketEvents {
+		if e.Ticket == nil {
+			continue
+		}
+
+		entry := atomEntry{
+			ID:      "urn:uuid:" + e.ID,
+			Updated: e.CreatedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: utils.EnvConfig.PublicURL("/tickets/" + e.TicketID)},
+		}
+
+		if e.Type == model.TicketEventTypeCreated {
+			entry.Title = "Neues Ticket: " + e.Ticket.Title
+		} else {
+			entry.Title = fmt.Sprintf("%v: %v → %v", e.Ticket.Title, stateName(e.OldValue), stateName(e.NewValue))
+		}
+
+		summary := "Status: " + stateName(string(e.Ticket.State))
+		if names := labels[e.TicketID]; len(names) > 0 {
+			summary += ", Labels: " + strings.Join(names, ", ")
+			for _, name := range names {
+				entry.Categories = append(entry.Categories, atomCategory{Term: name})
+			}
+		}
+		entry.Summary = summary
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed, nil
+}
+
+// feedDetails returns the names of the states and the label names of the tickets of the events
+func (r *Resolver) feedDetails(ctx context.Context, ticketEvents []*models.TicketEvent) (map[string]string, map[string][]string, error) {
+	var definitions []*models.TicketStateDefinition
+	if err := r.DB.NewSelect().Model(&definitions).Scan(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	stateNames := make(map[string]string)
+	for _, d := range definitions {
+		stateNames[string(d.Key)] = d.Name
+	}
+
+	labels := make(map[string][]string)
+	if len(ticketEvents) == 0 {
+		return stateNames, labels, nil
+	}
+
+	var ticketIDs []string
+	for _, e := range ticketEvents {
+		ticketIDs = append(ticketIDs, e.TicketID)
+	}
+
+	var assignments []*models.LabelsToTickets
+	if err := r.DB.NewSelect().Model(&assignments).
+		Relation("Label").
+		Where("ltt.ticket_id IN (?)", bun.In(ticketIDs)).
+		Order("label.name ASC").
+		Scan(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	for _, a := range assignments {
+		if a.Label != nil {
+			labels[a.TicketID] = append(labels[a.TicketID], a.Label.Name)
+		}
+	}
+
+	return stateNames, labels, nil
+}
@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// newTicketEvent prepares a history entry, the acting user is taken from the session if there is one
+func newTicketEvent(ctx context.Context, ticketID string, eventType model.TicketEventType, oldValue, newValue string) *models.TicketEvent {
+	event := &models.TicketEvent{
+		ID:        uuid.New().String(),
+		TicketID:  ticketID,
+		Type:      eventType,
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		CreatedAt: time.Now(),
+	}
+
+	if user, ok := ctx.Value(middleware.UserKey).(*model.User); ok && user != nil {
+		event.ActorID = user.ID
+	}
+
+	return event
+}
+
+func recordTicketEvents(ctx context.Context, db bun.IDB, events []*models.TicketEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().Model(&events).Exec(ctx)
+	return err
+}
+
+// labelNames maps the label IDs of the assignments to their names, which are
+// stored in the history so that it stays readable after a label is deleted
+func (r *Resolver) labelNames(ctx context.Context, assignments []*model.LabelToTicketAssignment) (map[string]string, error) {
+	names := make(map[string]string)
+	if len(assignments) == 0 {
+		return names, nil
+	}
+
+	var labelIDs []string
+	for _, assignment := range assignments {
+		labelIDs = append(labelIDs, assignment.LabelID)
+	}
+
+	var labels []*models.Label
+	if err := r.DB.NewSelect().Model(&labels).
+		Where("id IN (?)", bun.In(labelIDs)).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, l := range labels {
+		names[l.ID] = l.Name
+	}
+
+	return names, nil
+}
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+// parseMailLabelAliases reads lines like "vorlesung=Lehre" mapping the tag of a
+// plus-address to the name of a label, the tags are lowercased
+func parseMailLabelAliases(value string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for i, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		tag, label, ok := strings.Cut(line, "=")
+		tag, label = strings.ToLower(strings.TrimSpace(tag)), strings.TrimSpace(label)
+		if !ok || tag == "" || label == "" {
+			return nil, fmt.Errorf("line %v: expected tag=label name", i+1)
+		}
+		if strings.ContainsAny(tag, " @+") {
+			return nil, fmt.Errorf("line %v: the tag must not contain spaces, @ or +", i+1)
+		}
+
+		aliases[tag] = label
+	}
+	return aliases, nil
+}
+
+// InboundMailLabels maps the tags of the plus-addresses a mail was sent to, e.g. "lehre" of
+// kummerkasten+lehre@example.org, to label names. Tags without an alias in the setting
+// INBOUND_MAIL_LABELS are matched against the label names, unknown tags are ignored.
+func (r *Resolver) InboundMailLabels(ctx context.Context, tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	var settings []*models.Setting
+	if err := r.DB.NewSelect().Model(&settings).
+		Where("key = ?", models.InboundMailLabelsKey).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch inbound mail label aliases: %v", err)
+		return nil, ErrInternal
+	}
+
+	aliases := map[string]string{}
+	if len(settings) > 0 {
+		var err error
+		if aliases, err = parseMailLabelAliases(settings[0].Value); err != nil {
+			log.Printf("Invalid setting %v, ignoring the aliases: %v", models.InboundMailLabelsKey, err)
+			aliases = map[string]string{}
+		}
+	}
+
+	var labels []*models.Label
+	if err := r.DB.NewSelect().Model(&labels).Scan(ctx); err != nil {
+		log.Printf("Failed to fetch labels: %v", err)
+		return nil, ErrInternal
+	}
+
+	names := make(map[string]string)
+	for _, l := range labels {
+		names[strings.ToLower(l.Name)] = l.Name
+	}
+
+	var result []string
+	seen := make(map[string]struct{})
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if alias, ok := aliases[tag]; ok {
+			tag = strings.ToLower(alias)
+		}
+
+		name, ok := names[tag]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		result = append(result, name)
+	}
+
+	return result, nil
+}
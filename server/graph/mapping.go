@@ -0,0 +1,303 @@
+package graph
+
+import (
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+// Helpers converting database models into their graphql counterparts,
+// shared by resolvers that return the same nested entities.
+
+func toGQLTicket(t *models.Ticket) *model.Ticket {
+	var gqlLabels []*model.Label
+	for _, l := range t.Labels {
+		form := l.FormLabel
+		gqlLabels = append(gqlLabels, &model.Label{
+			ID:        l.ID,
+			Name:      l.Name,
+			FormLabel: &form,
+			Color:     l.Color,
+		})
+	}
+
+	var gqlComments []*model.TicketComment
+	for _, c := range t.Comments {
+		gqlComments = append(gqlComments, toGQLTicketComment(c))
+	}
+
+	var gqlMessages []*model.TicketMessage
+	for _, m := range t.Messages {
+		gqlMessages = append(gqlMessages, toGQLTicketMessage(m))
+	}
+
+	var gqlHistory []*model.TicketEvent
+	for _, e := range t.Events {
+		gqlHistory = append(gqlHistory, toGQLTicketEvent(e))
+	}
+
+	var gqlAttachments []*model.TicketAttachment
+	for _, a := range t.Attachments {
+		gqlAttachments = append(gqlAttachments, toGQLTicketAttachment(a))
+	}
+
+	var gqlMergedTickets []*model.MergedTicket
+	for _, m := range t.MergedTickets {
+		gqlMergedTickets = append(gqlMergedTickets, toGQLMergedTicket(m))
+	}
+
+	var deletedAt *time.Time
+	if !t.DeletedAt.IsZero() {
+		deletedAt = &t.DeletedAt
+	}
+
+	var anonymizedAt *time.Time
+	if !t.AnonymizedAt.IsZero() {
+		anonymizedAt = &t.AnonymizedAt
+	}
+
+	note := string(t.Note)
+
+	return &model.Ticket{
+		ID:            t.ID,
+		OriginalTitle: t.OriginalTitle,
+		Title:         t.Title,
+		Text:          string(t.Text),
+		Note:          &note,
+		State:         t.State,
+		CreatedAt:     t.CreatedAt,
+		LastModified:  t.LastModified,
+		Assignee:      toGQLUser(t.Assignee),
+		Labels:        gqlLabels,
+		Comments:      gqlComments,
+		Messages:      gqlMessages,
+		History:       gqlHistory,
+		Attachments:   gqlAttachments,
+		MergedTickets: gqlMergedTickets,
+		DeletedAt:     deletedAt,
+		AnonymizedAt:  anonymizedAt,
+		Source:        t.Source,
+	}
+}
+
+func toGQLUser(u *models.User) *model.User {
+	if u == nil {
+		return nil
+	}
+
+	var feedTokenCreatedAt *time.Time
+	if !u.FeedTokenCreatedAt.IsZero() {
+		feedTokenCreatedAt = &u.FeedTokenCreatedAt
+	}
+
+	return &model.User{
+		ID:                 u.ID,
+		Mail:               u.Mail,
+		Firstname:          u.Firstname,
+		Lastname:           u.Lastname,
+		Role:               u.Role,
+		CreatedAt:          u.CreatedAt,
+		LastModified:       u.LastModified,
+		LastLogin:          &u.LastLogin,
+		DigestFrequency:    u.DigestFrequency,
+		FeedTokenCreatedAt: feedTokenCreatedAt,
+	}
+}
+
+func toGQLTicketComment(c *models.TicketComment) *model.TicketComment {
+	var history []*model.TicketCommentRevision
+	for _, rev := range c.Revisions {
+		history = append(history, &model.TicketCommentRevision{
+			ID:       rev.ID,
+			Text:     string(rev.Text),
+			EditedAt: rev.EditedAt,
+		})
+	}
+
+	return &model.TicketComment{
+		ID:           c.ID,
+		TicketID:     c.TicketID,
+		Author:       toGQLUser(c.Author),
+		Text:         string(c.Text),
+		CreatedAt:    c.CreatedAt,
+		LastModified: c.LastModified,
+		History:      history,
+	}
+}
+
+func toGQLTicketMessage(m *models.TicketMessage) *model.TicketMessage {
+	message := &model.TicketMessage{
+		ID:            m.ID,
+		TicketID:      m.TicketID,
+		FromSubmitter: m.FromSubmitter,
+		Author:        toGQLUser(m.Author),
+		Text:          string(m.Text),
+		CreatedAt:     m.CreatedAt,
+	}
+
+	if m.MergedTicketID != "" {
+		message.MergedTicketID = &m.MergedTicketID
+	}
+
+	return message
+}
+
+func toGQLTicketEvent(e *models.TicketEvent) *model.TicketEvent {
+	event := &model.TicketEvent{
+		ID:        e.ID,
+		Type:      e.Type,
+		Actor:     toGQLUser(e.Actor),
+		CreatedAt: e.CreatedAt,
+	}
+
+	if e.OldValue != "" {
+		event.OldValue = &e.OldValue
+	}
+	if e.NewValue != "" {
+		event.NewValue = &e.NewValue
+	}
+
+	return event
+}
+
+func toGQLTicketAttachment(a *models.TicketAttachment) *model.TicketAttachment {
+	return &model.TicketAttachment{
+		ID:          a.ID,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        int32(a.Size),
+		CreatedAt:   a.CreatedAt,
+		URL:         AttachmentPath + a.ID,
+	}
+}
+
+func toGQLTicketStateDefinition(s *models.TicketStateDefinition) *model.TicketStateDefinition {
+	transitions := []model.TicketState{}
+	for _, t := range s.Transitions {
+		transitions = append(transitions, t.ToKey)
+	}
+
+	return &model.TicketStateDefinition{
+		Key:         s.Key,
+		Name:        s.Name,
+		Color:       s.Color,
+		Position:    s.Position,
+		Transitions: transitions,
+	}
+}
+
+func toGQLNotificationSubscription(s *models.NotificationSubscription) *model.NotificationSubscription {
+	var label *model.Label
+	if s.Label != nil {
+		form := s.Label.FormLabel
+		label = &model.Label{
+			ID:        s.Label.ID,
+			Name:      s.Label.Name,
+			Color:     s.Label.Color,
+			FormLabel: &form,
+		}
+	}
+
+	return &model.NotificationSubscription{
+		ID:        s.ID,
+		Type:      s.Type,
+		Label:     label,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func toGQLWebhook(w *models.Webhook) *model.Webhook {
+	webhookEvents := []model.WebhookEvent{}
+	for _, e := range w.Events {
+		webhookEvents = append(webhookEvents, model.WebhookEvent(e))
+	}
+
+	return &model.Webhook{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    webhookEvents,
+		Active:    w.Active,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+func toGQLWebhookDelivery(d *models.WebhookDelivery) *model.WebhookDelivery {
+	delivery := &model.WebhookDelivery{
+		ID:        d.ID,
+		WebhookID: d.WebhookID,
+		Event:     d.Event,
+		TicketID:  d.TicketID,
+		Status:    d.Status,
+		Attempts:  int32(d.Attempts),
+		CreatedAt: d.CreatedAt,
+	}
+
+	if d.ResponseStatus != 0 {
+		status := int32(d.ResponseStatus)
+		delivery.ResponseStatus = &status
+	}
+	if d.Error != "" {
+		delivery.Error = &d.Error
+	}
+	if !d.LastAttemptAt.IsZero() {
+		delivery.LastAttemptAt = &d.LastAttemptAt
+	}
+	if !d.NextAttemptAt.IsZero() {
+		delivery.NextAttemptAt = &d.NextAttemptAt
+	}
+
+	return delivery
+}
+
+func toGQLChatChannel(c *models.ChatChannel) *model.ChatChannel {
+	var label *model.Label
+	if c.Label != nil {
+		form := c.Label.FormLabel
+		label = &model.Label{
+			ID:        c.Label.ID,
+			Name:      c.Label.Name,
+			Color:     c.Label.Color,
+			FormLabel: &form,
+		}
+	}
+
+	channelEvents := []model.NotificationType{}
+	for _, e := range c.Events {
+		channelEvents = append(channelEvents, model.NotificationType(e))
+	}
+
+	channel := &model.ChatChannel{
+		ID:        c.ID,
+		Name:      c.Name,
+		Platform:  c.Platform,
+		Label:     label,
+		Events:    channelEvents,
+		Active:    c.Active,
+		CreatedAt: c.CreatedAt,
+	}
+	if c.RoomID != "" {
+		channel.RoomID = &c.RoomID
+	}
+	if c.Platform == model.ChatPlatformMatrix {
+		homeserver := string(c.URL)
+		channel.URL = &homeserver
+	}
+
+	return channel
+}
+
+func toGQLMergedTicket(m *models.MergedTicket) *model.MergedTicket {
+	var createdAt *time.Time
+	if !m.CreatedAt.IsZero() {
+		createdAt = &m.CreatedAt
+	}
+
+	return &model.MergedTicket{
+		ID:            m.ID,
+		OriginalTitle: m.OriginalTitle,
+		Text:          string(m.Text),
+		CreatedAt:     createdAt,
+		MergedAt:      m.MergedAt,
+	}
+}
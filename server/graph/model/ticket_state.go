@@ -0,0 +1,49 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// TicketState is the key of a ticket state. The states and the transitions
+// between them are configured by admins and stored in the database, only
+// the states defined here always exist.
+type TicketState string
+
+const (
+	TicketStateNew    TicketState = "NEW"
+	TicketStateOpen   TicketState = "OPEN"
+	TicketStateClosed TicketState = "CLOSED"
+	// TicketStateQuarantine holds tickets caught by the content filters, which only admins can see
+	TicketStateQuarantine TicketState = "QUARANTINE"
+)
+
+var ticketStateKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,29}$`)
+
+// IsValid only checks the format of the key, not whether the state is configured
+func (e TicketState) IsValid() bool {
+	return ticketStateKeyPattern.MatchString(string(e))
+}
+
+func (e TicketState) String() string {
+	return string(e)
+}
+
+func (e *TicketState) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("ticket states must be strings")
+	}
+
+	*e = TicketState(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TicketState", str)
+	}
+	return nil
+}
+
+func (e TicketState) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
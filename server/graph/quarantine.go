@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/filters"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+func isAdmin(ctx context.Context) bool {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	return ok && user != nil && user.Role == model.UserRoleAdmin
+}
+
+// withoutQuarantine hides quarantined tickets from everyone but admins,
+// it expects the tickets table to be aliased as ticket
+func withoutQuarantine(ctx context.Context) func(q *bun.SelectQuery) *bun.SelectQuery {
+	admin := isAdmin(ctx)
+
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		if admin {
+			return q
+		}
+		return q.Where("ticket.state != ?", model.TicketStateQuarantine)
+	}
+}
+
+// runContentFilters returns why the submission has to be quarantined, nothing if it can be shown.
+// Submissions are let through if the filters cannot be loaded, so that tickets are never lost.
+func (r *Resolver) runContentFilters(ctx context.Context, submission filters.Submission) []string {
+	var settings []*models.Setting
+	if err := r.DB.NewSelect().Model(&settings).
+		Where("key LIKE ?", filters.SettingPrefix+"%").
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch content filter settings: %v", err)
+		return nil
+	}
+
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.Key] = s.Value
+	}
+
+	pipeline, err := filters.NewPipeline(values)
+	if err != nil {
+		log.Printf("Failed to set up content filters: %v", err)
+		return nil
+	}
+
+	reasons := pipeline.Run(submission)
+	if len(reasons) > 0 {
+		log.Printf("Quarantining new ticket: %v", strings.Join(reasons, "; "))
+	}
+
+	return reasons
+}
@@ -6,8 +6,17 @@ package graph
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
-import "github.com/uptrace/bun"
+import (
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/mailer"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/storage"
+	"github.com/uptrace/bun"
+)
 
 type Resolver struct {
-	DB *bun.DB
+	DB      *bun.DB
+	Events  *events.Bus
+	Storage storage.Storage
+	// Mailer is nil if no SMTP server is configured
+	Mailer *mailer.Mailer
 }
@@ -0,0 +1,121 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+// RetentionPolicy decides what happens to closed tickets which were not modified
+// for the given number of days, it is disabled if Days is zero
+type RetentionPolicy struct {
+	Days         int
+	Action       model.RetentionAction
+	ExemptLabels []string
+}
+
+func validateRetentionSetting(key, value string) error {
+	switch key {
+	case models.RetentionDaysKey:
+		if days, err := strconv.Atoi(value); err != nil || days < 0 {
+			return fmt.Errorf("retention has to be a number of days, 0 disables it")
+		}
+	case models.RetentionActionKey:
+		if !model.RetentionAction(value).IsValid() {
+			return fmt.Errorf("retention action must be %v or %v", model.RetentionActionDelete, model.RetentionActionAnonymize)
+		}
+	}
+
+	return nil
+}
+
+// parseLabelNames splits a comma separated list of label names
+func parseLabelNames(value string) []string {
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RetentionPolicy reads the policy from the settings. Invalid settings are an error
+// instead of falling back to defaults, as guessing could delete tickets for good.
+func (r *Resolver) RetentionPolicy(ctx context.Context) (*RetentionPolicy, error) {
+	var settings []*models.Setting
+	if err := r.DB.NewSelect().Model(&settings).
+		Where("key IN (?)", bun.In([]string{models.RetentionDaysKey, models.RetentionActionKey, models.RetentionExemptLabelsKey})).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch retention settings: %v", err)
+		return nil, ErrInternal
+	}
+
+	policy := &RetentionPolicy{
+		Action:       model.RetentionActionAnonymize,
+		ExemptLabels: []string{},
+	}
+
+	for _, s := range settings {
+		if err := validateRetentionSetting(s.Key, s.Value); err != nil {
+			return nil, fmt.Errorf("setting %v: %w", s.Key, err)
+		}
+
+		switch s.Key {
+		case models.RetentionDaysKey:
+			policy.Days, _ = strconv.Atoi(s.Value)
+		case models.RetentionActionKey:
+			policy.Action = model.RetentionAction(s.Value)
+		case models.RetentionExemptLabelsKey:
+			policy.ExemptLabels = parseLabelNames(s.Value)
+		}
+	}
+
+	return policy, nil
+}
+
+// RetentionCandidates returns the IDs of the tickets the policy applies to, least recently modified first
+func (r *Resolver) RetentionCandidates(ctx context.Context, policy *RetentionPolicy) ([]string, error) {
+	ids := []string{}
+	if policy.Days == 0 {
+		return ids, nil
+	}
+
+	query := r.DB.NewSelect().Model((*models.Ticket)(nil)).
+		Column("ticket.id").
+		Where("ticket.state = ?", model.TicketStateClosed).
+		Where("ticket.last_modified < ?", time.Now().AddDate(0, 0, -policy.Days)).
+		Order("ticket.last_modified ASC")
+
+	if policy.Action == model.RetentionActionAnonymize {
+		query.Where("ticket.anonymized_at IS NULL")
+	}
+
+	if len(policy.ExemptLabels) > 0 {
+		// admins type the names into the settings, match them like the ticket form does
+		var lowered []string
+		for _, name := range policy.ExemptLabels {
+			lowered = append(lowered, strings.ToLower(name))
+		}
+
+		query.Where("NOT EXISTS (?)", r.DB.NewSelect().
+			TableExpr("labels_to_tickets AS ltt").
+			Join("JOIN labels AS label ON label.id = ltt.label_id").
+			Where("ltt.ticket_id = ticket.id").
+			Where("LOWER(label.name) IN (?)", bun.In(lowered)))
+	}
+
+	if err := query.Scan(ctx, &ids); err != nil {
+		log.Printf("Failed to fetch tickets due for retention: %v", err)
+		return nil, ErrInternal
+	}
+
+	return ids, nil
+}
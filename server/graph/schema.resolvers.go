@@ -14,180 +14,1000 @@ import (
 	"strings"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/auth"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/chat"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/utils"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
 	env "github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/webhooks"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
 )
 
 // CreateTicket is the resolver for the createTicket field.
 func (r *mutationResolver) CreateTicket(ctx context.Context, ticket model.NewTicket) (*model.Ticket, error) {
-	var labels []*models.Label
+	if err := auth.VerifyChallenge(ticket.Challenge, ticket.ChallengeSolution); err != nil {
+		return nil, err
+	}
+
+	created, err := r.SubmitTicket(ctx, TicketSubmission{
+		Title:       ticket.OriginalTitle,
+		Text:        ticket.Text,
+		Labels:      ticket.Labels,
+		Attachments: ticket.Attachments,
+		Source:      model.TicketSourceForm,
+	})
+	// the challenge is reserved while the ticket is submitted, it is only used up once the ticket exists
+	if created == nil {
+		auth.ReleaseChallenge(ticket.Challenge)
+	}
+
+	return created, err
+}
+
+// DeleteTicket is the resolver for the deleteTicket field.
+func (r *mutationResolver) DeleteTicket(ctx context.Context, ids []string) (int32, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// tickets are soft deleted, maintenance.PurgeTrashedTickets removes them for good
+	var deletedIDs []string
+	if _, err := r.DB.NewDelete().Model((*models.Ticket)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		Returning("id").
+		Exec(ctx, &deletedIDs); err != nil {
+		log.Printf("Failed to delete tickets : %v", err)
+		return 0, ErrInternal
+	}
+
+	for _, id := range deletedIDs {
+		r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: id})
+	}
+
+	return int32(len(deletedIDs)), nil
+}
+
+// RestoreTickets is the resolver for the restoreTickets field.
+func (r *mutationResolver) RestoreTickets(ctx context.Context, ids []string) (int32, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var restoredIDs []string
+	if _, err := r.DB.NewUpdate().Model((*models.Ticket)(nil)).
+		WhereDeleted().
+		Where("id IN (?)", bun.In(ids)).
+		Set("deleted_at = NULL").
+		Returning("id").
+		Exec(ctx, &restoredIDs); err != nil {
+		log.Printf("Failed to restore tickets: %v", err)
+		return 0, ErrInternal
+	}
+
+	for _, id := range restoredIDs {
+		r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: id})
+	}
+
+	return int32(len(restoredIDs)), nil
+}
+
+// UpdateTicket is the resolver for the updateTicket field.
+func (r *mutationResolver) UpdateTicket(ctx context.Context, id string, ticket model.UpdateTicket) (string, error) {
+	var dbTickets []*models.Ticket
+	err := r.DB.NewSelect().
+		Model(&dbTickets).
+		Where("id = ?", id).
+		Scan(ctx)
+
+	if err != nil {
+		log.Printf("Failed to update ticket : %v", err)
+		return "", ErrInternal
+	}
+	if len(dbTickets) == 0 {
+		return "", ErrNotFound
+	}
+
+	dbTicket := dbTickets[0]
+	var historyEvents []*models.TicketEvent
+
+	if ticket.Title != nil {
+		const MaxTitleLength = 70
+		if len(*ticket.Title) > MaxTitleLength {
+			return "", fmt.Errorf("ticket title exceeds max length of %v", MaxTitleLength)
+		}
+
+		title := strings.TrimSpace(*ticket.Title)
+		if title != dbTicket.Title {
+			historyEvents = append(historyEvents, newTicketEvent(ctx, dbTicket.ID, model.TicketEventTypeTitleChanged, dbTicket.Title, title))
+		}
+		dbTicket.Title = title
+	}
+
+	if ticket.State != nil {
+		if err := r.checkStateTransitions(ctx, []model.TicketState{dbTicket.State}, *ticket.State); err != nil {
+			return "", err
+		}
+
+		if *ticket.State != dbTicket.State {
+			historyEvents = append(historyEvents, newTicketEvent(ctx, dbTicket.ID, model.TicketEventTypeStateChanged, string(dbTicket.State), string(*ticket.State)))
+		}
+		dbTicket.State = *ticket.State
+	}
+
+	dbTicket.LastModified = time.Now()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return "", ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.NewUpdate().
+		Model(dbTicket).
+		WherePK().
+		Exec(ctx); err != nil {
+		log.Printf("Failed to update ticket %s: %v", id, err)
+		return "", ErrInternal
+	}
+
+	if err := recordTicketEvents(ctx, tx, historyEvents); err != nil {
+		log.Printf("Failed to record history of ticket %s: %v", id, err)
+		return "", ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit ticket update: %v", err)
+		return "", ErrInternal
+	}
+
+	for _, event := range historyEvents {
+		if event.Type == model.TicketEventTypeStateChanged {
+			r.Events.Publish(events.TicketEvent{
+				Type:     events.TicketStateChanged,
+				TicketID: dbTicket.ID,
+				OldState: model.TicketState(event.OldValue),
+				NewState: model.TicketState(event.NewValue),
+			})
+		}
+	}
+
+	if len(historyEvents) > 0 {
+		r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: dbTicket.ID})
+	}
+
+	return dbTicket.ID, nil
+}
+
+// UpdateTicketState is the resolver for the updateTicketState field.
+func (r *mutationResolver) UpdateTicketState(ctx context.Context, ids []string, state model.TicketState) (int32, error) {
+	var dbTickets []*models.Ticket
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Column("id", "state").
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch tickets for state update: %v", err)
+		return 0, ErrInternal
+	}
+
+	var currentStates []model.TicketState
+	for _, t := range dbTickets {
+		currentStates = append(currentStates, t.State)
+	}
+
+	if err := r.checkStateTransitions(ctx, currentStates, state); err != nil {
+		return 0, err
+	}
+
+	var historyEvents []*models.TicketEvent
+	for _, t := range dbTickets {
+		if t.State != state {
+			historyEvents = append(historyEvents, newTicketEvent(ctx, t.ID, model.TicketEventTypeStateChanged, string(t.State), string(state)))
+		}
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return 0, ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.NewUpdate().Model((*models.Ticket)(nil)).
+		Where("id IN (?)", bun.In(ids)).Set("state = ?", state).
+		Set("last_modified = ?", time.Now()).Exec(ctx)
+
+	if err != nil {
+		log.Printf("Failed to update setting state: %v", err)
+		return 0, ErrInternal
+	}
+
+	if err := recordTicketEvents(ctx, tx, historyEvents); err != nil {
+		log.Printf("Failed to record state changes: %v", err)
+		return 0, ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit state update: %v", err)
+		return 0, ErrInternal
+	}
+
+	for _, event := range historyEvents {
+		r.Events.Publish(
+			events.TicketEvent{
+				Type:     events.TicketStateChanged,
+				TicketID: event.TicketID,
+				OldState: model.TicketState(event.OldValue),
+				NewState: state,
+			},
+			events.TicketEvent{Type: events.TicketUpdated, TicketID: event.TicketID},
+		)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Failed to read affected rows: %v", err)
+		return 0, fmt.Errorf("the ticket states were updated, but counting them failed")
+	}
+
+	return int32(rowsAffected), nil
+}
+
+// CreateTicketStateDefinition is the resolver for the createTicketStateDefinition field.
+func (r *mutationResolver) CreateTicketStateDefinition(ctx context.Context, state model.NewTicketStateDefinition) (*model.TicketStateDefinition, error) {
+	const MaxStateNameLength = 50
+
+	name := strings.TrimSpace(state.Name)
+	if len(name) == 0 || len(name) > MaxStateNameLength {
+		return nil, fmt.Errorf("state name cannot be empty, or longer than %v", MaxStateNameLength)
+	}
+
+	exists, err := r.DB.NewSelect().Model((*models.TicketStateDefinition)(nil)).
+		Where("key = ?", state.Key).
+		WhereOr("LOWER(name) = ?", strings.ToLower(name)).
+		Exists(ctx)
+	if err != nil {
+		log.Printf("Failed to check for existing ticket states: %v", err)
+		return nil, ErrInternal
+	}
+	if exists {
+		return nil, fmt.Errorf("unique constraint error: state with key %v or name %v does already exist", state.Key, name)
+	}
+
+	color, err := stateColor(state.Color, defaultStateColor)
+	if err != nil {
+		return nil, err
+	}
+
+	dbState := &models.TicketStateDefinition{
+		Key:   state.Key,
+		Name:  name,
+		Color: color,
+	}
+
+	if state.Position != nil {
+		dbState.Position = *state.Position
+	}
+
+	if err := r.checkTicketStatesExist(ctx, r.DB, state.Transitions); err != nil {
+		return nil, err
+	}
+
+	for _, to := range state.Transitions {
+		if to != state.Key {
+			dbState.Transitions = append(dbState.Transitions, &models.TicketStateTransition{FromKey: state.Key, ToKey: to})
+		}
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return nil, ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.NewInsert().Model(dbState).Exec(ctx); err != nil {
+		log.Printf("Failed to create ticket state: %v", err)
+		return nil, ErrInternal
+	}
+
+	if len(dbState.Transitions) > 0 {
+		if _, err := tx.NewInsert().Model(&dbState.Transitions).Exec(ctx); err != nil {
+			log.Printf("Failed to create transitions of ticket state: %v", err)
+			return nil, ErrInternal
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit ticket state creation: %v", err)
+		return nil, ErrInternal
+	}
+
+	return toGQLTicketStateDefinition(dbState), nil
+}
+
+// UpdateTicketStateDefinition is the resolver for the updateTicketStateDefinition field.
+func (r *mutationResolver) UpdateTicketStateDefinition(ctx context.Context, key model.TicketState, state model.UpdateTicketStateDefinition) (model.TicketState, error) {
+	var dbStates []*models.TicketStateDefinition
+	if err := r.DB.NewSelect().Model(&dbStates).Where("key = ?", key).Scan(ctx); err != nil {
+		log.Printf("Failed to fetch ticket state %v: %v", key, err)
+		return "", ErrInternal
+	}
+	if len(dbStates) == 0 {
+		return "", ErrNotFound
+	}
+
+	dbState := dbStates[0]
+
+	if state.Name != nil {
+		const MaxStateNameLength = 50
+
+		name := strings.TrimSpace(*state.Name)
+		if len(name) == 0 || len(name) > MaxStateNameLength {
+			return "", fmt.Errorf("state name cannot be empty, or longer than %v", MaxStateNameLength)
+		}
+
+		exists, err := r.DB.NewSelect().Model((*models.TicketStateDefinition)(nil)).
+			Where("LOWER(name) = ?", strings.ToLower(name)).
+			Where("key != ?", key).
+			Exists(ctx)
+		if err != nil {
+			log.Printf("Failed to check for existing ticket state names: %v", err)
+			return "", ErrInternal
+		}
+		if exists {
+			return "", fmt.Errorf("unique constraint error: state with name %v does already exist", name)
+		}
+
+		dbState.Name = name
+	}
+
+	color, err := stateColor(state.Color, dbState.Color)
+	if err != nil {
+		return "", err
+	}
+	dbState.Color = color
+
+	if state.Position != nil {
+		dbState.Position = *state.Position
+	}
+
+	if _, err := r.DB.NewUpdate().Model(dbState).WherePK().Exec(ctx); err != nil {
+		log.Printf("Failed to update ticket state %v: %v", key, err)
+		return "", ErrInternal
+	}
+
+	return dbState.Key, nil
+}
+
+// DeleteTicketStateDefinition is the resolver for the deleteTicketStateDefinition field.
+func (r *mutationResolver) DeleteTicketStateDefinition(ctx context.Context, keys []model.TicketState) (int32, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	for _, key := range keys {
+		if _, builtin := builtinTicketStates[key]; builtin {
+			return 0, fmt.Errorf("the state %v cannot be deleted", key)
+		}
+	}
+
+	inUse, err := r.DB.NewSelect().Model((*models.Ticket)(nil)).
+		WhereAllWithDeleted().
+		Where("state IN (?)", bun.In(keys)).
+		Exists(ctx)
+	if err != nil {
+		log.Printf("Failed to check whether ticket states are in use: %v", err)
+		return 0, ErrInternal
+	}
+	if inUse {
+		return 0, fmt.Errorf("states which are still used by tickets cannot be deleted")
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return 0, ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.NewDelete().Model((*models.TicketStateTransition)(nil)).
+		Where("from_key IN (?)", bun.In(keys)).
+		WhereOr("to_key IN (?)", bun.In(keys)).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to delete transitions of ticket states: %v", err)
+		return 0, ErrInternal
+	}
+
+	result, err := tx.NewDelete().Model((*models.TicketStateDefinition)(nil)).
+		Where("key IN (?)", bun.In(keys)).
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to delete ticket states: %v", err)
+		return 0, ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit ticket state deletion: %v", err)
+		return 0, ErrInternal
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Failed to read affected rows: %v", err)
+		return 0, fmt.Errorf("the states were deleted, but counting them failed")
+	}
+
+	return int32(rowsAffected), nil
+}
+
+// SetTicketStateTransitions is the resolver for the setTicketStateTransitions field.
+func (r *mutationResolver) SetTicketStateTransitions(ctx context.Context, from model.TicketState, to []model.TicketState) (int32, error) {
+	if err := r.checkTicketStatesExist(ctx, r.DB, append([]model.TicketState{from}, to...)); err != nil {
+		return 0, err
+	}
+
+	var transitions []*models.TicketStateTransition
+	seen := make(map[model.TicketState]struct{})
+	for _, target := range to {
+		if _, duplicate := seen[target]; duplicate || target == from {
+			continue
+		}
+		seen[target] = struct{}{}
+		transitions = append(transitions, &models.TicketStateTransition{FromKey: from, ToKey: target})
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return 0, ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.NewDelete().Model((*models.TicketStateTransition)(nil)).
+		Where("from_key = ?", from).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to delete transitions from %v: %v", from, err)
+		return 0, ErrInternal
+	}
+
+	if len(transitions) > 0 {
+		if _, err := tx.NewInsert().Model(&transitions).Exec(ctx); err != nil {
+			log.Printf("Failed to create transitions from %v: %v", from, err)
+			return 0, ErrInternal
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transitions: %v", err)
+		return 0, ErrInternal
+	}
+
+	return int32(len(transitions)), nil
+}
+
+// AssignTicket is the resolver for the assignTicket field.
+func (r *mutationResolver) AssignTicket(ctx context.Context, ids []string, userID string) (int32, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var assignees []*models.User
+	if err := r.DB.NewSelect().Model(&assignees).Where("id = ?", userID).Scan(ctx); err != nil {
+		log.Printf("Failed to fetch assignee %v: %v", userID, err)
+		return 0, ErrInternal
+	}
+	if len(assignees) == 0 {
+		return 0, ErrNotFound
+	}
+
+	return r.setTicketAssignee(ctx, ids, assignees[0])
+}
+
+// UnassignTicket is the resolver for the unassignTicket field.
+func (r *mutationResolver) UnassignTicket(ctx context.Context, ids []string) (int32, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	return r.setTicketAssignee(ctx, ids, nil)
+}
+
+// MergeTickets is the resolver for the mergeTickets field.
+func (r *mutationResolver) MergeTickets(ctx context.Context, targetID string, sourceIDs []string) (*model.Ticket, error) {
+	var ids []string
+	seen := make(map[string]struct{})
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return nil, fmt.Errorf("a ticket cannot be merged into itself")
+		}
+		if _, duplicate := seen[id]; !duplicate {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("at least one ticket to merge is required")
+	}
+
+	var dbTickets []*models.Ticket
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Relation("Labels").
+		Apply(withoutQuarantine(ctx)).
+		Where("ticket.id IN (?)", bun.In(append([]string{targetID}, ids...))).
+		Order("ticket.created_at ASC").
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch tickets to merge: %v", err)
+		return nil, ErrInternal
+	}
 
-	for _, labelName := range ticket.Labels {
-		label := &models.Label{}
-		err := r.DB.NewSelect().
-			Model(label).
-			Where("LOWER(name) = ?", strings.ToLower(labelName)).
-			Limit(1).
-			Scan(ctx)
-		if err != nil {
-			log.Printf("Label not found: %s", labelName)
-			return nil, ErrInternal
+	if len(dbTickets) != len(ids)+1 {
+		return nil, ErrNotFound
+	}
+
+	var target *models.Ticket
+	var sources []*models.Ticket
+	for _, t := range dbTickets {
+		if t.ID == targetID {
+			target = t
+		} else {
+			sources = append(sources, t)
 		}
-		labels = append(labels, label)
 	}
 
-	const MaxTitleLength = 70
-	if len(ticket.OriginalTitle) > MaxTitleLength {
-		return nil, fmt.Errorf("ticket title exceeds max length of %v", MaxTitleLength)
+	targetLabels := make(map[string]struct{})
+	for _, l := range target.Labels {
+		targetLabels[l.ID] = struct{}{}
 	}
 
-	const MaxTextLength = 3000
-	if len(ticket.Text) > MaxTextLength {
-		return nil, fmt.Errorf("ticket text exceeds max length of %v", MaxTextLength)
+	var user *model.User
+	if u, ok := ctx.Value(middleware.UserKey).(*model.User); ok {
+		user = u
 	}
 
-	dbTicket := &models.Ticket{
-		ID:            uuid.New().String(),
-		Text:          ticket.Text,
-		OriginalTitle: strings.TrimSpace(ticket.OriginalTitle),
-		Title:         strings.TrimSpace(ticket.OriginalTitle),
-		State:         model.TicketStateNew,
-		Labels:        labels,
-		CreatedAt:     time.Now(),
-		LastModified:  time.Now(),
+	now := time.Now()
+	notes := []string{}
+	if target.Note != "" {
+		notes = append(notes, string(target.Note))
+	}
+
+	var labelsToTicketsEntries []*models.LabelsToTickets
+	var mergedTickets []*models.MergedTicket
+	var historyEvents []*models.TicketEvent
+	for _, source := range sources {
+		for _, l := range source.Labels {
+			if _, assigned := targetLabels[l.ID]; assigned {
+				continue
+			}
+			targetLabels[l.ID] = struct{}{}
+
+			labelsToTicketsEntries = append(labelsToTicketsEntries, &models.LabelsToTickets{TicketID: targetID, LabelID: l.ID})
+			historyEvents = append(historyEvents, newTicketEvent(ctx, targetID, model.TicketEventTypeLabelAdded, "", l.Name))
+		}
+
+		if source.Note != "" {
+			notes = append(notes, fmt.Sprintf("%s:\n%s", source.Title, source.Note))
+		}
+
+		merged := &models.MergedTicket{
+			ID:               source.ID,
+			TicketID:         targetID,
+			OriginalTitle:    source.OriginalTitle,
+			Text:             source.Text,
+			TrackingCodeHash: source.TrackingCodeHash,
+			CreatedAt:        source.CreatedAt,
+			MergedAt:         now,
+		}
+		if user != nil {
+			merged.MergedByID = user.ID
+		}
+		mergedTickets = append(mergedTickets, merged)
+
+		historyEvents = append(historyEvents, newTicketEvent(ctx, targetID, model.TicketEventTypeMerged, source.ID, source.Title))
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return nil, ErrInternal
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	if _, err := r.DB.NewInsert().Model(dbTicket).Exec(ctx); err != nil {
-		log.Printf("Failed to create Ticket: %v", err)
+	// the conversations with the submitters of the merged tickets stay separate
+	if _, err := tx.NewUpdate().Model((*models.TicketMessage)(nil)).
+		Set("merged_ticket_id = COALESCE(merged_ticket_id, ticket_id)").
+		Where("ticket_id IN (?)", bun.In(ids)).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to keep conversations of merged tickets: %v", err)
 		return nil, ErrInternal
 	}
 
-	gqlTicket := &model.Ticket{
-		ID:            dbTicket.ID,
-		OriginalTitle: dbTicket.Title,
-		Title:         dbTicket.Title,
-		Text:          dbTicket.Text,
-		State:         dbTicket.State,
-		CreatedAt:     dbTicket.CreatedAt,
-		LastModified:  dbTicket.LastModified,
-		Labels:        nil,
+	// comment revisions follow their comments, so only the direct dependents are moved
+	dependents := []interface{}{
+		(*models.TicketComment)(nil),
+		(*models.TicketMessage)(nil),
+		(*models.TicketEvent)(nil),
+		(*models.MergedTicket)(nil),
+		(*models.TicketAttachment)(nil),
 	}
 
-	if len(labels) > 0 {
-		var labelsToTickets []models.LabelsToTickets
-		for _, label := range labels {
-			labelsToTickets = append(labelsToTickets, models.LabelsToTickets{
-				LabelID:  label.ID,
-				TicketID: dbTicket.ID,
-			})
+	for _, dependent := range dependents {
+		if _, err := tx.NewUpdate().Model(dependent).
+			Set("ticket_id = ?", targetID).
+			Where("ticket_id IN (?)", bun.In(ids)).
+			Exec(ctx); err != nil {
+			log.Printf("Failed to move dependents of merged tickets: %v", err)
+			return nil, ErrInternal
 		}
-		if _, err := r.DB.NewInsert().Model(&labelsToTickets).Exec(ctx); err != nil {
-			log.Printf("Failed to link labels to ticket: %v", err)
-			return gqlTicket, fmt.Errorf("the ticket was created but adding labels failed")
+	}
+
+	if len(labelsToTicketsEntries) > 0 {
+		if _, err := tx.NewInsert().Model(&labelsToTicketsEntries).Exec(ctx); err != nil {
+			log.Printf("Failed to add labels of merged tickets: %v", err)
+			return nil, ErrInternal
 		}
 	}
 
-	var gqlLabels []*model.Label
-	for _, l := range dbTicket.Labels {
-		gqlLabels = append(gqlLabels, &model.Label{
-			ID:        l.ID,
-			Name:      l.Name,
-			Color:     l.Color,
-			FormLabel: &l.FormLabel,
-		})
+	if _, err := tx.NewDelete().Model((*models.LabelsToTickets)(nil)).
+		Where("ticket_id IN (?)", bun.In(ids)).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to delete labels of merged tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	if _, err := tx.NewDelete().Model((*models.Ticket)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		ForceDelete().
+		Exec(ctx); err != nil {
+		log.Printf("Failed to delete merged tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	if _, err := tx.NewInsert().Model(&mergedTickets).Exec(ctx); err != nil {
+		log.Printf("Failed to record merged tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	target.Note = models.EncryptedString(strings.Join(notes, "\n\n"))
+	target.LastModified = now
+
+	if _, err := tx.NewUpdate().Model(target).
+		Column("note", "note_tokens", "last_modified").
+		WherePK().
+		Exec(ctx); err != nil {
+		log.Printf("Failed to update merge target: %v", err)
+		return nil, ErrInternal
+	}
+
+	if err := recordTicketEvents(ctx, tx, historyEvents); err != nil {
+		log.Printf("Failed to record ticket history: %v", err)
+		return nil, ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit ticket merge: %v", err)
+		return nil, ErrInternal
 	}
 
-	gqlTicket.Labels = gqlLabels
+	r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: targetID})
+
+	ticket := r.loadTicket(ctx, targetID)
+	if ticket == nil {
+		return nil, ErrInternal
+	}
 
-	return gqlTicket, nil
+	return ticket, nil
 }
 
-// DeleteTicket is the resolver for the deleteTicket field.
-func (r *mutationResolver) DeleteTicket(ctx context.Context, ids []string) (int32, error) {
-	result, err := r.DB.NewDelete().Model((*model.Ticket)(nil)).Where("id IN (?)", bun.In(ids)).Exec(ctx)
+// AddTicketComment is the resolver for the addTicketComment field.
+func (r *mutationResolver) AddTicketComment(ctx context.Context, comment model.NewTicketComment) (*model.TicketComment, error) {
+	user, text, err := commentInput(ctx, comment.Text)
 	if err != nil {
-		log.Printf("Failed to delete tickets : %v", err)
-		return 0, ErrInternal
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	exists, err := r.DB.NewSelect().Model((*models.Ticket)(nil)).Where("id = ?", comment.TicketID).Exists(ctx)
 	if err != nil {
-		log.Printf("Failed to read affected rows: %v", err)
-		return 0, fmt.Errorf("the tickets were deleted, but counting them failed")
+		log.Printf("Failed to look up ticket for comment: %v", err)
+		return nil, ErrInternal
+	}
+	if !exists {
+		return nil, ErrNotFound
 	}
 
-	return int32(rowsAffected), nil
-}
+	now := time.Now()
+	dbComment := &models.TicketComment{
+		ID:           uuid.New().String(),
+		TicketID:     comment.TicketID,
+		AuthorID:     user.ID,
+		Text:         models.EncryptedString(text),
+		CreatedAt:    now,
+		LastModified: now,
+	}
 
-// UpdateTicket is the resolver for the updateTicket field.
-func (r *mutationResolver) UpdateTicket(ctx context.Context, id string, ticket model.UpdateTicket) (string, error) {
-	var dbTickets []*models.Ticket
-	err := r.DB.NewSelect().
-		Model(&dbTickets).
-		Where("id = ?", id).
-		Scan(ctx)
+	if _, err := r.DB.NewInsert().Model(dbComment).Exec(ctx); err != nil {
+		log.Printf("Failed to create ticket comment: %v", err)
+		return nil, ErrInternal
+	}
+
+	gqlComment := toGQLTicketComment(dbComment)
+	gqlComment.Author = user
+
+	r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: dbComment.TicketID})
+
+	return gqlComment, nil
+}
 
+// EditTicketComment is the resolver for the editTicketComment field.
+func (r *mutationResolver) EditTicketComment(ctx context.Context, id string, text string) (string, error) {
+	user, text, err := commentInput(ctx, text)
 	if err != nil {
-		log.Printf("Failed to update ticket : %v", err)
+		return "", err
+	}
+
+	var dbComments []*models.TicketComment
+	if err := r.DB.NewSelect().Model(&dbComments).Where("id = ?", id).Scan(ctx); err != nil {
+		log.Printf("Failed to fetch ticket comment %v: %v", id, err)
 		return "", ErrInternal
 	}
-	if len(dbTickets) == 0 {
+	if len(dbComments) == 0 {
 		return "", ErrNotFound
 	}
 
-	dbTicket := dbTickets[0]
+	dbComment := dbComments[0]
 
-	if ticket.Title != nil {
-		const MaxTitleLength = 70
-		if len(*ticket.Title) > MaxTitleLength {
-			return "", fmt.Errorf("ticket title exceeds max length of %v", MaxTitleLength)
-		}
-		dbTicket.Title = strings.TrimSpace(*ticket.Title)
+	if dbComment.AuthorID != user.ID && user.Role != model.UserRoleAdmin {
+		return "", fmt.Errorf("denied: can only edit own comments")
 	}
 
-	if ticket.State != nil {
-		dbTicket.State = *ticket.State
+	if string(dbComment.Text) == text {
+		return dbComment.ID, nil
 	}
 
-	dbTicket.LastModified = time.Now()
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return "", ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
 
-	if _, err := r.DB.NewUpdate().
-		Model(dbTicket).
-		WherePK().
-		Exec(ctx); err != nil {
-		log.Printf("Failed to update ticket %s: %v", id, err)
+	revision := &models.TicketCommentRevision{
+		ID:        uuid.New().String(),
+		CommentID: dbComment.ID,
+		Text:      dbComment.Text,
+		EditedAt:  time.Now(),
+	}
+
+	if _, err := tx.NewInsert().Model(revision).Exec(ctx); err != nil {
+		log.Printf("Failed to store revision of ticket comment %v: %v", id, err)
 		return "", ErrInternal
 	}
 
-	return dbTicket.ID, nil
+	dbComment.Text = models.EncryptedString(text)
+	dbComment.LastModified = revision.EditedAt
+
+	if _, err := tx.NewUpdate().Model(dbComment).WherePK().Exec(ctx); err != nil {
+		log.Printf("Failed to update ticket comment %v: %v", id, err)
+		return "", ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit ticket comment update: %v", err)
+		return "", ErrInternal
+	}
+
+	r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: dbComment.TicketID})
+
+	return dbComment.ID, nil
 }
 
-// UpdateTicketState is the resolver for the updateTicketState field.
-func (r *mutationResolver) UpdateTicketState(ctx context.Context, ids []string, state model.TicketState) (int32, error) {
-	result, err := r.DB.NewUpdate().Model((*models.Ticket)(nil)).
-		Where("id IN (?)", bun.In(ids)).Set("state = ?", state).
-		Set("last_modified = ?", time.Now()).Exec(ctx)
+// DeleteTicketComment is the resolver for the deleteTicketComment field.
+func (r *mutationResolver) DeleteTicketComment(ctx context.Context, ids []string) (int32, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return 0, fmt.Errorf("access denied")
+	}
+
+	if user.Role != model.UserRoleAdmin {
+		foreignComments, err := r.DB.NewSelect().Model((*models.TicketComment)(nil)).
+			Where("id IN (?)", bun.In(ids)).
+			Where("author_id IS DISTINCT FROM ?", user.ID).
+			Exists(ctx)
+		if err != nil {
+			log.Printf("Failed to check ticket comment authors: %v", err)
+			return 0, ErrInternal
+		}
+		if foreignComments {
+			return 0, fmt.Errorf("denied: can only delete own comments")
+		}
+	}
+
+	var ticketIDs []string
+	if err := r.DB.NewSelect().Model((*models.TicketComment)(nil)).
+		Distinct().
+		Column("ticket_id").
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx, &ticketIDs); err != nil {
+		log.Printf("Failed to fetch tickets of comments: %v", err)
+		return 0, ErrInternal
+	}
+
+	if _, err := r.DB.NewDelete().Model((*models.TicketCommentRevision)(nil)).
+		Where("comment_id IN (?)", bun.In(ids)).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to delete ticket comment revisions: %v", err)
+		return 0, ErrInternal
+	}
 
+	result, err := r.DB.NewDelete().Model((*models.TicketComment)(nil)).Where("id IN (?)", bun.In(ids)).Exec(ctx)
 	if err != nil {
-		log.Printf("Failed to update setting state: %v", err)
+		log.Printf("Failed to delete ticket comments: %v", err)
 		return 0, ErrInternal
 	}
 
+	for _, ticketID := range ticketIDs {
+		r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: ticketID})
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Printf("Failed to read affected rows: %v", err)
-		return 0, fmt.Errorf("the ticket states were updated, but counting them failed")
+		return 0, fmt.Errorf("the comments were deleted, but counting them failed")
 	}
 
 	return int32(rowsAffected), nil
 }
 
+// ReplyToTicket is the resolver for the replyToTicket field.
+func (r *mutationResolver) ReplyToTicket(ctx context.Context, ticketID string, text string, mergedTicketID *string) (*model.TicketMessage, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	text = strings.TrimSpace(text)
+
+	const MaxMessageLength = 3000
+	if len(text) == 0 || len(text) > MaxMessageLength {
+		return nil, fmt.Errorf("message cannot be empty, or longer than %v", MaxMessageLength)
+	}
+
+	if mergedTicketID != nil && *mergedTicketID != "" {
+		exists, err := r.DB.NewSelect().Model((*models.MergedTicket)(nil)).
+			Where("id = ?", *mergedTicketID).
+			Where("ticket_id = ?", ticketID).
+			Exists(ctx)
+		if err != nil {
+			log.Printf("Failed to fetch merged ticket %v: %v", *mergedTicketID, err)
+			return nil, ErrInternal
+		}
+		if !exists {
+			return nil, ErrNotFound
+		}
+	}
+
+	now := time.Now()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return nil, ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.NewUpdate().Model((*models.Ticket)(nil)).
+		Where("id = ?", ticketID).
+		Set("last_modified = ?", now).
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to update ticket %v for reply: %v", ticketID, err)
+		return nil, ErrInternal
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	message := &models.TicketMessage{
+		ID:        uuid.New().String(),
+		TicketID:  ticketID,
+		AuthorID:  user.ID,
+		Text:      models.EncryptedString(text),
+		CreatedAt: now,
+	}
+	if mergedTicketID != nil {
+		message.MergedTicketID = *mergedTicketID
+	}
+
+	if _, err := tx.NewInsert().Model(message).Exec(ctx); err != nil {
+		log.Printf("Failed to create ticket message: %v", err)
+		return nil, ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit reply to ticket %v: %v", ticketID, err)
+		return nil, ErrInternal
+	}
+
+	gqlMessage := toGQLTicketMessage(message)
+	gqlMessage.Author = user
+
+	r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: ticketID})
+
+	return gqlMessage, nil
+}
+
+// ReplyByTrackingCode is the resolver for the replyByTrackingCode field.
+func (r *mutationResolver) ReplyByTrackingCode(ctx context.Context, code string, text string) (*model.TrackedTicketMessage, error) {
+	text = strings.TrimSpace(text)
+
+	const MaxMessageLength = 3000
+	if len(text) == 0 || len(text) > MaxMessageLength {
+		return nil, fmt.Errorf("message cannot be empty, or longer than %v", MaxMessageLength)
+	}
+
+	dbTicket, merged, err := r.ticketByTrackingCode(ctx, code)
+	if err != nil {
+		log.Printf("Failed to fetch ticket by tracking code: %v", err)
+		return nil, ErrInternal
+	}
+	if dbTicket == nil {
+		return nil, ErrNotFound
+	}
+
+	now := time.Now()
+
+	message := &models.TicketMessage{
+		ID:            uuid.New().String(),
+		TicketID:      dbTicket.ID,
+		FromSubmitter: true,
+		Text:          models.EncryptedString(text),
+		CreatedAt:     now,
+	}
+	if merged != nil {
+		message.MergedTicketID = merged.ID
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return nil, ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.NewInsert().Model(message).Exec(ctx); err != nil {
+		log.Printf("Failed to create ticket message from submitter: %v", err)
+		return nil, ErrInternal
+	}
+
+	if _, err := tx.NewUpdate().Model((*models.Ticket)(nil)).
+		Where("id = ?", dbTicket.ID).
+		Set("last_modified = ?", now).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to update LastModified: %v", err)
+		return nil, ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit reply to ticket %v: %v", dbTicket.ID, err)
+		return nil, ErrInternal
+	}
+
+	r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: dbTicket.ID})
+
+	return &model.TrackedTicketMessage{
+		FromSubmitter: true,
+		Text:          string(message.Text),
+		CreatedAt:     message.CreatedAt,
+	}, nil
+}
+
 // CreateLabel is the resolver for the createLabel field.
 func (r *mutationResolver) CreateLabel(ctx context.Context, label model.NewLabel) (*model.Label, error) {
 	const MAXLABELLENGTH = 50
@@ -245,6 +1065,21 @@ func (r *mutationResolver) CreateLabel(ctx context.Context, label model.NewLabel
 
 // DeleteLabel is the resolver for the deleteLabel field.
 func (r *mutationResolver) DeleteLabel(ctx context.Context, ids []string) (int32, error) {
+	if _, err := r.DB.NewDelete().Model((*models.NotificationSubscription)(nil)).
+		Where("label_id IN (?)", bun.In(ids)).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to delete notification subscriptions of labels: %v", err)
+		return 0, ErrInternal
+	}
+
+	// without their label the channels would get all tickets
+	if _, err := r.DB.NewDelete().Model((*models.ChatChannel)(nil)).
+		Where("label_id IN (?)", bun.In(ids)).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to delete chat channels of labels: %v", err)
+		return 0, ErrInternal
+	}
+
 	result, err := r.DB.NewDelete().Model((*model.Label)(nil)).Where("id IN (?)", bun.In(ids)).Exec(ctx)
 	if err != nil {
 		log.Printf("Failed to delete label: %v", err)
@@ -347,38 +1182,89 @@ func (r *mutationResolver) CreateUser(ctx context.Context, user model.NewUser) (
 		LastModified: time.Now(),
 	}
 
-	if _, err := r.DB.NewInsert().Model(newDbUser).Exec(ctx); err != nil {
-		log.Printf("Failed to create user: %v", err)
-		return nil, ErrInternal
+	if _, err := r.DB.NewInsert().Model(newDbUser).Exec(ctx); err != nil {
+		log.Printf("Failed to create user: %v", err)
+		return nil, ErrInternal
+	}
+
+	gqlUser := model.User{
+		ID:              newDbUser.ID,
+		Mail:            newDbUser.Mail,
+		Firstname:       newDbUser.Firstname,
+		Lastname:        newDbUser.Lastname,
+		Role:            newDbUser.Role,
+		CreatedAt:       newDbUser.CreatedAt,
+		LastModified:    newDbUser.LastModified,
+		LastLogin:       &newDbUser.LastLogin,
+		DigestFrequency: model.DigestFrequencyNone,
+	}
+
+	return &gqlUser, nil
+}
+
+// DeleteUser is the resolver for the deleteUser field.
+func (r *mutationResolver) DeleteUser(ctx context.Context, ids []string) (int32, error) {
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("no ids provided to DeleteUser()")
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return 0, ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var assignedTickets []*models.Ticket
+	if err := tx.NewSelect().Model(&assignedTickets).
+		Relation("Assignee").
+		WhereAllWithDeleted().
+		Where("ticket.assignee_id IN (?)", bun.In(ids)).
+		For("UPDATE OF ticket").
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch tickets of deleted users: %v", err)
+		return 0, ErrInternal
 	}
 
-	gqlUser := model.User{
-		ID:           newDbUser.ID,
-		Mail:         newDbUser.Mail,
-		Firstname:    newDbUser.Firstname,
-		Lastname:     newDbUser.Lastname,
-		Role:         newDbUser.Role,
-		CreatedAt:    newDbUser.CreatedAt,
-		LastModified: newDbUser.LastModified,
-		LastLogin:    &newDbUser.LastLogin,
+	// the history keeps who handled the tickets, after the user is gone
+	var historyEvents []*models.TicketEvent
+	for _, t := range assignedTickets {
+		historyEvents = append(historyEvents, newTicketEvent(ctx, t.ID, model.TicketEventTypeAssigneeChanged, fullName(t.Assignee), ""))
 	}
 
-	return &gqlUser, nil
-}
+	if _, err := tx.NewUpdate().Model((*models.Ticket)(nil)).
+		WhereAllWithDeleted().
+		Where("assignee_id IN (?)", bun.In(ids)).
+		Set("assignee_id = NULL").
+		Exec(ctx); err != nil {
+		log.Printf("Failed to unassign tickets of deleted users: %v", err)
+		return 0, ErrInternal
+	}
 
-// DeleteUser is the resolver for the deleteUser field.
-func (r *mutationResolver) DeleteUser(ctx context.Context, ids []string) (int32, error) {
-	if len(ids) == 0 {
-		return 0, fmt.Errorf("no ids provided to DeleteUser()")
+	if err := recordTicketEvents(ctx, tx, historyEvents); err != nil {
+		log.Printf("Failed to record assignee changes of deleted users: %v", err)
+		return 0, ErrInternal
+	}
+
+	if _, err := tx.NewDelete().Model((*models.NotificationSubscription)(nil)).
+		Where("user_id IN (?)", bun.In(ids)).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to delete notification subscriptions of deleted users: %v", err)
+		return 0, ErrInternal
 	}
 
-	result, err := r.DB.NewDelete().Model((*model.User)(nil)).Where("ID IN (?)", bun.In(ids)).Exec(ctx)
+	result, err := tx.NewDelete().Model((*model.User)(nil)).Where("ID IN (?)", bun.In(ids)).Exec(ctx)
 
 	if err != nil {
 		log.Printf("Failed to delete user: %v", err)
 		return 0, ErrInternal
 	}
 
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit user deletion: %v", err)
+		return 0, ErrInternal
+	}
+
 	rowsAffected, _ := result.RowsAffected()
 	return int32(rowsAffected), nil
 }
@@ -542,8 +1428,107 @@ func (r *mutationResolver) Logout(ctx context.Context, sid string) (string, erro
 	return "", nil
 }
 
+// SubscribeToNotifications is the resolver for the subscribeToNotifications field.
+func (r *mutationResolver) SubscribeToNotifications(ctx context.Context, typeArg model.NotificationType, labelID *string) (*model.NotificationSubscription, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	subscription := &models.NotificationSubscription{
+		UserID:    user.ID,
+		Type:      typeArg,
+		CreatedAt: time.Now(),
+	}
+
+	query := r.DB.NewSelect().Model((*models.NotificationSubscription)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("type = ?", typeArg)
+
+	if typeArg == model.NotificationTypeLabel {
+		if labelID == nil {
+			return nil, fmt.Errorf("a label is required for label notifications")
+		}
+
+		var labels []*models.Label
+		if err := r.DB.NewSelect().Model(&labels).
+			Where("id = ?", *labelID).
+			Scan(ctx); err != nil {
+			log.Printf("Failed to fetch label %v: %v", *labelID, err)
+			return nil, ErrInternal
+		}
+		if len(labels) == 0 {
+			return nil, ErrNotFound
+		}
+
+		subscription.LabelID = labels[0].ID
+		subscription.Label = labels[0]
+		query = query.Where("label_id = ?", subscription.LabelID)
+	} else if labelID != nil {
+		return nil, fmt.Errorf("only label notifications have a label")
+	}
+
+	exists, err := query.Exists(ctx)
+	if err != nil {
+		log.Printf("Failed to check for existing notification subscription: %v", err)
+		return nil, ErrInternal
+	}
+	if exists {
+		return nil, fmt.Errorf("already subscribed")
+	}
+
+	if _, err := r.DB.NewInsert().Model(subscription).Returning("id").Exec(ctx); err != nil {
+		log.Printf("Failed to create notification subscription: %v", err)
+		return nil, ErrInternal
+	}
+
+	return toGQLNotificationSubscription(subscription), nil
+}
+
+// UnsubscribeFromNotifications is the resolver for the unsubscribeFromNotifications field.
+func (r *mutationResolver) UnsubscribeFromNotifications(ctx context.Context, ids []string) (int32, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return 0, fmt.Errorf("access denied")
+	}
+
+	result, err := r.DB.NewDelete().Model((*models.NotificationSubscription)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		Where("user_id = ?", user.ID).
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to delete notification subscriptions: %v", err)
+		return 0, ErrInternal
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return int32(rowsAffected), nil
+}
+
+// SetDigestFrequency is the resolver for the setDigestFrequency field.
+func (r *mutationResolver) SetDigestFrequency(ctx context.Context, frequency model.DigestFrequency) (model.DigestFrequency, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return "", fmt.Errorf("access denied")
+	}
+
+	if _, err := r.DB.NewUpdate().Model((*models.User)(nil)).
+		Set("digest_frequency = ?", frequency).
+		Where("id = ?", user.ID).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to set digest frequency of user %v: %v", user.ID, err)
+		return "", ErrInternal
+	}
+
+	return frequency, nil
+}
+
 // CreateSetting is the resolver for the createSetting field.
 func (r *mutationResolver) CreateSetting(ctx context.Context, setting model.NewSetting) (*model.Setting, error) {
+	if err := validateSetting(setting.Key, setting.Value); err != nil {
+		return nil, err
+	}
+
 	insertedSetting := &model.Setting{
 		Value: strings.TrimSpace(setting.Value),
 		Key:   setting.Key,
@@ -574,6 +1559,10 @@ func (r *mutationResolver) DeleteSetting(ctx context.Context, keys []string) (in
 
 // UpdateSetting is the resolver for the updateSetting field.
 func (r *mutationResolver) UpdateSetting(ctx context.Context, setting model.NewSetting) (*model.Setting, error) {
+	if err := validateSetting(setting.Key, setting.Value); err != nil {
+		return nil, err
+	}
+
 	updateSetting := &model.Setting{
 		Key:   setting.Key,
 		Value: setting.Value,
@@ -614,6 +1603,313 @@ func (r *mutationResolver) UpdateAboutSectionText(ctx context.Context, text stri
 	return setting.Value, nil
 }
 
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, webhook model.NewWebhook) (*model.Webhook, error) {
+	if err := webhooks.ValidateURL(webhook.URL); err != nil {
+		return nil, err
+	}
+
+	selectedEvents, err := webhookEvents(webhook.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		log.Printf("Failed to generate webhook secret: %v", err)
+		return nil, ErrInternal
+	}
+
+	dbWebhook := &models.Webhook{
+		URL:    webhook.URL,
+		Secret: models.EncryptedString(secret),
+		Events: selectedEvents,
+		Active: true,
+	}
+
+	if _, err := r.DB.NewInsert().Model(dbWebhook).Returning("*").Exec(ctx); err != nil {
+		log.Printf("Failed to insert webhook: %v", err)
+		return nil, ErrInternal
+	}
+
+	result := toGQLWebhook(dbWebhook)
+	result.Secret = &secret
+
+	return result, nil
+}
+
+// UpdateWebhook is the resolver for the updateWebhook field.
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, id string, webhook model.UpdateWebhook) (*model.Webhook, error) {
+	dbWebhook := &models.Webhook{}
+	if err := r.DB.NewSelect().Model(dbWebhook).Where("id = ?", id).Scan(ctx); err != nil {
+		log.Printf("Failed to find webhook with id %v: %v", id, err)
+		return nil, ErrNotFound
+	}
+
+	if webhook.URL != nil {
+		if err := webhooks.ValidateURL(*webhook.URL); err != nil {
+			return nil, err
+		}
+		dbWebhook.URL = *webhook.URL
+	}
+
+	if webhook.Events != nil {
+		selectedEvents, err := webhookEvents(webhook.Events)
+		if err != nil {
+			return nil, err
+		}
+		dbWebhook.Events = selectedEvents
+	}
+
+	if webhook.Active != nil {
+		dbWebhook.Active = *webhook.Active
+	}
+
+	if _, err := r.DB.NewUpdate().Model(dbWebhook).
+		Column("url", "events", "active").
+		WherePK().
+		Exec(ctx); err != nil {
+		log.Printf("Failed to update webhook %v: %v", id, err)
+		return nil, ErrInternal
+	}
+
+	return toGQLWebhook(dbWebhook), nil
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, ids []string) (int32, error) {
+	var rowsAffected int64
+	err := r.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*models.WebhookDelivery)(nil)).
+			Where("webhook_id IN (?)", bun.In(ids)).
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		result, err := tx.NewDelete().Model((*models.Webhook)(nil)).
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to delete webhooks: %v", err)
+		return 0, ErrInternal
+	}
+
+	return int32(rowsAffected), nil
+}
+
+// RedeliverWebhookDeliveries is the resolver for the redeliverWebhookDeliveries field.
+func (r *mutationResolver) RedeliverWebhookDeliveries(ctx context.Context, ids []string) (int32, error) {
+	result, err := r.DB.NewUpdate().Model((*models.WebhookDelivery)(nil)).
+		Set("status = ?", model.WebhookDeliveryStatusPending).
+		Set("attempts = 0").
+		Set("next_attempt_at = now()").
+		Where("id IN (?)", bun.In(ids)).
+		Where("status != ?", model.WebhookDeliveryStatusPending).
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to requeue webhook deliveries: %v", err)
+		return 0, ErrInternal
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Failed to read affected rows: %v", err)
+		return 0, fmt.Errorf("the deliveries were queued, but counting them failed")
+	}
+
+	return int32(rowsAffected), nil
+}
+
+// CreateFeedToken is the resolver for the createFeedToken field.
+func (r *mutationResolver) CreateFeedToken(ctx context.Context) (string, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return "", fmt.Errorf("access denied")
+	}
+
+	token, hash, err := auth.NewFeedToken()
+	if err != nil {
+		log.Printf("Failed to generate feed token: %v", err)
+		return "", ErrInternal
+	}
+
+	if _, err := r.DB.NewUpdate().Model((*models.User)(nil)).
+		Set("feed_token_hash = ?", hash).
+		Set("feed_token_created_at = ?", time.Now()).
+		Where("id = ?", user.ID).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to save feed token of user %v: %v", user.ID, err)
+		return "", ErrInternal
+	}
+
+	return env.EnvConfig.PublicURL(FeedPath + "?token=" + token), nil
+}
+
+// RevokeFeedToken is the resolver for the revokeFeedToken field.
+func (r *mutationResolver) RevokeFeedToken(ctx context.Context) (bool, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return false, fmt.Errorf("access denied")
+	}
+
+	if _, err := r.DB.NewUpdate().Model((*models.User)(nil)).
+		Set("feed_token_hash = NULL").
+		Set("feed_token_created_at = NULL").
+		Where("id = ?", user.ID).
+		Exec(ctx); err != nil {
+		log.Printf("Failed to revoke feed token of user %v: %v", user.ID, err)
+		return false, ErrInternal
+	}
+
+	return true, nil
+}
+
+// ExportTickets is the resolver for the exportTickets field.
+func (r *mutationResolver) ExportTickets(ctx context.Context, format model.ExportFormat, columns []model.ExportColumn, filter *model.TicketFilter) (string, error) {
+	if !format.IsValid() {
+		return "", fmt.Errorf("invalid export format")
+	}
+	for _, c := range columns {
+		if !c.IsValid() {
+			return "", fmt.Errorf("invalid export column")
+		}
+	}
+
+	return exportURL(format, columns, filter), nil
+}
+
+// ImportTickets is the resolver for the importTickets field.
+func (r *mutationResolver) ImportTickets(ctx context.Context, file graphql.Upload, format model.ImportFormat, dryRun *bool) (*model.TicketImportReport, error) {
+	if !format.IsValid() {
+		return nil, fmt.Errorf("invalid import format")
+	}
+
+	return r.ImportTicketFile(ctx, format.String(), file.File, dryRun != nil && *dryRun)
+}
+
+// CreateChatChannel is the resolver for the createChatChannel field.
+func (r *mutationResolver) CreateChatChannel(ctx context.Context, channel model.NewChatChannel) (*model.ChatChannel, error) {
+	dbChannel := &models.ChatChannel{
+		Name:     strings.TrimSpace(channel.Name),
+		Platform: channel.Platform,
+		URL:      models.EncryptedString(channel.URL),
+		Events:   chatChannelEvents(channel.Events),
+		Active:   true,
+	}
+	if channel.RoomID != nil {
+		dbChannel.RoomID = *channel.RoomID
+	}
+	if channel.AccessToken != nil {
+		dbChannel.AccessToken = models.EncryptedString(*channel.AccessToken)
+	}
+	if channel.LabelID != nil {
+		if err := r.setChatChannelLabel(ctx, dbChannel, *channel.LabelID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := chat.ValidateChannel(dbChannel); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.DB.NewInsert().Model(dbChannel).Returning("id, created_at").Exec(ctx); err != nil {
+		log.Printf("Failed to insert chat channel: %v", err)
+		return nil, ErrInternal
+	}
+
+	return toGQLChatChannel(dbChannel), nil
+}
+
+// UpdateChatChannel is the resolver for the updateChatChannel field.
+func (r *mutationResolver) UpdateChatChannel(ctx context.Context, id string, channel model.UpdateChatChannel) (*model.ChatChannel, error) {
+	dbChannel := &models.ChatChannel{}
+	if err := r.DB.NewSelect().Model(dbChannel).Relation("Label").Where("chat_channel.id = ?", id).Scan(ctx); err != nil {
+		log.Printf("Failed to find chat channel with id %v: %v", id, err)
+		return nil, ErrNotFound
+	}
+
+	if channel.Name != nil {
+		dbChannel.Name = strings.TrimSpace(*channel.Name)
+	}
+	if channel.URL != nil {
+		dbChannel.URL = models.EncryptedString(*channel.URL)
+	}
+	if channel.RoomID != nil {
+		dbChannel.RoomID = *channel.RoomID
+	}
+	if channel.AccessToken != nil {
+		dbChannel.AccessToken = models.EncryptedString(*channel.AccessToken)
+	}
+	if channel.LabelID != nil {
+		if err := r.setChatChannelLabel(ctx, dbChannel, *channel.LabelID); err != nil {
+			return nil, err
+		}
+	}
+	if channel.Events != nil {
+		dbChannel.Events = chatChannelEvents(channel.Events)
+	}
+	if channel.Active != nil {
+		dbChannel.Active = *channel.Active
+	}
+
+	if err := chat.ValidateChannel(dbChannel); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.DB.NewUpdate().Model(dbChannel).
+		Column("name", "url", "room_id", "access_token", "label_id", "events", "active").
+		WherePK().
+		Exec(ctx); err != nil {
+		log.Printf("Failed to update chat channel %v: %v", id, err)
+		return nil, ErrInternal
+	}
+
+	return toGQLChatChannel(dbChannel), nil
+}
+
+// DeleteChatChannels is the resolver for the deleteChatChannels field.
+func (r *mutationResolver) DeleteChatChannels(ctx context.Context, ids []string) (int32, error) {
+	result, err := r.DB.NewDelete().Model((*models.ChatChannel)(nil)).Where("id IN (?)", bun.In(ids)).Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to delete chat channels: %v", err)
+		return 0, ErrInternal
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Failed to read affected rows: %v", err)
+		return 0, fmt.Errorf("the chat channels were deleted, but counting them failed")
+	}
+
+	return int32(rowsAffected), nil
+}
+
+// TestChatChannel is the resolver for the testChatChannel field.
+func (r *mutationResolver) TestChatChannel(ctx context.Context, id string) (bool, error) {
+	dbChannel := &models.ChatChannel{}
+	if err := r.DB.NewSelect().Model(dbChannel).Where("id = ?", id).Scan(ctx); err != nil {
+		log.Printf("Failed to find chat channel with id %v: %v", id, err)
+		return false, ErrNotFound
+	}
+
+	if err := chat.Send(ctx, dbChannel, chat.Message{
+		Headline: "Test",
+		Title:    "Der Kummerkasten kann in " + dbChannel.Name + " schreiben",
+		URL:      env.EnvConfig.PublicURL("/tickets"),
+	}); err != nil {
+		return false, fmt.Errorf("sending the test message failed: %w", err)
+	}
+
+	return true, nil
+}
+
 // AddLabelToTicket is the resolver for the addLabelToTicket field.
 func (r *mutationResolver) AddLabelToTicket(ctx context.Context, assignments []*model.LabelToTicketAssignment) (int32, error) {
 	var labelsToTicketsEntries []*models.LabelsToTickets
@@ -639,17 +1935,46 @@ func (r *mutationResolver) AddLabelToTicket(ctx context.Context, assignments []*
 		}
 	}
 
-	result, err := r.DB.NewInsert().Model(&labelsToTicketsEntries).Exec(ctx)
+	labelNames, err := r.labelNames(ctx, assignments)
+	if err != nil {
+		log.Printf("Failed to fetch label names for ticket history: %v", err)
+		return 0, ErrInternal
+	}
+
+	var historyEvents []*models.TicketEvent
+	for _, assignment := range assignments {
+		historyEvents = append(historyEvents, newTicketEvent(ctx, assignment.TicketID, model.TicketEventTypeLabelAdded, "", labelNames[assignment.LabelID]))
+	}
+
+	var rowsAffected int64
+	err = r.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewInsert().Model(&labelsToTicketsEntries).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := recordTicketEvents(ctx, tx, historyEvents); err != nil {
+			return err
+		}
 
+		rowsAffected, err = result.RowsAffected()
+		return err
+	})
 	if err != nil {
 		log.Printf("Failed to add labels to tickets: %v", err)
 		return 0, ErrInternal
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Printf("Failed to read affected rows: %v", err)
-		return 0, fmt.Errorf("the labels were added to the tickets, but counting them failed")
+	addedLabels := make(map[string][]string)
+	for _, assignment := range assignments {
+		addedLabels[assignment.TicketID] = append(addedLabels[assignment.TicketID], assignment.LabelID)
+	}
+
+	for ticketID, labelIDs := range addedLabels {
+		r.Events.Publish(
+			events.TicketEvent{Type: events.TicketLabelled, TicketID: ticketID, LabelIDs: labelIDs},
+			events.TicketEvent{Type: events.TicketUpdated, TicketID: ticketID},
+		)
 	}
 
 	return int32(rowsAffected), nil
@@ -659,31 +1984,57 @@ func (r *mutationResolver) AddLabelToTicket(ctx context.Context, assignments []*
 func (r *mutationResolver) RemoveLabelFromTicket(ctx context.Context, assignments []*model.LabelToTicketAssignment) (int32, error) {
 	updatedTickets := make(map[string]struct{})
 	var rowsAffected int64
+	var historyEvents []*models.TicketEvent
+
+	labelNames, err := r.labelNames(ctx, assignments)
+	if err != nil {
+		log.Printf("Failed to fetch label names for ticket history: %v", err)
+		return 0, ErrInternal
+	}
 
 	for _, assignment := range assignments {
 		if assignment.TicketID == "" || assignment.LabelID == "" {
 			return 0, fmt.Errorf("ticketId and labelId cannot be empty")
 		}
+		updatedTickets[assignment.TicketID] = struct{}{}
+	}
 
-		result, err := r.DB.NewDelete().Model(&models.LabelsToTickets{}).
-			Where("ticket_id = ?", assignment.TicketID).
-			Where("label_id = ?", assignment.LabelID).
-			Exec(ctx)
+	err = r.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, assignment := range assignments {
+			result, err := tx.NewDelete().Model(&models.LabelsToTickets{}).
+				Where("ticket_id = ?", assignment.TicketID).
+				Where("label_id = ?", assignment.LabelID).
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("removing label %s from ticket %s: %w", assignment.LabelID, assignment.TicketID, err)
+			}
 
-		if err != nil {
-			log.Printf("Failed to remove label '%s' from ticket '%s': %v", assignment.LabelID, assignment.TicketID, err)
-			return int32(rowsAffected), ErrInternal
-		}
+			removalRowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
 
-		removalRowsAffected, err := result.RowsAffected()
-		if err != nil {
-			log.Printf("Failed to read affected rows: %v", err)
-			return int32(rowsAffected), err
+			rowsAffected += removalRowsAffected
+
+			if removalRowsAffected > 0 {
+				historyEvents = append(historyEvents, newTicketEvent(ctx, assignment.TicketID, model.TicketEventTypeLabelRemoved, labelNames[assignment.LabelID], ""))
+			}
 		}
 
-		rowsAffected = removalRowsAffected + rowsAffected
+		return recordTicketEvents(ctx, tx, historyEvents)
+	})
+	if err != nil {
+		log.Printf("Failed to remove labels from tickets: %v", err)
+		return 0, ErrInternal
+	}
+
+	changedTickets := make(map[string]struct{})
+	for _, event := range historyEvents {
+		changedTickets[event.TicketID] = struct{}{}
+	}
 
-		updatedTickets[assignment.TicketID] = struct{}{}
+	for ticketID := range changedTickets {
+		r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: ticketID})
 	}
 
 	for ticketID := range updatedTickets {
@@ -1008,18 +2359,17 @@ func (r *mutationResolver) UpdateQuestionAnswerPairBatchPositions(ctx context.Co
 }
 
 // Tickets is the resolver for the tickets field.
-func (r *queryResolver) Tickets(ctx context.Context, id []string, state []model.TicketState) ([]*model.Ticket, error) {
+func (r *queryResolver) Tickets(ctx context.Context, id []string, state []model.TicketState, assignee []string, assignedToMe *bool) ([]*model.Ticket, error) {
 	var dbTickets []*models.Ticket
 
-	query := r.DB.NewSelect().Model(&dbTickets).Relation("Labels")
-
-	if len(id) > 0 {
-		query = query.Where("ticket.id IN (?)", bun.In(id))
-	}
+	filter := withAssignedToMe(ctx, &model.TicketFilter{
+		Ids:          id,
+		States:       state,
+		AssigneeIDs:  assignee,
+		AssignedToMe: assignedToMe,
+	})
 
-	if len(state) > 0 {
-		query = query.Where("ticket.state IN (?)", bun.In(state))
-	}
+	query := utils.ApplyTicketFilter(r.DB.NewSelect().Model(&dbTickets).Apply(withTicketRelations).Apply(withoutQuarantine(ctx)), filter)
 
 	if err := query.Scan(ctx); err != nil {
 		log.Printf("Failed to get tickets: %v", err)
@@ -1028,38 +2378,314 @@ func (r *queryResolver) Tickets(ctx context.Context, id []string, state []model.
 
 	var gqlTickets []*model.Ticket
 	for _, t := range dbTickets {
-		var gqlLabels []*model.Label
-		for _, l := range t.Labels {
-			form := l.FormLabel
-			gqlLabels = append(gqlLabels, &model.Label{
-				ID:        l.ID,
-				Name:      l.Name,
-				FormLabel: &form,
-				Color:     l.Color,
-			})
+		gqlTickets = append(gqlTickets, toGQLTicket(t))
+	}
+
+	return gqlTickets, nil
+}
+
+// SearchTickets is the resolver for the searchTickets field.
+func (r *queryResolver) SearchTickets(ctx context.Context, query string, limit *int32) ([]*model.TicketSearchResult, error) {
+	const DefaultSearchLimit = 20
+	const MaxSearchLimit = 100
+
+	resultLimit := DefaultSearchLimit
+	if limit != nil {
+		if *limit < 1 || *limit > MaxSearchLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %v", MaxSearchLimit)
+		}
+		resultLimit = int(*limit)
+	}
+
+	results := []*model.TicketSearchResult{}
+
+	if strings.TrimSpace(query) == "" {
+		return results, nil
+	}
+
+	groups, err := parseSearchQuery(query)
+	if err != nil {
+		log.Printf("Failed to parse search query: %v", err)
+		return nil, ErrInternal
+	}
+	if len(groups) == 0 {
+		return results, nil
+	}
+
+	var hits []struct {
+		ID             string
+		Rank           float64
+		TitleHighlight string
+	}
+
+	// the texts are encrypted, so they are matched by their blind indexes and the excerpts are built after decrypting.
+	// Matches in the text add to the rank of the title, less than a match in the title itself.
+	tokens := pgdialect.Array(searchTokens(groups))
+	if err := r.DB.NewSelect().
+		TableExpr("tickets AS ticket").
+		TableExpr("websearch_to_tsquery('german', ?) AS query", query).
+		ColumnExpr("ticket.id").
+		ColumnExpr(`ts_rank(ticket.title_search_vector, query)
+			+ 0.1::float8 * (SELECT count(*) FROM unnest(?::varchar[]) AS token WHERE token = ANY(ticket.text_tokens)) / GREATEST(cardinality(?::varchar[]), 1)
+			+ 0.05::float8 * (SELECT count(*) FROM unnest(?::varchar[]) AS token WHERE token = ANY(ticket.note_tokens)) / GREATEST(cardinality(?::varchar[]), 1)
+			AS rank`, tokens, tokens, tokens, tokens).
+		ColumnExpr("ts_headline('german', ticket.title, query, ?) AS title_highlight", titleHeadlineOptions).
+		Apply(whereSearchMatches(groups)).
+		Where("ticket.deleted_at IS NULL").
+		Apply(withoutQuarantine(ctx)).
+		OrderExpr("rank DESC, ticket.id").
+		Limit(resultLimit).
+		Scan(ctx, &hits); err != nil {
+		log.Printf("Failed to search tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	if len(hits) == 0 {
+		return results, nil
+	}
+
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+
+	var dbTickets []*models.Ticket
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Apply(withTicketRelations).
+		Where("ticket.id IN (?)", bun.In(ids)).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to get tickets of search results: %v", err)
+		return nil, ErrInternal
+	}
+
+	ticketsByID := make(map[string]*models.Ticket, len(dbTickets))
+	for _, t := range dbTickets {
+		ticketsByID[t.ID] = t
+	}
+
+	for _, hit := range hits {
+		t, ok := ticketsByID[hit.ID]
+		if !ok {
+			continue
 		}
 
-		gqlTickets = append(gqlTickets, &model.Ticket{
-			ID:            t.ID,
-			OriginalTitle: t.OriginalTitle,
-			Title:         t.Title,
-			Text:          t.Text,
-			Note:          &t.Note,
-			State:         t.State,
-			CreatedAt:     t.CreatedAt,
-			LastModified:  t.LastModified,
-			Labels:        gqlLabels,
+		results = append(results, &model.TicketSearchResult{
+			Ticket:         toGQLTicket(t),
+			Rank:           hit.Rank,
+			TitleHighlight: markHighlights(hit.TitleHighlight),
+			TextHighlight:  textExcerpt(string(t.Text), query),
 		})
 	}
 
-	return gqlTickets, nil
+	return results, nil
+}
+
+// SimilarTickets is the resolver for the similarTickets field.
+func (r *queryResolver) SimilarTickets(ctx context.Context, id string, limit *int32) ([]*model.SimilarTicket, error) {
+	const DefaultSimilarLimit = 5
+	const MaxSimilarLimit = 50
+
+	resultLimit := DefaultSimilarLimit
+	if limit != nil {
+		if *limit < 1 || *limit > MaxSimilarLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %v", MaxSimilarLimit)
+		}
+		resultLimit = int(*limit)
+	}
+
+	exists, err := r.DB.NewSelect().Model((*models.Ticket)(nil)).
+		Apply(withoutQuarantine(ctx)).
+		Where("ticket.id = ?", id).
+		Exists(ctx)
+	if err != nil {
+		log.Printf("Failed to check for ticket %v: %v", id, err)
+		return nil, ErrInternal
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var hits []struct {
+		ID         string
+		Similarity float64
+	}
+
+	// candidates share trigrams of the title, found by the % operator and pg_trgm.similarity_threshold,
+	// or words of the text, found by the blind indexes. The texts are compared by the share of words
+	// they have in common, which is held to the same threshold.
+	if err := r.DB.NewSelect().
+		TableExpr("tickets AS ticket").
+		Join("JOIN tickets AS original ON original.id = ?", id).
+		Join(`JOIN LATERAL (SELECT count(*) AS shared FROM unnest(ticket.text_tokens) AS token
+			WHERE token = ANY(original.text_tokens)) AS words ON true`).
+		ColumnExpr("ticket.id").
+		ColumnExpr("GREATEST(similarity(ticket.title, original.title), ?) AS similarity", bun.Safe(textSimilarity)).
+		Where("ticket.id != original.id").
+		Where("ticket.deleted_at IS NULL").
+		Apply(withoutQuarantine(ctx)).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				Where("ticket.title % original.title").
+				WhereOr("ticket.text_tokens && original.text_tokens AND ? >= show_limit()", bun.Safe(textSimilarity))
+		}).
+		OrderExpr("similarity DESC, ticket.id").
+		Limit(resultLimit).
+		Scan(ctx, &hits); err != nil {
+		log.Printf("Failed to find similar tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	results := []*model.SimilarTicket{}
+	if len(hits) == 0 {
+		return results, nil
+	}
+
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+
+	var dbTickets []*models.Ticket
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Apply(withTicketRelations).
+		Where("ticket.id IN (?)", bun.In(ids)).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to get similar tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	ticketsByID := make(map[string]*models.Ticket, len(dbTickets))
+	for _, t := range dbTickets {
+		ticketsByID[t.ID] = t
+	}
+
+	for _, hit := range hits {
+		t, ok := ticketsByID[hit.ID]
+		if !ok {
+			continue
+		}
+
+		results = append(results, &model.SimilarTicket{
+			Ticket:     toGQLTicket(t),
+			Similarity: hit.Similarity,
+		})
+	}
+
+	return results, nil
+}
+
+// PaginatedTickets is the resolver for the paginatedTickets field.
+func (r *queryResolver) PaginatedTickets(ctx context.Context, filter *model.TicketFilter, sort *model.TicketSort, first *int32, after *string) (*model.TicketConnection, error) {
+	const DefaultPageSize = 25
+	const MaxPageSize = 100
+
+	pageSize := DefaultPageSize
+	if first != nil {
+		if *first < 1 || *first > MaxPageSize {
+			return nil, fmt.Errorf("first must be between 1 and %v", MaxPageSize)
+		}
+		pageSize = int(*first)
+	}
+
+	sortField := model.TicketSortFieldCreatedAt
+	direction := model.SortDirectionDesc
+	if sort != nil {
+		sortField = sort.Field
+		if sort.Direction != nil {
+			direction = *sort.Direction
+		}
+	}
+
+	sortColumns := map[model.TicketSortField]string{
+		model.TicketSortFieldCreatedAt:    "ticket.created_at",
+		model.TicketSortFieldLastModified: "ticket.last_modified",
+		model.TicketSortFieldTitle:        "ticket.title",
+	}
+
+	column, ok := sortColumns[sortField]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field %v", sortField)
+	}
+
+	comparator := "<"
+	if direction == model.SortDirectionAsc {
+		comparator = ">"
+	}
+
+	filter = withAssignedToMe(ctx, filter)
+
+	totalCount, err := utils.ApplyTicketFilter(r.DB.NewSelect().Model((*models.Ticket)(nil)).Apply(withoutQuarantine(ctx)), filter).Count(ctx)
+	if err != nil {
+		log.Printf("Failed to count tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	var dbTickets []*models.Ticket
+
+	query := utils.ApplyTicketFilter(r.DB.NewSelect().Model(&dbTickets).Apply(withTicketRelations).Apply(withoutQuarantine(ctx)), filter)
+
+	if after != nil {
+		cursor, err := utils.DecodeCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+
+		var cursorValue interface{} = cursor.Value
+		if sortField != model.TicketSortFieldTitle {
+			cursorTime, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			cursorValue = cursorTime
+		}
+
+		query = query.Where(fmt.Sprintf("(%s, ticket.id) %s (?, ?)", column, comparator), cursorValue, cursor.ID)
+	}
+
+	if err := query.
+		OrderExpr(fmt.Sprintf("%s %s, ticket.id %s", column, direction, direction)).
+		Limit(pageSize + 1).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to get tickets page: %v", err)
+		return nil, ErrInternal
+	}
+
+	hasNextPage := len(dbTickets) > pageSize
+	if hasNextPage {
+		dbTickets = dbTickets[:pageSize]
+	}
+
+	connection := &model.TicketConnection{
+		Edges:      []*model.TicketEdge{},
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: int32(totalCount),
+	}
+
+	for _, t := range dbTickets {
+		cursorValue := t.Title
+		switch sortField {
+		case model.TicketSortFieldCreatedAt:
+			cursorValue = t.CreatedAt.Format(time.RFC3339Nano)
+		case model.TicketSortFieldLastModified:
+			cursorValue = t.LastModified.Format(time.RFC3339Nano)
+		}
+
+		cursor := utils.EncodeCursor(cursorValue, t.ID)
+		connection.Edges = append(connection.Edges, &model.TicketEdge{
+			Cursor: cursor,
+			Node:   toGQLTicket(t),
+		})
+		connection.PageInfo.EndCursor = &cursor
+	}
+
+	return connection, nil
 }
 
 // Labels is the resolver for the labels field.
 func (r *queryResolver) Labels(ctx context.Context, ids []string) ([]*model.Label, error) {
 	var dbLabels []*models.Label
 
-	query := r.DB.NewSelect().Model(&dbLabels).Relation("Tickets")
+	query := r.DB.NewSelect().Model(&dbLabels).Relation("Tickets", withoutQuarantine(ctx))
 
 	if len(ids) > 0 {
 		query = query.Where("label.id IN (?)", bun.In(ids))
@@ -1074,14 +2700,16 @@ func (r *queryResolver) Labels(ctx context.Context, ids []string) ([]*model.Labe
 	for _, l := range dbLabels {
 		var gqlTickets []*model.Ticket
 		for _, t := range l.Tickets {
+			note := string(t.Note)
 			gqlTickets = append(gqlTickets, &model.Ticket{
 				ID:           t.ID,
 				Title:        t.Title,
-				Text:         t.Text,
-				Note:         &t.Note,
+				Text:         string(t.Text),
+				Note:         &note,
 				State:        t.State,
 				CreatedAt:    t.CreatedAt,
 				LastModified: t.LastModified,
+				Source:       t.Source,
 			})
 		}
 
@@ -1101,7 +2729,7 @@ func (r *queryResolver) Labels(ctx context.Context, ids []string) ([]*model.Labe
 func (r *queryResolver) FormLabels(ctx context.Context, ids []string) ([]*model.Label, error) {
 	var dbLabels []*models.Label
 
-	query := r.DB.NewSelect().Model(&dbLabels).Relation("Tickets")
+	query := r.DB.NewSelect().Model(&dbLabels).Relation("Tickets", withoutQuarantine(ctx))
 
 	if len(ids) > 0 {
 		query = query.Where("label.id IN (?)", bun.In(ids))
@@ -1118,14 +2746,16 @@ func (r *queryResolver) FormLabels(ctx context.Context, ids []string) ([]*model.
 	for _, l := range dbLabels {
 		var gqlTickets []*model.Ticket
 		for _, t := range l.Tickets {
+			note := string(t.Note)
 			gqlTickets = append(gqlTickets, &model.Ticket{
 				ID:           t.ID,
 				Title:        t.Title,
-				Text:         t.Text,
-				Note:         &t.Note,
+				Text:         string(t.Text),
+				Note:         &note,
 				State:        t.State,
 				CreatedAt:    t.CreatedAt,
 				LastModified: t.LastModified,
+				Source:       t.Source,
 			})
 		}
 
@@ -1335,14 +2965,18 @@ func (r *queryResolver) LoginCheck(ctx context.Context, sid *string) (*model.Use
 	}
 
 	gqlUser := model.User{
-		ID:           users[0].ID,
-		Mail:         users[0].Mail,
-		Firstname:    users[0].Firstname,
-		Lastname:     users[0].Lastname,
-		Role:         users[0].Role,
-		CreatedAt:    users[0].CreatedAt,
-		LastModified: users[0].LastLogin,
-		LastLogin:    &users[0].LastLogin,
+		ID:              users[0].ID,
+		Mail:            users[0].Mail,
+		Firstname:       users[0].Firstname,
+		Lastname:        users[0].Lastname,
+		Role:            users[0].Role,
+		CreatedAt:       users[0].CreatedAt,
+		LastModified:    users[0].LastLogin,
+		LastLogin:       &users[0].LastLogin,
+		DigestFrequency: users[0].DigestFrequency,
+	}
+	if !users[0].FeedTokenCreatedAt.IsZero() {
+		gqlUser.FeedTokenCreatedAt = &users[0].FeedTokenCreatedAt
 	}
 
 	return &gqlUser, nil
@@ -1368,11 +3002,312 @@ func (r *queryResolver) QuestionAnswerPairs(ctx context.Context, ids []string) (
 	return questionAnswerPairs, nil
 }
 
+// TicketByTrackingCode is the resolver for the ticketByTrackingCode field.
+func (r *queryResolver) TicketByTrackingCode(ctx context.Context, code string) (*model.TrackedTicket, error) {
+	t, merged, err := r.ticketByTrackingCode(ctx, code)
+	if err != nil {
+		log.Printf("Failed to fetch ticket by tracking code: %v", err)
+		return nil, ErrInternal
+	}
+
+	if t == nil {
+		return nil, nil
+	}
+
+	var dbMessages []*models.TicketMessage
+	if err := r.DB.NewSelect().Model(&dbMessages).
+		Apply(whereConversation(t.ID, merged)).
+		Order("ticket_message.created_at ASC").
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch messages of ticket by tracking code: %v", err)
+		return nil, ErrInternal
+	}
+
+	// submitters are not told that their ticket was filtered
+	state := t.State
+	if state == model.TicketStateQuarantine {
+		state = model.TicketStateNew
+	}
+
+	var messages []*model.TrackedTicketMessage
+	for _, m := range dbMessages {
+		messages = append(messages, &model.TrackedTicketMessage{
+			FromSubmitter: m.FromSubmitter,
+			Text:          string(m.Text),
+			CreatedAt:     m.CreatedAt,
+		})
+	}
+
+	tracked := &model.TrackedTicket{
+		Title:        t.OriginalTitle,
+		Text:         string(t.Text),
+		State:        state,
+		CreatedAt:    t.CreatedAt,
+		LastModified: t.LastModified,
+		Messages:     messages,
+	}
+
+	// only the state of the ticket it was merged into is shared with the submitter of a merged ticket
+	if merged != nil {
+		tracked.Title = merged.OriginalTitle
+		tracked.Text = string(merged.Text)
+		tracked.CreatedAt = merged.CreatedAt
+		if merged.CreatedAt.IsZero() {
+			tracked.CreatedAt = merged.MergedAt
+		}
+	}
+
+	return tracked, nil
+}
+
+// SubmissionChallenge is the resolver for the submissionChallenge field.
+func (r *queryResolver) SubmissionChallenge(ctx context.Context) (*model.SubmissionChallenge, error) {
+	difficulty := r.challengeDifficulty(ctx)
+
+	challenge, expiresAt, err := auth.NewChallenge(difficulty)
+	if err != nil {
+		log.Printf("Failed to create submission challenge: %v", err)
+		return nil, ErrInternal
+	}
+
+	return &model.SubmissionChallenge{
+		Challenge:  challenge,
+		Difficulty: int32(difficulty),
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// TicketStates is the resolver for the ticketStates field.
+func (r *queryResolver) TicketStates(ctx context.Context) ([]*model.TicketStateDefinition, error) {
+	var dbStates []*models.TicketStateDefinition
+
+	if err := r.DB.NewSelect().Model(&dbStates).
+		Relation("Transitions").
+		Order("ticket_state.position ASC", "ticket_state.key ASC").
+		Scan(ctx); err != nil {
+		log.Printf("Failed to get ticket states: %v", err)
+		return nil, ErrInternal
+	}
+
+	states := []*model.TicketStateDefinition{}
+	for _, s := range dbStates {
+		states = append(states, toGQLTicketStateDefinition(s))
+	}
+
+	return states, nil
+}
+
+// TrashedTickets is the resolver for the trashedTickets field.
+func (r *queryResolver) TrashedTickets(ctx context.Context) ([]*model.Ticket, error) {
+	var dbTickets []*models.Ticket
+
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Apply(withTicketRelations).
+		WhereDeleted().
+		Order("ticket.deleted_at DESC").
+		Scan(ctx); err != nil {
+		log.Printf("Failed to get trashed tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	tickets := []*model.Ticket{}
+	for _, t := range dbTickets {
+		tickets = append(tickets, toGQLTicket(t))
+	}
+
+	return tickets, nil
+}
+
+// NotificationSubscriptions is the resolver for the notificationSubscriptions field.
+func (r *queryResolver) NotificationSubscriptions(ctx context.Context) ([]*model.NotificationSubscription, error) {
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	var dbSubscriptions []*models.NotificationSubscription
+	if err := r.DB.NewSelect().Model(&dbSubscriptions).
+		Relation("Label").
+		Where("notification_subscription.user_id = ?", user.ID).
+		Order("notification_subscription.created_at ASC").
+		Scan(ctx); err != nil {
+		log.Printf("Failed to get notification subscriptions: %v", err)
+		return nil, ErrInternal
+	}
+
+	subscriptions := []*model.NotificationSubscription{}
+	for _, s := range dbSubscriptions {
+		subscriptions = append(subscriptions, toGQLNotificationSubscription(s))
+	}
+
+	return subscriptions, nil
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	var dbWebhooks []*models.Webhook
+	if err := r.DB.NewSelect().Model(&dbWebhooks).Order("created_at ASC").Scan(ctx); err != nil {
+		log.Printf("Failed to fetch webhooks: %v", err)
+		return nil, ErrInternal
+	}
+
+	result := []*model.Webhook{}
+	for _, w := range dbWebhooks {
+		result = append(result, toGQLWebhook(w))
+	}
+
+	return result, nil
+}
+
+// WebhookDeliveries is the resolver for the webhookDeliveries field.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, webhookID *string, status *model.WebhookDeliveryStatus, limit *int32) ([]*model.WebhookDelivery, error) {
+	const DefaultDeliveryLimit = 50
+	const MaxDeliveryLimit = 500
+
+	resultLimit := DefaultDeliveryLimit
+	if limit != nil {
+		if *limit < 1 || *limit > MaxDeliveryLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %v", MaxDeliveryLimit)
+		}
+		resultLimit = int(*limit)
+	}
+
+	var deliveries []*models.WebhookDelivery
+	query := r.DB.NewSelect().Model(&deliveries).
+		ExcludeColumn("payload").
+		Order("created_at DESC").
+		Limit(resultLimit)
+
+	if webhookID != nil {
+		query = query.Where("webhook_id = ?", *webhookID)
+	}
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		log.Printf("Failed to fetch webhook deliveries: %v", err)
+		return nil, ErrInternal
+	}
+
+	result := []*model.WebhookDelivery{}
+	for _, d := range deliveries {
+		result = append(result, toGQLWebhookDelivery(d))
+	}
+
+	return result, nil
+}
+
+// ChatChannels is the resolver for the chatChannels field.
+func (r *queryResolver) ChatChannels(ctx context.Context) ([]*model.ChatChannel, error) {
+	var dbChannels []*models.ChatChannel
+	if err := r.DB.NewSelect().Model(&dbChannels).
+		Relation("Label").
+		Order("chat_channel.name ASC").
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch chat channels: %v", err)
+		return nil, ErrInternal
+	}
+
+	result := []*model.ChatChannel{}
+	for _, c := range dbChannels {
+		result = append(result, toGQLChatChannel(c))
+	}
+
+	return result, nil
+}
+
+// RetentionPreview is the resolver for the retentionPreview field.
+func (r *queryResolver) RetentionPreview(ctx context.Context) (*model.RetentionPreview, error) {
+	policy, err := r.RetentionPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := r.RetentionCandidates(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var dbTickets []*models.Ticket
+	if len(ids) > 0 {
+		if err := r.DB.NewSelect().Model(&dbTickets).
+			Apply(withTicketRelations).
+			Where("ticket.id IN (?)", bun.In(ids)).
+			Order("ticket.last_modified ASC").
+			Scan(ctx); err != nil {
+			log.Printf("Failed to get tickets due for retention: %v", err)
+			return nil, ErrInternal
+		}
+	}
+
+	tickets := []*model.Ticket{}
+	for _, t := range dbTickets {
+		tickets = append(tickets, toGQLTicket(t))
+	}
+
+	return &model.RetentionPreview{
+		Enabled:      policy.Days > 0,
+		Days:         int32(policy.Days),
+		Action:       policy.Action,
+		ExemptLabels: policy.ExemptLabels,
+		Tickets:      tickets,
+	}, nil
+}
+
+// TicketCreated is the resolver for the ticketCreated field.
+func (r *subscriptionResolver) TicketCreated(ctx context.Context) (<-chan *model.Ticket, error) {
+	return subscribe(ctx, r.Events, func(event events.TicketEvent) (*model.Ticket, bool) {
+		if event.Type != events.TicketCreated {
+			return nil, false
+		}
+
+		ticket := r.loadTicket(ctx, event.TicketID)
+		return ticket, ticket != nil
+	}), nil
+}
+
+// TicketUpdated is the resolver for the ticketUpdated field.
+func (r *subscriptionResolver) TicketUpdated(ctx context.Context, id *string) (<-chan *model.Ticket, error) {
+	return subscribe(ctx, r.Events, func(event events.TicketEvent) (*model.Ticket, bool) {
+		if event.Type != events.TicketUpdated || (id != nil && *id != event.TicketID) {
+			return nil, false
+		}
+
+		ticket := r.loadTicket(ctx, event.TicketID)
+		return ticket, ticket != nil
+	}), nil
+}
+
+// TicketStateChanged is the resolver for the ticketStateChanged field.
+func (r *subscriptionResolver) TicketStateChanged(ctx context.Context) (<-chan *model.TicketStateChange, error) {
+	return subscribe(ctx, r.Events, func(event events.TicketEvent) (*model.TicketStateChange, bool) {
+		if event.Type != events.TicketStateChanged {
+			return nil, false
+		}
+
+		ticket := r.loadTicket(ctx, event.TicketID)
+		if ticket == nil {
+			return nil, false
+		}
+
+		return &model.TicketStateChange{
+			Ticket:   ticket,
+			OldState: event.OldState,
+			NewState: event.NewState,
+		}, true
+	}), nil
+}
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
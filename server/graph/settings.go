@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/auth"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/filters"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/notifications"
+)
+
+const defaultChallengeDifficulty = 16
+
+// validateSetting rejects values of settings the server relies on, which it could not use
+func validateSetting(key, value string) error {
+	switch {
+	case strings.HasPrefix(key, middleware.RateLimitSettingPrefix):
+		return middleware.ValidateRateLimit(value)
+	case strings.HasPrefix(key, filters.SettingPrefix):
+		return filters.ValidateSetting(key, value)
+	case key == models.RetentionDaysKey || key == models.RetentionActionKey:
+		return validateRetentionSetting(key, value)
+	case key == models.DigestTemplateKey:
+		return notifications.ValidateDigestTemplate(value)
+	case key == models.DigestStaleDaysKey:
+		if days, err := strconv.Atoi(value); err != nil || days < 1 {
+			return fmt.Errorf("stale days must be a positive number")
+		}
+	case key == models.InboundMailLabelsKey:
+		_, err := parseMailLabelAliases(value)
+		return err
+	case key == models.SubmissionChallengeDifficultyKey:
+		difficulty, err := strconv.Atoi(value)
+		if err != nil || difficulty < 0 || difficulty > auth.MaxChallengeDifficulty {
+			return fmt.Errorf("difficulty must be a number between 0 and %v", auth.MaxChallengeDifficulty)
+		}
+	}
+
+	return nil
+}
+
+// challengeDifficulty reads the configured difficulty, falling back to the default if it is missing
+func (r *Resolver) challengeDifficulty(ctx context.Context) int {
+	var settings []*models.Setting
+	if err := r.DB.NewSelect().Model(&settings).
+		Where("key = ?", models.SubmissionChallengeDifficultyKey).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch challenge difficulty: %v", err)
+		return defaultChallengeDifficulty
+	}
+
+	if len(settings) == 0 {
+		return defaultChallengeDifficulty
+	}
+
+	difficulty, err := strconv.Atoi(settings[0].Value)
+	if err != nil || difficulty < 0 || difficulty > auth.MaxChallengeDifficulty {
+		log.Printf("Invalid challenge difficulty %q, using the default", settings[0].Value)
+		return defaultChallengeDifficulty
+	}
+
+	return difficulty
+}
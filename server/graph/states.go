@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+// builtinTicketStates cannot be deleted, as tickets are created as NEW and other parts of the system rely on them
+var builtinTicketStates = map[model.TicketState]struct{}{
+	model.TicketStateNew:        {},
+	model.TicketStateOpen:       {},
+	model.TicketStateClosed:     {},
+	model.TicketStateQuarantine: {},
+}
+
+// defaultStateColor is the color of ticket states created without one, the column default of models.TicketStateDefinition
+const defaultStateColor = "#7a7777"
+
+// stateColor validates the color given for a ticket state, and returns current if none was given
+func stateColor(color *string, current string) (string, error) {
+	if color == nil {
+		return current, nil
+	}
+
+	if match, _ := regexp.MatchString("^#[[:xdigit:]]{6}$", *color); !match {
+		return "", fmt.Errorf("color was not provided in valid hex format")
+	}
+	return *color, nil
+}
+
+// checkStateTransitions returns an error if tickets in one of the states from may not be moved to state to
+func (r *Resolver) checkStateTransitions(ctx context.Context, from []model.TicketState, to model.TicketState) error {
+	if !isAdmin(ctx) && (to == model.TicketStateQuarantine || slices.Contains(from, model.TicketStateQuarantine)) {
+		return fmt.Errorf("access denied")
+	}
+
+	exists, err := r.DB.NewSelect().Model((*models.TicketStateDefinition)(nil)).
+		Where("key = ?", to).
+		Exists(ctx)
+	if err != nil {
+		log.Printf("Failed to look up ticket state %v: %v", to, err)
+		return ErrInternal
+	}
+	if !exists {
+		return fmt.Errorf("unknown ticket state %v", to)
+	}
+
+	var transitions []*models.TicketStateTransition
+	if err := r.DB.NewSelect().Model(&transitions).
+		Where("to_key = ?", to).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch transitions to ticket state %v: %v", to, err)
+		return ErrInternal
+	}
+
+	allowed := make(map[model.TicketState]struct{}, len(transitions))
+	for _, t := range transitions {
+		allowed[t.FromKey] = struct{}{}
+	}
+
+	for _, state := range from {
+		if _, ok := allowed[state]; !ok && state != to {
+			return fmt.Errorf("tickets in state %v cannot be moved to %v", state, to)
+		}
+	}
+
+	return nil
+}
+
+// checkTicketStatesExist returns an error naming the first of the states which is not configured
+func (r *Resolver) checkTicketStatesExist(ctx context.Context, db bun.IDB, states []model.TicketState) error {
+	if len(states) == 0 {
+		return nil
+	}
+
+	var existing []model.TicketState
+	if err := db.NewSelect().Model((*models.TicketStateDefinition)(nil)).
+		Column("key").
+		Where("key IN (?)", bun.In(states)).
+		Scan(ctx, &existing); err != nil {
+		log.Printf("Failed to look up ticket states: %v", err)
+		return ErrInternal
+	}
+
+	found := make(map[model.TicketState]struct{}, len(existing))
+	for _, key := range existing {
+		found[key] = struct{}{}
+	}
+
+	for _, state := range states {
+		if _, ok := found[state]; !ok {
+			return fmt.Errorf("unknown ticket state %v", state)
+		}
+	}
+
+	return nil
+}
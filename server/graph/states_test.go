@@ -0,0 +1,33 @@
+package graph
+
+import "testing"
+
+func TestStateColor(t *testing.T) {
+	color := func(c string) *string { return &c }
+
+	tests := []struct {
+		name    string
+		color   *string
+		want    string
+		wantErr bool
+	}{
+		{"not given", nil, defaultStateColor, false},
+		{"hex", color("#A1b2C3"), "#A1b2C3", false},
+		{"empty", color(""), "", true},
+		{"without hash", color("a1b2c3"), "", true},
+		{"short", color("#abc"), "", true},
+		{"no hex", color("#ghijkl"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stateColor(tt.color, defaultStateColor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
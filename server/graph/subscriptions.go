@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"context"
+	"log"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+// subscribe forwards the ticket events for which convert returns true to the subscriber until ctx is done
+func subscribe[T any](ctx context.Context, bus *events.Bus, convert func(events.TicketEvent) (T, bool)) <-chan T {
+	ticketEvents := bus.Subscribe(ctx)
+	results := make(chan T)
+
+	go func() {
+		defer close(results)
+
+		for event := range ticketEvents {
+			result, ok := convert(event)
+			if !ok {
+				continue
+			}
+
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return results
+}
+
+// loadTicket fetches the current state of a ticket for a subscriber, nil if it does not exist anymore
+func (r *Resolver) loadTicket(ctx context.Context, id string) *model.Ticket {
+	var dbTickets []*models.Ticket
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Apply(withTicketRelations).
+		Apply(withoutQuarantine(ctx)).
+		Where("ticket.id = ?", id).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to load ticket %v for subscription: %v", id, err)
+		return nil
+	}
+
+	if len(dbTickets) == 0 {
+		return nil
+	}
+
+	return toGQLTicket(dbTickets[0])
+}
@@ -0,0 +1,293 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/importer"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// MaxImportRows keeps the validated tickets of one import small enough to hold in memory
+	MaxImportRows = 10000
+
+	importBatchSize = 500
+)
+
+var errTooManyImportRows = fmt.Errorf("the file has more than %v rows, split it into smaller files", MaxImportRows)
+
+// importedTicket is a valid row, ready to be inserted
+type importedTicket struct {
+	row    int
+	ticket *models.Ticket
+	labels []*models.Label
+}
+
+// ImportTicketFile creates tickets from a CSV or JSON file, see the importer package for the columns.
+// Rejected rows are listed in the report, the valid ones are created together. Imported tickets
+// keep their dates and get no tracking code, as nobody is waiting for an answer to them.
+// Tickets keep the IDs of the export, rows with the ID of an existing ticket are rejected,
+// so importing an export again does not duplicate its tickets.
+func (r *Resolver) ImportTicketFile(ctx context.Context, format string, file io.Reader, dryRun bool) (*model.TicketImportReport, error) {
+	var dbLabels []*models.Label
+	if err := r.DB.NewSelect().Model(&dbLabels).Scan(ctx); err != nil {
+		log.Printf("Failed to fetch labels for import: %v", err)
+		return nil, ErrInternal
+	}
+
+	labels := make(map[string]*models.Label, len(dbLabels))
+	for _, l := range dbLabels {
+		labels[strings.ToLower(l.Name)] = l
+	}
+
+	// states are configurable, each one used by the file is looked up once
+	checkedStates := make(map[model.TicketState]error)
+	checkState := func(state model.TicketState) error {
+		if err, ok := checkedStates[state]; ok {
+			return err
+		}
+		err := r.checkTicketStatesExist(ctx, r.DB, []model.TicketState{state})
+		if !errors.Is(err, ErrInternal) {
+			checkedStates[state] = err
+		}
+		return err
+	}
+
+	report := &model.TicketImportReport{DryRun: dryRun, Rejected: []*model.RejectedTicketRow{}}
+	var tickets []importedTicket
+	ids := make(map[string]struct{})
+	rows := 0
+	now := time.Now()
+
+	err := importer.Read(format, file, func(row importer.Row) error {
+		if rows++; rows > MaxImportRows {
+			return errTooManyImportRows
+		}
+
+		ticket, err := validateImportRow(row, labels, checkState, now)
+		if errors.Is(err, ErrInternal) {
+			return err
+		}
+		if err == nil {
+			if _, duplicate := ids[ticket.ticket.ID]; duplicate {
+				err = fmt.Errorf("the ID %v is used by another row", ticket.ticket.ID)
+			}
+		}
+		if err != nil {
+			report.Rejected = append(report.Rejected, &model.RejectedTicketRow{Row: int32(row.Number), Reason: err.Error()})
+			return nil
+		}
+
+		ids[ticket.ticket.ID] = struct{}{}
+		tickets = append(tickets, ticket)
+		return nil
+	})
+	if errors.Is(err, ErrInternal) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the file: %w", err)
+	}
+
+	tickets, err = r.rejectExistingTickets(ctx, tickets, report)
+	if err != nil {
+		log.Printf("Failed to check imported tickets for existing IDs: %v", err)
+		return nil, ErrInternal
+	}
+
+	report.Imported = int32(len(tickets))
+	if dryRun || len(tickets) == 0 {
+		return report, nil
+	}
+
+	if err := r.insertImportedTickets(ctx, tickets); err != nil {
+		log.Printf("Failed to import tickets: %v", err)
+		return nil, ErrInternal
+	}
+
+	// no events are published, an import of old tickets should not notify anyone
+	log.Printf("Imported %v tickets, rejected %v rows", report.Imported, len(report.Rejected))
+
+	return report, nil
+}
+
+func validateImportRow(row importer.Row, labels map[string]*models.Label, checkState func(model.TicketState) error, now time.Time) (importedTicket, error) {
+	if row.Err != nil {
+		return importedTicket{}, row.Err
+	}
+
+	id := uuid.New().String()
+	if row.ID != "" {
+		parsed, err := uuid.Parse(row.ID)
+		if err != nil {
+			return importedTicket{}, fmt.Errorf("invalid ID %q", row.ID)
+		}
+		id = parsed.String()
+	}
+
+	title := strings.TrimSpace(row.Title)
+	if err := validateTicketContent(title, row.Text, row.Note); err != nil {
+		return importedTicket{}, err
+	}
+
+	originalTitle := strings.TrimSpace(row.OriginalTitle)
+	if originalTitle == "" {
+		originalTitle = title
+	}
+	if err := validateTicketContent(originalTitle, "", ""); err != nil {
+		return importedTicket{}, fmt.Errorf("original %w", err)
+	}
+
+	state := model.TicketStateNew
+	if row.State != "" {
+		state = model.TicketState(row.State)
+	}
+	if !state.IsValid() {
+		return importedTicket{}, fmt.Errorf("unknown state %q", row.State)
+	}
+	if state == model.TicketStateQuarantine {
+		return importedTicket{}, fmt.Errorf("tickets cannot be imported into the quarantine")
+	}
+	if err := checkState(state); err != nil {
+		return importedTicket{}, err
+	}
+
+	createdAt := row.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = now
+	}
+	if createdAt.After(now) {
+		return importedTicket{}, fmt.Errorf("the creation date is in the future")
+	}
+
+	lastModified := row.LastModified
+	if lastModified.IsZero() {
+		lastModified = createdAt
+	}
+	if lastModified.Before(createdAt) || lastModified.After(now) {
+		return importedTicket{}, fmt.Errorf("the modification date has to be between the creation date and now")
+	}
+
+	var ticketLabels []*models.Label
+	seen := make(map[string]struct{})
+	for _, name := range row.Labels {
+		label, ok := labels[strings.ToLower(name)]
+		if !ok {
+			return importedTicket{}, fmt.Errorf("unknown label %q", name)
+		}
+		if _, duplicate := seen[label.ID]; !duplicate {
+			seen[label.ID] = struct{}{}
+			ticketLabels = append(ticketLabels, label)
+		}
+	}
+
+	return importedTicket{
+		row: row.Number,
+		ticket: &models.Ticket{
+			ID:            id,
+			OriginalTitle: originalTitle,
+			Title:         title,
+			Text:          models.EncryptedString(row.Text),
+			Note:          models.EncryptedString(row.Note),
+			State:         state,
+			Source:        model.TicketSourceImport,
+			CreatedAt:     createdAt,
+			LastModified:  lastModified,
+		},
+		labels: ticketLabels,
+	}, nil
+}
+
+// rejectExistingTickets moves the tickets with the ID of an existing one, deleted ones included,
+// to the rejected rows of the report
+func (r *Resolver) rejectExistingTickets(ctx context.Context, tickets []importedTicket, report *model.TicketImportReport) ([]importedTicket, error) {
+	existing := make(map[string]struct{})
+	for start := 0; start < len(tickets); start += importBatchSize {
+		var ids []string
+		for _, t := range tickets[start:min(start+importBatchSize, len(tickets))] {
+			ids = append(ids, t.ticket.ID)
+		}
+
+		var found []string
+		if err := r.DB.NewSelect().Model((*models.Ticket)(nil)).
+			WhereAllWithDeleted().
+			Column("id").
+			Where("id IN (?)", bun.In(ids)).
+			Scan(ctx, &found); err != nil {
+			return nil, err
+		}
+		for _, id := range found {
+			existing[id] = struct{}{}
+		}
+	}
+
+	if len(existing) == 0 {
+		return tickets, nil
+	}
+
+	kept := tickets[:0]
+	for _, t := range tickets {
+		if _, ok := existing[t.ticket.ID]; ok {
+			report.Rejected = append(report.Rejected, &model.RejectedTicketRow{Row: int32(t.row), Reason: fmt.Sprintf("a ticket with the ID %v exists already", t.ticket.ID)})
+			continue
+		}
+		kept = append(kept, t)
+	}
+
+	slices.SortFunc(report.Rejected, func(a, b *model.RejectedTicketRow) int { return int(a.Row - b.Row) })
+	return kept, nil
+}
+
+// insertImportedTickets creates the tickets, their labels and the creation in their history in one transaction
+func (r *Resolver) insertImportedTickets(ctx context.Context, tickets []importedTicket) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for start := 0; start < len(tickets); start += importBatchSize {
+		batch := tickets[start:min(start+importBatchSize, len(tickets))]
+
+		var dbTickets []*models.Ticket
+		var labelsToTickets []models.LabelsToTickets
+		var historyEvents []*models.TicketEvent
+
+		for _, t := range batch {
+			dbTickets = append(dbTickets, t.ticket)
+
+			for _, label := range t.labels {
+				labelsToTickets = append(labelsToTickets, models.LabelsToTickets{LabelID: label.ID, TicketID: t.ticket.ID})
+			}
+
+			// the history starts at the original date, so feeds and digests do not list old tickets as new
+			event := newTicketEvent(ctx, t.ticket.ID, model.TicketEventTypeCreated, "", "")
+			event.CreatedAt = t.ticket.CreatedAt
+			historyEvents = append(historyEvents, event)
+		}
+
+		if _, err := tx.NewInsert().Model(&dbTickets).Exec(ctx); err != nil {
+			return err
+		}
+		if len(labelsToTickets) > 0 {
+			if _, err := tx.NewInsert().Model(&labelsToTickets).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if err := recordTicketEvents(ctx, tx, historyEvents); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/importer"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+func TestValidateImportRow(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	labels := map[string]*models.Label{"hörsaal": {ID: "l1", Name: "Hörsaal"}}
+	checkState := func(state model.TicketState) error {
+		if state == "BLOCKED" {
+			return fmt.Errorf("state %v is not configured", state)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		row     importer.Row
+		wantErr string
+	}{
+		{name: "minimal", row: importer.Row{Title: " Beamer "}},
+		{name: "complete", row: importer.Row{
+			ID:           "3F1C2A9E-0000-4000-8000-000000000001",
+			Title:        "Beamer",
+			Text:         "kaputt",
+			Note:         "gemeldet",
+			State:        "CLOSED",
+			Labels:       []string{"HÖRSAAL", "Hörsaal"},
+			CreatedAt:    now.Add(-time.Hour),
+			LastModified: now,
+		}},
+		{name: "read error", row: importer.Row{Err: fmt.Errorf("expected 3 fields")}, wantErr: "expected 3 fields"},
+		{name: "invalid id", row: importer.Row{ID: "42", Title: "Beamer"}, wantErr: "invalid ID"},
+		{name: "missing title", row: importer.Row{Title: "  "}, wantErr: "title is missing"},
+		{name: "long title", row: importer.Row{Title: strings.Repeat("x", MaxTitleLength+1)}, wantErr: "title exceeds"},
+		{name: "long original title", row: importer.Row{Title: "Beamer", OriginalTitle: strings.Repeat("x", MaxTitleLength+1)}, wantErr: "original ticket title exceeds"},
+		{name: "long text", row: importer.Row{Title: "Beamer", Text: strings.Repeat("x", MaxTextLength+1)}, wantErr: "text exceeds"},
+		{name: "long note", row: importer.Row{Title: "Beamer", Note: strings.Repeat("x", MaxNoteLength+1)}, wantErr: "note exceeds"},
+		{name: "not utf-8", row: importer.Row{Title: "Beamer", Text: "\xff"}, wantErr: "UTF-8"},
+		{name: "unknown state", row: importer.Row{Title: "Beamer", State: "done!"}, wantErr: "unknown state"},
+		{name: "quarantine", row: importer.Row{Title: "Beamer", State: "QUARANTINE"}, wantErr: "quarantine"},
+		{name: "state not configured", row: importer.Row{Title: "Beamer", State: "BLOCKED"}, wantErr: "not configured"},
+		{name: "future", row: importer.Row{Title: "Beamer", CreatedAt: now.Add(time.Hour)}, wantErr: "in the future"},
+		{name: "modified before created", row: importer.Row{Title: "Beamer", CreatedAt: now.Add(-time.Hour), LastModified: now.Add(-2 * time.Hour)}, wantErr: "modification date"},
+		{name: "unknown label", row: importer.Row{Title: "Beamer", Labels: []string{"Mensa"}}, wantErr: "unknown label"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateImportRow(tt.row, labels, checkState, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.ticket.ID == "" || got.ticket.Title != strings.TrimSpace(tt.row.Title) || got.ticket.Source != model.TicketSourceImport {
+				t.Errorf("unexpected ticket %+v", got.ticket)
+			}
+		})
+	}
+
+	complete, _ := validateImportRow(tests[1].row, labels, checkState, now)
+	if complete.ticket.ID != "3f1c2a9e-0000-4000-8000-000000000001" {
+		t.Errorf("the ID %v was not kept", complete.ticket.ID)
+	}
+	if len(complete.labels) != 1 || complete.ticket.OriginalTitle != "Beamer" || complete.ticket.State != model.TicketStateClosed {
+		t.Errorf("unexpected ticket %+v with labels %v", complete.ticket, complete.labels)
+	}
+
+	minimal, _ := validateImportRow(tests[0].row, labels, checkState, now)
+	if !minimal.ticket.CreatedAt.Equal(now) || !minimal.ticket.LastModified.Equal(now) || minimal.ticket.State != model.TicketStateNew {
+		t.Errorf("unexpected defaults %+v", minimal.ticket)
+	}
+}
@@ -0,0 +1,191 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/auth"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/filters"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/google/uuid"
+)
+
+const (
+	MaxTitleLength = 70
+	MaxTextLength  = 3000
+	MaxNoteLength  = 3000
+)
+
+// TicketSubmission is a new ticket from one of the sources, e.g. the form or an inbound mail
+type TicketSubmission struct {
+	Title string
+	Text  string
+	// Labels are names, matched case-insensitively
+	Labels      []string
+	Attachments []*graphql.Upload
+	Source      model.TicketSource
+}
+
+// SubmitTicket validates and creates a ticket, the result contains its tracking code.
+// Callers have to check challenges or rate limits of their source themselves.
+func (r *Resolver) SubmitTicket(ctx context.Context, submission TicketSubmission) (*model.Ticket, error) {
+	var labels []*models.Label
+
+	for _, labelName := range submission.Labels {
+		label := &models.Label{}
+		err := r.DB.NewSelect().
+			Model(label).
+			Where("LOWER(name) = ?", strings.ToLower(labelName)).
+			Limit(1).
+			Scan(ctx)
+		if err != nil {
+			log.Printf("Label not found: %s", labelName)
+			return nil, ErrInternal
+		}
+		labels = append(labels, label)
+	}
+
+	if err := validateTicketContent(strings.TrimSpace(submission.Title), submission.Text, ""); err != nil {
+		return nil, err
+	}
+
+	ticketID := uuid.New().String()
+
+	attachments, err := prepareAttachments(ticketID, submission.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	trackingCode, trackingCodeHash, err := auth.NewTrackingCode()
+	if err != nil {
+		log.Printf("Failed to generate tracking code: %v", err)
+		return nil, ErrInternal
+	}
+
+	dbTicket := &models.Ticket{
+		ID:               ticketID,
+		Text:             models.EncryptedString(submission.Text),
+		OriginalTitle:    strings.TrimSpace(submission.Title),
+		Title:            strings.TrimSpace(submission.Title),
+		TrackingCodeHash: trackingCodeHash,
+		State:            model.TicketStateNew,
+		Source:           submission.Source,
+		Labels:           labels,
+		CreatedAt:        time.Now(),
+		LastModified:     time.Now(),
+	}
+
+	historyEvents := []*models.TicketEvent{newTicketEvent(ctx, dbTicket.ID, model.TicketEventTypeCreated, "", "")}
+
+	reasons := r.runContentFilters(ctx, filters.Submission{Title: dbTicket.OriginalTitle, Text: submission.Text})
+	if len(reasons) > 0 {
+		dbTicket.State = model.TicketStateQuarantine
+		historyEvents = append(historyEvents, newTicketEvent(ctx, dbTicket.ID, model.TicketEventTypeQuarantined, "", strings.Join(reasons, "; ")))
+	}
+
+	if err := r.storeAttachments(ctx, attachments); err != nil {
+		log.Printf("Failed to store attachments: %v", err)
+		return nil, ErrInternal
+	}
+
+	var storageKeys []string
+	for _, a := range attachments {
+		dbTicket.Attachments = append(dbTicket.Attachments, a.attachment)
+		storageKeys = append(storageKeys, a.attachment.StorageKey)
+	}
+
+	if _, err := r.DB.NewInsert().Model(dbTicket).Exec(ctx); err != nil {
+		log.Printf("Failed to create Ticket: %v", err)
+		r.deleteStoredAttachments(ctx, storageKeys)
+		return nil, ErrInternal
+	}
+
+	if err := recordTicketEvents(ctx, r.DB, historyEvents); err != nil {
+		log.Printf("Failed to record creation of ticket %s: %v", dbTicket.ID, err)
+	}
+
+	// submitters are not told that their ticket was filtered
+	gqlTicket := &model.Ticket{
+		ID:            dbTicket.ID,
+		OriginalTitle: dbTicket.Title,
+		Title:         dbTicket.Title,
+		Text:          submission.Text,
+		State:         model.TicketStateNew,
+		CreatedAt:     dbTicket.CreatedAt,
+		LastModified:  dbTicket.LastModified,
+		Labels:        nil,
+		TrackingCode:  &trackingCode,
+		Source:        dbTicket.Source,
+	}
+
+	if len(dbTicket.Attachments) > 0 {
+		if _, err := r.DB.NewInsert().Model(&dbTicket.Attachments).Exec(ctx); err != nil {
+			log.Printf("Failed to save attachments of ticket: %v", err)
+			r.deleteStoredAttachments(ctx, storageKeys)
+			return gqlTicket, fmt.Errorf("the ticket was created but adding attachments failed")
+		}
+
+		for _, a := range dbTicket.Attachments {
+			gqlTicket.Attachments = append(gqlTicket.Attachments, toGQLTicketAttachment(a))
+		}
+	}
+
+	if len(labels) > 0 {
+		var labelsToTickets []models.LabelsToTickets
+		for _, label := range labels {
+			labelsToTickets = append(labelsToTickets, models.LabelsToTickets{
+				LabelID:  label.ID,
+				TicketID: dbTicket.ID,
+			})
+		}
+		if _, err := r.DB.NewInsert().Model(&labelsToTickets).Exec(ctx); err != nil {
+			log.Printf("Failed to link labels to ticket: %v", err)
+			return gqlTicket, fmt.Errorf("the ticket was created but adding labels failed")
+		}
+	}
+
+	var gqlLabels []*model.Label
+	for _, l := range dbTicket.Labels {
+		gqlLabels = append(gqlLabels, &model.Label{
+			ID:        l.ID,
+			Name:      l.Name,
+			Color:     l.Color,
+			FormLabel: &l.FormLabel,
+		})
+	}
+
+	gqlTicket.Labels = gqlLabels
+
+	r.Events.Publish(events.TicketEvent{Type: events.TicketCreated, TicketID: dbTicket.ID})
+
+	return gqlTicket, nil
+}
+
+// validateTicketContent checks what is written into a new ticket, the title has to be trimmed already
+func validateTicketContent(title, text, note string) error {
+	if title == "" {
+		return fmt.Errorf("ticket title is missing")
+	}
+	if len(title) > MaxTitleLength {
+		return fmt.Errorf("ticket title exceeds max length of %v", MaxTitleLength)
+	}
+	if len(text) > MaxTextLength {
+		return fmt.Errorf("ticket text exceeds max length of %v", MaxTextLength)
+	}
+	if len(note) > MaxNoteLength {
+		return fmt.Errorf("ticket note exceeds max length of %v", MaxNoteLength)
+	}
+	for _, value := range []string{title, text, note} {
+		if !utf8.ValidString(value) {
+			return fmt.Errorf("ticket is not UTF-8 encoded")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/auth"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/encryption"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// Search matches are wrapped in these control characters, so the
+// surrounding text can be escaped before the matches are marked up
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+
+	titleHeadlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+
+	// bytes of text shown before the first match and in total
+	excerptLead   = 80
+	excerptLength = 300
+)
+
+// withTicketRelations loads everything which is shown together with a ticket
+func withTicketRelations(q *bun.SelectQuery) *bun.SelectQuery {
+	return q.
+		Relation("Assignee").
+		Relation("Labels").
+		Relation("Comments", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("ticket_comment.created_at ASC")
+		}).
+		Relation("Comments.Author").
+		Relation("Comments.Revisions", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("ticket_comment_revision.edited_at DESC")
+		}).
+		Relation("Messages", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("ticket_message.created_at ASC")
+		}).
+		Relation("Messages.Author").
+		Relation("Events", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("ticket_event.created_at ASC")
+		}).
+		Relation("Events.Actor").
+		Relation("Attachments", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("ticket_attachment.created_at ASC")
+		}).
+		Relation("MergedTickets", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("merged_ticket.merged_at ASC")
+		})
+}
+
+// ticketByTrackingCode returns the ticket belonging to a tracking code, or nil. For the code of a
+// ticket merged into another one, merged is set as well. Its submitter must only see their own
+// title, text and conversation, the rest of the ticket belongs to other submitters.
+func (r *Resolver) ticketByTrackingCode(ctx context.Context, code string) (*models.Ticket, *models.MergedTicket, error) {
+	hash := auth.HashTrackingCode(code)
+
+	var dbTickets []*models.Ticket
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Where("ticket.tracking_code_hash = ?", hash).
+		Scan(ctx); err != nil {
+		return nil, nil, err
+	}
+	if len(dbTickets) > 0 {
+		return dbTickets[0], nil, nil
+	}
+
+	var mergedTickets []*models.MergedTicket
+	if err := r.DB.NewSelect().Model(&mergedTickets).
+		Where("tracking_code_hash = ?", hash).
+		Scan(ctx); err != nil {
+		return nil, nil, err
+	}
+	if len(mergedTickets) == 0 {
+		return nil, nil, nil
+	}
+
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Where("ticket.id = ?", mergedTickets[0].TicketID).
+		Scan(ctx); err != nil {
+		return nil, nil, err
+	}
+	if len(dbTickets) == 0 {
+		return nil, nil, nil
+	}
+
+	return dbTickets[0], mergedTickets[0], nil
+}
+
+// whereConversation selects the messages of the conversation with the submitter of
+// the ticket itself, or with the one of a ticket merged into it
+func whereConversation(ticketID string, merged *models.MergedTicket) func(q *bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		q = q.Where("ticket_message.ticket_id = ?", ticketID)
+		if merged == nil {
+			return q.Where("ticket_message.merged_ticket_id IS NULL")
+		}
+		return q.Where("ticket_message.merged_ticket_id = ?", merged.ID)
+	}
+}
+
+// markHighlights escapes a ts_headline result and marks the matches with <mark> tags
+func markHighlights(headline string) string {
+	escaped := html.EscapeString(headline)
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(escaped)
+}
+
+// textExcerpt cuts the decrypted text around the first occurrence of a search term and
+// marks all occurrences in it, formatted like markHighlights
+func textExcerpt(text, query string) string {
+	text = strings.NewReplacer(highlightStart, "", highlightStop, "").Replace(text)
+
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		// excluded terms are not in the results anyway
+		if strings.HasPrefix(term, "-") {
+			continue
+		}
+		term = strings.Trim(term, `"`)
+		if term != "" && !strings.EqualFold(term, "or") {
+			terms = append(terms, regexp.QuoteMeta(term))
+		}
+	}
+
+	var matches [][]int
+	if len(terms) > 0 {
+		matches = regexp.MustCompile("(?i)"+strings.Join(terms, "|")).FindAllStringIndex(text, -1)
+	}
+
+	start := 0
+	if len(matches) > 0 {
+		start = max(0, matches[0][0]-excerptLead)
+	}
+	end := min(len(text), start+excerptLength)
+
+	// cut at rune boundaries only
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	var excerpt strings.Builder
+	if start > 0 {
+		excerpt.WriteString("… ")
+	}
+
+	pos := start
+	for _, m := range matches {
+		if m[0] < pos || m[1] > end {
+			continue
+		}
+		excerpt.WriteString(text[pos:m[0]] + highlightStart + text[m[0]:m[1]] + highlightStop)
+		pos = m[1]
+	}
+	excerpt.WriteString(text[pos:end])
+
+	if end < len(text) {
+		excerpt.WriteString(" …")
+	}
+
+	return markHighlights(excerpt.String())
+}
+
+// withAssignedToMe resolves the assignedToMe flag of a filter to the ID of the logged in user
+func withAssignedToMe(ctx context.Context, filter *model.TicketFilter) *model.TicketFilter {
+	if filter == nil || filter.AssignedToMe == nil || !*filter.AssignedToMe {
+		return filter
+	}
+
+	user, ok := ctx.Value(middleware.UserKey).(*model.User)
+	if !ok || user == nil {
+		return filter
+	}
+
+	resolved := *filter
+	resolved.AssigneeIDs = append(append([]string{}, filter.AssigneeIDs...), user.ID)
+
+	return &resolved
+}
+
+// setTicketAssignee assigns the tickets to the user, or unassigns them if user is nil
+func (r *Resolver) setTicketAssignee(ctx context.Context, ids []string, user *models.User) (int32, error) {
+	var dbTickets []*models.Ticket
+	if err := r.DB.NewSelect().Model(&dbTickets).
+		Relation("Assignee").
+		Where("ticket.id IN (?)", bun.In(ids)).
+		Scan(ctx); err != nil {
+		log.Printf("Failed to fetch tickets for assignment: %v", err)
+		return 0, ErrInternal
+	}
+
+	var assigneeID, assigneeName string
+	if user != nil {
+		assigneeID = user.ID
+		assigneeName = fullName(user)
+	}
+
+	var changedIDs []string
+	var historyEvents []*models.TicketEvent
+	for _, t := range dbTickets {
+		if t.AssigneeID == assigneeID {
+			continue
+		}
+
+		changedIDs = append(changedIDs, t.ID)
+		historyEvents = append(historyEvents, newTicketEvent(ctx, t.ID, model.TicketEventTypeAssigneeChanged, fullName(t.Assignee), assigneeName))
+	}
+
+	if len(changedIDs) == 0 {
+		return 0, nil
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return 0, ErrInternal
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := tx.NewUpdate().Model((*models.Ticket)(nil)).
+		Where("id IN (?)", bun.In(changedIDs)).
+		Set("last_modified = ?", time.Now())
+
+	if user != nil {
+		query = query.Set("assignee_id = ?", assigneeID)
+	} else {
+		query = query.Set("assignee_id = NULL")
+	}
+
+	result, err := query.Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to update ticket assignee: %v", err)
+		return 0, ErrInternal
+	}
+
+	if err := recordTicketEvents(ctx, tx, historyEvents); err != nil {
+		log.Printf("Failed to record assignee changes: %v", err)
+		return 0, ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit assignee update: %v", err)
+		return 0, ErrInternal
+	}
+
+	for _, ticketID := range changedIDs {
+		r.Events.Publish(events.TicketEvent{Type: events.TicketUpdated, TicketID: ticketID})
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Failed to read affected rows: %v", err)
+		return 0, fmt.Errorf("the tickets were assigned, but counting them failed")
+	}
+
+	return int32(rowsAffected), nil
+}
+
+func fullName(u *models.User) string {
+	if u == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(u.Firstname + " " + u.Lastname)
+}
+
+// searchTerm is a word or quoted phrase of a search query, with the blind index of its words
+type searchTerm struct {
+	text    string
+	tokens  []string
+	exclude bool
+}
+
+// parseSearchQuery splits a query like websearch_to_tsquery does: the terms of a group all have to
+// match, terms starting with - must not match, and groups are separated by "or"
+func parseSearchQuery(query string) ([][]searchTerm, error) {
+	var groups [][]searchTerm
+	var group []searchTerm
+
+	for _, field := range splitSearchQuery(query) {
+		if strings.EqualFold(field, "or") {
+			if len(group) > 0 {
+				groups = append(groups, group)
+				group = nil
+			}
+			continue
+		}
+
+		term := searchTerm{text: field}
+		if strings.HasPrefix(term.text, "-") {
+			term.text = strings.TrimPrefix(term.text, "-")
+			term.exclude = true
+		}
+		term.text = strings.Trim(term.text, `"`)
+
+		tokens, err := encryption.BlindIndex(term.text)
+		if err != nil {
+			return nil, err
+		}
+		// stop words are left out, like in the full-text search of the titles
+		if len(tokens) == 0 {
+			continue
+		}
+		term.tokens = tokens
+
+		group = append(group, term)
+	}
+
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// splitSearchQuery splits at whitespace outside of double quotes
+func splitSearchQuery(query string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			field.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// whereSearchMatches selects the tickets matching the groups of a search query. Titles are
+// searched in full text, text and note by the blind indexes of their words.
+func whereSearchMatches(groups [][]searchTerm) func(q *bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, group := range groups {
+				q = q.WhereGroup(" OR ", func(q *bun.SelectQuery) *bun.SelectQuery {
+					for _, term := range group {
+						condition := `(ticket.title_search_vector @@ plainto_tsquery('german', ?)
+							OR ticket.text_tokens @> ? OR ticket.note_tokens @> ?)`
+						if term.exclude {
+							condition = "NOT COALESCE(" + condition + ", false)"
+						}
+						tokens := pgdialect.Array(term.tokens)
+						q = q.Where(condition, term.text, tokens, tokens)
+					}
+					return q
+				})
+			}
+			return q
+		})
+	}
+}
+
+// searchTokens are the tokens of all terms which are not excluded, they are used for the ranking
+func searchTokens(groups [][]searchTerm) []string {
+	tokens := []string{}
+	for _, group := range groups {
+		for _, term := range group {
+			if !term.exclude {
+				tokens = append(tokens, term.tokens...)
+			}
+		}
+	}
+	return tokens
+}
+
+// textSimilarity is the share of words two texts have in common, between 0 and 1. It expects the
+// tickets to be compared aliased as ticket and original, and the words they share as words.shared.
+const textSimilarity = `(words.shared::float8 / GREATEST(cardinality(ticket.text_tokens) + cardinality(original.text_tokens) - words.shared, 1))`
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"log"
+	"strings"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/encryption"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// ApplyTicketFilter restricts a select on the tickets table, aliased as ticket, to the tickets matching the filter
+func ApplyTicketFilter(query *bun.SelectQuery, filter *model.TicketFilter) *bun.SelectQuery {
+	if filter == nil {
+		return query
+	}
+
+	if len(filter.Ids) > 0 {
+		query = query.Where("ticket.id IN (?)", bun.In(filter.Ids))
+	}
+
+	if len(filter.States) > 0 {
+		query = query.Where("ticket.state IN (?)", bun.In(filter.States))
+	}
+
+	if len(filter.LabelIDs) > 0 {
+		query = query.Where("ticket.id IN (SELECT ticket_id FROM labels_to_tickets WHERE label_id IN (?))", bun.In(filter.LabelIDs))
+	}
+
+	if len(filter.AssigneeIDs) > 0 {
+		query = query.Where("ticket.assignee_id IN (?)", bun.In(filter.AssigneeIDs))
+	}
+
+	if filter.CreatedAfter != nil {
+		query = query.Where("ticket.created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("ticket.created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.LastModifiedAfter != nil {
+		query = query.Where("ticket.last_modified >= ?", *filter.LastModifiedAfter)
+	}
+	if filter.LastModifiedBefore != nil {
+		query = query.Where("ticket.last_modified < ?", *filter.LastModifiedBefore)
+	}
+
+	if filter.Text != nil && strings.TrimSpace(*filter.Text) != "" {
+		pattern := "%" + escapeLike(strings.TrimSpace(*filter.Text)) + "%"
+
+		// text and note are encrypted, they are matched by whole words through their blind indexes
+		tokens, err := encryption.BlindIndex(*filter.Text)
+		if err != nil {
+			log.Printf("Failed to search ticket texts: %v", err)
+		}
+
+		query = query.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			q = q.
+				WhereOr("ticket.title ILIKE ?", pattern).
+				WhereOr("ticket.original_title ILIKE ?", pattern)
+			if len(tokens) > 0 {
+				q = q.
+					WhereOr("ticket.text_tokens @> ?", pgdialect.Array(tokens)).
+					WhereOr("ticket.note_tokens @> ?", pgdialect.Array(tokens))
+			}
+			return q
+		})
+	}
+
+	return query
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/encryption"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+func TestApplyTicketFilter(t *testing.T) {
+	// the queries are only formatted, the connector never connects
+	db := bun.NewDB(sql.OpenDB(pgdriver.NewConnector()), pgdialect.New())
+	db.RegisterModel((*models.LabelsToTickets)(nil))
+	if err := encryption.SetKeys(base64.StdEncoding.EncodeToString(make([]byte, encryption.KeySize)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	text := func(s string) *string { return &s }
+
+	tokens, err := encryption.BlindIndex("Beamer")
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("BlindIndex() = %v, %v", tokens, err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *model.TicketFilter
+		want   string
+	}{
+		{
+			name:   "no filter",
+			filter: nil,
+			want:   "",
+		},
+		{
+			name:   "blank text",
+			filter: &model.TicketFilter{Text: text("  ")},
+			want:   "",
+		},
+		{
+			name:   "ids and states",
+			filter: &model.TicketFilter{Ids: []string{"a", "b"}, States: []model.TicketState{model.TicketStateNew, model.TicketStateClosed}},
+			want:   `(ticket.id IN ('a', 'b')) AND (ticket.state IN ('NEW', 'CLOSED'))`,
+		},
+		{
+			name:   "labels and assignees",
+			filter: &model.TicketFilter{LabelIDs: []string{"l"}, AssigneeIDs: []string{"u"}},
+			want:   `(ticket.id IN (SELECT ticket_id FROM labels_to_tickets WHERE label_id IN ('l'))) AND (ticket.assignee_id IN ('u'))`,
+		},
+		{
+			name: "dates",
+			filter: &model.TicketFilter{
+				CreatedAfter:       &date,
+				CreatedBefore:      &date,
+				LastModifiedAfter:  &date,
+				LastModifiedBefore: &date,
+			},
+			want: `(ticket.created_at >= '2026-01-02 03:04:05+00:00') AND (ticket.created_at < '2026-01-02 03:04:05+00:00') AND ` +
+				`(ticket.last_modified >= '2026-01-02 03:04:05+00:00') AND (ticket.last_modified < '2026-01-02 03:04:05+00:00')`,
+		},
+		{
+			name:   "text",
+			filter: &model.TicketFilter{Text: text(" Beamer ")},
+			want: `((ticket.title ILIKE '%Beamer%') OR (ticket.original_title ILIKE '%Beamer%') OR ` +
+				`(ticket.text_tokens @> '{"` + tokens[0] + `"}') OR (ticket.note_tokens @> '{"` + tokens[0] + `"}'))`,
+		},
+		{
+			name:   "like wildcards are escaped",
+			filter: &model.TicketFilter{Text: text(`%_\`)},
+			want:   `((ticket.title ILIKE '%\%\_\\%') OR (ticket.original_title ILIKE '%\%\_\\%'))`,
+		},
+		{
+			// words too short for the blind index only search the titles
+			name:   "filters are combined",
+			filter: &model.TicketFilter{States: []model.TicketState{model.TicketStateNew}, Text: text("x")},
+			want:   `(ticket.state IN ('NEW')) AND ((ticket.title ILIKE '%x%') OR (ticket.original_title ILIKE '%x%'))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := ApplyTicketFilter(db.NewSelect().Model((*models.Ticket)(nil)), tt.filter).String()
+
+			// only the conditions of the filter are compared, not the one excluding deleted tickets
+			_, where, _ := strings.Cut(query, " WHERE ")
+			where = strings.TrimSuffix(strings.TrimSuffix(where, `"ticket"."deleted_at" IS NULL`), " AND ")
+
+			if where != tt.want {
+				t.Errorf("got the conditions\n%v\nwant\n%v", where, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+// AnonymizedTitle replaces the titles of tickets anonymized by the retention policy
+const AnonymizedTitle = "Anonymized"
+
+// rows holding what was written about a ticket, its labels are not part of them
+var ticketContent = []interface{}{
+	(*models.TicketComment)(nil),
+	(*models.TicketMessage)(nil),
+	(*models.TicketEvent)(nil),
+	(*models.MergedTicket)(nil),
+	(*models.TicketAttachment)(nil),
+	// the payloads contain the ticket title
+	(*models.WebhookDelivery)(nil),
+}
+
+// DeleteTicketDependents removes all rows referencing the given tickets,
+// it has to run before the tickets themselves are deleted
+func DeleteTicketDependents(ctx context.Context, db bun.IDB, ids []string) error {
+	if err := deleteTicketRows(ctx, db, ids, ticketContent); err != nil {
+		return err
+	}
+
+	_, err := db.NewDelete().Model((*models.LabelsToTickets)(nil)).
+		Where("ticket_id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	return err
+}
+
+// AnonymizeTickets removes the texts, conversation, history and attachment rows of the given
+// tickets. Their state, labels and dates are kept, so they still count in statistics.
+// The attachment files have to be deleted from the storage by the caller.
+func AnonymizeTickets(ctx context.Context, db bun.IDB, ids []string) error {
+	if err := deleteTicketRows(ctx, db, ids, ticketContent); err != nil {
+		return err
+	}
+
+	_, err := db.NewUpdate().Model((*models.Ticket)(nil)).
+		Set("title = ?", AnonymizedTitle).
+		Set("original_title = ?", AnonymizedTitle).
+		Set("text = ''").
+		Set("note = ''").
+		Set("text_tokens = '{}'").
+		Set("note_tokens = '{}'").
+		Set("tracking_code_hash = NULL").
+		Set("assignee_id = NULL").
+		Set("anonymized_at = ?", time.Now()).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	return err
+}
+
+func deleteTicketRows(ctx context.Context, db bun.IDB, ids []string, dependents []interface{}) error {
+	commentIDs := db.NewSelect().Model((*models.TicketComment)(nil)).
+		Column("id").
+		Where("ticket_id IN (?)", bun.In(ids))
+
+	if _, err := db.NewDelete().Model((*models.TicketCommentRevision)(nil)).
+		Where("comment_id IN (?)", commentIDs).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	for _, dependent := range dependents {
+		if _, err := db.NewDelete().Model(dependent).
+			Where("ticket_id IN (?)", bun.In(ids)).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
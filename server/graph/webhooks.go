@@ -0,0 +1,29 @@
+package graph
+
+import (
+	"fmt"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+)
+
+// webhookEvents checks that at least one event is selected and removes duplicates
+func webhookEvents(selected []model.WebhookEvent) ([]string, error) {
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("select at least one event")
+	}
+
+	seen := make(map[model.WebhookEvent]struct{})
+	var result []string
+	for _, e := range selected {
+		if !e.IsValid() {
+			return nil, fmt.Errorf("unknown event %v", e)
+		}
+		if _, ok := seen[e]; ok {
+			continue
+		}
+		seen[e] = struct{}{}
+		result = append(result, string(e))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+func readCSV(r io.Reader, fn func(Row) error) error {
+	buffered := bufio.NewReader(r)
+
+	// spreadsheet programs like to start files with a byte order mark
+	if bom, err := buffered.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		_, _ = buffered.Discard(3)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = guessComma(buffered)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("the file is empty")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = columnKey(name)
+	}
+	if !slices.Contains(columns, "title") {
+		return fmt.Errorf("the header has no title column")
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := fn(Row{Number: parseErr.StartLine, Err: parseErr.Err}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := Row{Number: line}
+
+		if len(record) != len(columns) {
+			row.Err = fmt.Errorf("expected %v fields, got %v", len(columns), len(record))
+		}
+		for i := 0; row.Err == nil && i < len(record); i++ {
+			row.Err = row.set(columns[i], unescapeFormula(record[i]))
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// guessComma reads semicolons as separator if the header has no commas,
+// which is what spreadsheet programs with German settings write
+func guessComma(r *bufio.Reader) rune {
+	peek, _ := r.Peek(4096)
+	header, _, _ := bytes.Cut(peek, []byte("\n"))
+
+	if bytes.ContainsRune(header, ';') && !bytes.ContainsRune(header, ',') {
+		return ';'
+	}
+	return ','
+}
+
+// unescapeFormula removes the quote the export puts in front of values looking like formulas
+func unescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
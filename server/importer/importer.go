@@ -0,0 +1,150 @@
+// Package importer reads tickets of other systems from CSV and JSON files. The columns
+// are named like the ones of the export, so exported files can be imported again.
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	FormatCSV = "CSV"
+	// FormatJSON is a JSON array of objects or one object per line
+	FormatJSON = "JSON"
+)
+
+// Row is a ticket read from a file. Fields missing in the file are empty.
+type Row struct {
+	// Number is the line in CSV files and the position of the object in JSON files
+	Number int
+	// ID is the ID of an exported ticket, it is kept so the ticket is not imported twice
+	ID            string
+	Title         string
+	OriginalTitle string
+	Text          string
+	Note          string
+	State         string
+	Labels        []string
+	CreatedAt     time.Time
+	LastModified  time.Time
+	// Err is set if the row could not be read, the other rows are still read
+	Err error
+}
+
+// Read calls fn with every row of the file, in order. Errors of single rows are passed
+// in Row.Err, the returned error means that the rest of the file could not be read.
+func Read(format string, r io.Reader, fn func(Row) error) error {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, fn)
+	case FormatJSON:
+		return readJSON(r, fn)
+	default:
+		return fmt.Errorf("unknown import format %v", format)
+	}
+}
+
+// FormatOf guesses the format from the extension of a file name
+func FormatOf(fileName string) (string, error) {
+	name := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(name, ".csv"):
+		return FormatCSV, nil
+	case strings.HasSuffix(name, ".json"), strings.HasSuffix(name, ".jsonl"):
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %v, use .csv, .json or .jsonl", fileName)
+	}
+}
+
+// columnKey normalizes headers like "Created At", "created-at" or "createdAt" to created_at
+func columnKey(name string) string {
+	var key strings.Builder
+	previous := ' '
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r == ' ' || r == '-':
+			key.WriteRune('_')
+		case unicode.IsUpper(r):
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) {
+				key.WriteRune('_')
+			}
+			key.WriteRune(unicode.ToLower(r))
+		default:
+			key.WriteRune(r)
+		}
+		previous = r
+	}
+	return key.String()
+}
+
+// timeLayouts are tried in order, the dates of typed notes are often written the German way
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04",
+	"02.01.2006",
+}
+
+// parseTime reads times without zone in the local time zone, an empty value is the zero time
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// splitLabels reads labels joined like in the export
+func splitLabels(value string) []string {
+	var labels []string
+	for _, label := range strings.Split(value, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// set assigns a value of a column to the row, unknown columns are ignored
+func (row *Row) set(column, value string) error {
+	var err error
+
+	switch column {
+	case "id":
+		row.ID = strings.TrimSpace(value)
+	case "title":
+		row.Title = value
+	case "original_title":
+		row.OriginalTitle = value
+	case "text":
+		row.Text = value
+	case "note":
+		row.Note = value
+	case "state":
+		row.State = strings.ToUpper(strings.TrimSpace(value))
+	case "labels":
+		row.Labels = splitLabels(value)
+	case "created_at":
+		row.CreatedAt, err = parseTime(value)
+	case "last_modified":
+		row.LastModified, err = parseTime(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%v: %w", column, err)
+	}
+	return nil
+}
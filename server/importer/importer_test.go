@@ -0,0 +1,259 @@
+package importer
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, format, data string) ([]Row, error) {
+	t.Helper()
+
+	var rows []Row
+	err := Read(format, strings.NewReader(data), func(row Row) error {
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+func TestColumnKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"title", "title"},
+		{"Created At", "created_at"},
+		{"created-at", "created_at"},
+		{"createdAt", "created_at"},
+		{"lastModified", "last_modified"},
+		{"  Original Title ", "original_title"},
+		{"ID", "id"},
+		{"originalTitle2", "original_title2"},
+		{"label2Name", "label2_name"},
+	}
+
+	for _, tt := range tests {
+		if got := columnKey(tt.name); got != tt.want {
+			t.Errorf("columnKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "  ", want: time.Time{}},
+		{value: "2026-03-04T05:06:07Z", want: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)},
+		{value: "2026-03-04T05:06:07.5+02:00", want: time.Date(2026, 3, 4, 3, 6, 7, 5e8, time.UTC)},
+		{value: "2026-03-04T05:06:07", want: time.Date(2026, 3, 4, 5, 6, 7, 0, time.Local)},
+		{value: "2026-03-04 05:06:07", want: time.Date(2026, 3, 4, 5, 6, 7, 0, time.Local)},
+		{value: "2026-03-04 05:06", want: time.Date(2026, 3, 4, 5, 6, 0, 0, time.Local)},
+		{value: "2026-03-04", want: time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local)},
+		{value: "04.03.2026 05:06", want: time.Date(2026, 3, 4, 5, 6, 0, 0, time.Local)},
+		{value: " 04.03.2026 ", want: time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local)},
+		{value: "4.3.2026", wantErr: true},
+		{value: "03/04/2026", wantErr: true},
+		{value: "gestern", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTime(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Row
+		errs    []int
+		wantErr bool
+	}{
+		{
+			name: "export",
+			data: "\xef\xbb\xbfid,Title,Labels,State,Created At,unknown\n" +
+				"3f1c2a9e-0000-4000-8000-000000000001,'=1+1,\"Hörsaal, Mensa\",closed,2026-03-04,x\n",
+			want: []Row{{
+				Number:    2,
+				ID:        "3f1c2a9e-0000-4000-8000-000000000001",
+				Title:     "=1+1",
+				Labels:    []string{"Hörsaal", "Mensa"},
+				State:     "CLOSED",
+				CreatedAt: time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local),
+			}},
+		},
+		{
+			name: "semicolons",
+			data: "title;text;note\nBeamer;\"Zeile 1\nZeile 2\";'-Notiz\n",
+			want: []Row{{Number: 2, Title: "Beamer", Text: "Zeile 1\nZeile 2", Note: "-Notiz"}},
+		},
+		{
+			name: "rows with errors are passed on",
+			data: "title,created_at\nA,gestern\nB\nC,2026-03-04\n",
+			want: []Row{
+				{Number: 2, Title: "A"},
+				{Number: 3},
+				{Number: 4, Title: "C", CreatedAt: time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local)},
+			},
+			errs: []int{2, 3},
+		},
+		{
+			name:    "no title column",
+			data:    "text\nHallo\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			data:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readAll(t, FormatCSV, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			compareRows(t, rows, tt.want, tt.errs)
+		})
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Row
+		errs    []int
+		wantErr bool
+	}{
+		{
+			name: "array",
+			data: `[{"title": "A", "labels": ["Hörsaal", " "], "createdAt": "2026-03-04", "id": "x"},
+				{"title": "B", "labels": "Mensa, Bib", "note": null, "comments": 3}]`,
+			want: []Row{
+				{Number: 1, ID: "x", Title: "A", Labels: []string{"Hörsaal"}, CreatedAt: time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local)},
+				{Number: 2, Title: "B", Labels: []string{"Mensa", "Bib"}},
+			},
+		},
+		{
+			name: "lines",
+			data: "\xef\xbb\xbf{\"title\": \"A\"}\n{\"title\": \"B\", \"state\": \"in_progress\"}\n",
+			want: []Row{
+				{Number: 1, Title: "A"},
+				{Number: 2, Title: "B", State: "IN_PROGRESS"},
+			},
+		},
+		{
+			name: "rows with errors are passed on",
+			data: `[{"title": 1}, "text", {"created_at": "gestern"}, {"title": "D"}]`,
+			want: []Row{
+				{Number: 1},
+				{Number: 2},
+				{Number: 3},
+				{Number: 4, Title: "D"},
+			},
+			errs: []int{1, 2, 3},
+		},
+		{
+			name:    "broken syntax",
+			data:    `[{"title": "A"}, {"title": `,
+			want:    []Row{{Number: 1, Title: "A"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			data:    "  \n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readAll(t, FormatJSON, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			compareRows(t, rows, tt.want, tt.errs)
+		})
+	}
+}
+
+// compareRows checks that exactly the rows with the numbers in errs have an error,
+// and compares what was read besides it
+func compareRows(t *testing.T, got, want []Row, errs []int) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v rows, want %v: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		row := got[i]
+		if (row.Err != nil) != slices.Contains(errs, row.Number) {
+			t.Errorf("row %v has the error %v", row.Number, row.Err)
+		}
+
+		row.Err = nil
+		if !reflect.DeepEqual(row, want[i]) {
+			t.Errorf("row %v is %+v, want %+v", i+1, row, want[i])
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     string
+		wantErr  bool
+	}{
+		{fileName: "tickets.csv", want: FormatCSV},
+		{fileName: "Export.CSV", want: FormatCSV},
+		{fileName: "tickets.json", want: FormatJSON},
+		{fileName: "tickets.jsonl", want: FormatJSON},
+		{fileName: "tickets.xlsx", wantErr: true},
+		{fileName: "tickets", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := FormatOf(tt.fileName)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("FormatOf(%q) = %q, %v, want %q", tt.fileName, got, err, tt.want)
+		}
+	}
+}
+
+func TestUnescapeFormula(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"'=1+1", "=1+1"},
+		{"'+49", "+49"},
+		{"'@SUM", "@SUM"},
+		// only quotes the export put in front of formulas are removed
+		{"'quoted'", "'quoted'"},
+		{"'", "'"},
+		{"=1+1", "=1+1"},
+	}
+
+	for _, tt := range tests {
+		if got := unescapeFormula(tt.value); got != tt.want {
+			t.Errorf("unescapeFormula(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+func readJSON(r io.Reader, fn func(Row) error) error {
+	buffered := bufio.NewReader(r)
+	decoder := json.NewDecoder(buffered)
+
+	first, err := firstByte(buffered)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("the file is empty")
+	}
+	if err != nil {
+		return err
+	}
+
+	// an array is read element by element, so it is never decoded as a whole
+	array := first == '['
+	if array {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+	}
+
+	for number := 1; ; number++ {
+		if array && !decoder.More() {
+			_, err := decoder.Token()
+			return err
+		}
+
+		var object map[string]json.RawMessage
+		err := decoder.Decode(&object)
+		if !array && errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			// the value was skipped, the next one can still be read
+			if err := fn(Row{Number: number, Err: fmt.Errorf("expected an object")}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("object %v: %w", number, err)
+		}
+
+		row := Row{Number: number}
+		for key, value := range object {
+			if row.Err = row.setJSON(columnKey(key), value); row.Err != nil {
+				break
+			}
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// setJSON accepts strings and null for all columns, and arrays of strings for labels
+func (row *Row) setJSON(column string, raw json.RawMessage) error {
+	if column == "labels" && strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+		var labels []string
+		if err := json.Unmarshal(raw, &labels); err != nil {
+			return fmt.Errorf("labels: expected an array of strings")
+		}
+		for _, label := range labels {
+			if label = strings.TrimSpace(label); label != "" {
+				row.Labels = append(row.Labels, label)
+			}
+		}
+		return nil
+	}
+
+	var value *string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		// unknown columns, e.g. the ones of the export which are not imported, may have other types
+		if !isColumn(column) {
+			return nil
+		}
+		return fmt.Errorf("%v: expected a string", column)
+	}
+	if value == nil {
+		return nil
+	}
+	return row.set(column, *value)
+}
+
+func isColumn(column string) bool {
+	switch column {
+	case "id", "title", "original_title", "text", "note", "state", "labels", "created_at", "last_modified":
+		return true
+	}
+	return false
+}
+
+// firstByte peeks at the first byte which is not whitespace or part of a byte order mark
+func firstByte(r *bufio.Reader) (byte, error) {
+	if bom, err := r.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		_, _ = r.Discard(3)
+	}
+
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			return b[0], nil
+		}
+		_, _ = r.Discard(1)
+	}
+}
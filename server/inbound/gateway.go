@@ -0,0 +1,162 @@
+package inbound
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/mailer"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+)
+
+// RateLimitOperation is limited per sending server by the setting RATE_LIMIT_INBOUND_MAIL
+const RateLimitOperation = "INBOUND_MAIL"
+
+const (
+	noSubjectTitle      = "(ohne Betreff)"
+	confirmationSubject = "Dein Ticket im Kummerkasten"
+)
+
+// Gateway turns mails to Address, or a plus-address of it, into tickets
+type Gateway struct {
+	Resolver *graph.Resolver
+	Limiter  *middleware.RateLimiter
+	Address  string
+	// KeepSender adds the sender address to the ticket text and keeps signatures,
+	// by default both are removed so that tickets stay anonymous like the ones of the form
+	KeepSender bool
+
+	// spfResolver looks up the SPF records of senders, net.DefaultResolver if nil
+	spfResolver dnsResolver
+}
+
+// Recipient accepts Address and its plus-addresses
+func (g *Gateway) Recipient(address string) bool {
+	_, ok := g.tag(address)
+	return ok
+}
+
+// tag returns "lehre" for kummerkasten+lehre@example.org
+func (g *Gateway) tag(address string) (string, bool) {
+	local, domain, ok := strings.Cut(address, "@")
+	wantLocal, wantDomain, _ := strings.Cut(g.Address, "@")
+	if !ok || !strings.EqualFold(domain, wantDomain) {
+		return "", false
+	}
+
+	local, tag, _ := strings.Cut(local, "+")
+	if !strings.EqualFold(local, wantLocal) {
+		return "", false
+	}
+	return tag, true
+}
+
+// Handle creates the ticket, validation errors are returned to the sending server,
+// which passes them on to the sender in its bounce
+func (g *Gateway) Handle(ctx context.Context, envelope Envelope, data []byte) error {
+	ctx = g.Limiter.WithClient(ctx, envelope.RemoteIP)
+	if !g.Limiter.Allow(ctx, RateLimitOperation) {
+		return &Error{Code: 450, Message: "4.7.1 too many messages, try again later"}
+	}
+
+	msg, err := parseMessage(data)
+	if err != nil {
+		return &Error{Code: 550, Message: "5.6.0 message could not be read: " + err.Error()}
+	}
+
+	if msg.Text == "" && len(msg.Attachments) == 0 {
+		return &Error{Code: 550, Message: "5.6.0 the message is empty"}
+	}
+
+	var tags []string
+	for _, to := range envelope.To {
+		if tag, _ := g.tag(to); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	labels, err := g.Resolver.InboundMailLabels(ctx, tags)
+	if err != nil {
+		return err
+	}
+
+	text := msg.Text
+	if g.KeepSender {
+		text = fmt.Sprintf("%v\n\nAbsender: %v", text, msg.From)
+	} else {
+		text = stripSignature(text)
+	}
+
+	title := msg.Subject
+	if title == "" {
+		title = noSubjectTitle
+	}
+
+	var uploads []*graphql.Upload
+	for _, a := range msg.Attachments {
+		uploads = append(uploads, &graphql.Upload{
+			File:        bytes.NewReader(a.Data),
+			Filename:    a.FileName,
+			Size:        int64(len(a.Data)),
+			ContentType: a.ContentType,
+		})
+	}
+
+	ticket, err := g.Resolver.SubmitTicket(ctx, graph.TicketSubmission{
+		Title:       truncate(title, graph.MaxTitleLength),
+		Text:        text,
+		Labels:      labels,
+		Attachments: uploads,
+		Source:      model.TicketSourceMail,
+	})
+	if err != nil && ticket == nil {
+		if errors.Is(err, graph.ErrInternal) {
+			return err
+		}
+		return &Error{Code: 550, Message: "5.6.0 " + err.Error()}
+	}
+
+	// once the ticket exists the mail is accepted, the sending server would create it again on retries
+	if err != nil {
+		log.Printf("Created ticket %v from an inbound mail incompletely: %v", ticket.ID, err)
+	} else {
+		log.Printf("Created ticket %v from an inbound mail", ticket.ID)
+	}
+
+	// only senders proven by SPF are answered, with a fixed text, so that forged senders cannot
+	// make the server send anything to other people
+	if ticket.TrackingCode != nil && !msg.Automatic && senderVerified(ctx, g.dns(), envelope) {
+		g.Resolver.Mailer.Enqueue(mailer.Message{
+			To:      envelope.From,
+			Subject: confirmationSubject,
+			Body:    confirmation(*ticket.TrackingCode),
+		})
+	}
+
+	return nil
+}
+
+func (g *Gateway) dns() dnsResolver {
+	if g.spfResolver == nil {
+		return net.DefaultResolver
+	}
+	return g.spfResolver
+}
+
+// confirmation tells the sender the tracking code, their address is not stored
+func confirmation(trackingCode string) string {
+	var b strings.Builder
+	b.WriteString("Hallo,\n\ndeine Mail ist als Ticket im Kummerkasten der Fachschaft eingegangen.\n\n")
+	fmt.Fprintf(&b, "Mit dem Tracking-Code %v kannst du den Status verfolgen und uns antworten:\n", trackingCode)
+	fmt.Fprintf(&b, "%v\n\n", utils.EnvConfig.PublicURL("/"))
+	b.WriteString("Deine Mail-Adresse wird nicht gespeichert. Bewahre den Code gut auf, wir können ihn dir nicht erneut schicken.\n")
+	return b.String()
+}
@@ -0,0 +1,229 @@
+package inbound
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const maxPartDepth = 5
+
+// Message is the content of an inbound mail that can end up in a ticket
+type Message struct {
+	Subject string
+	Text    string
+	// From is the address in the From header, it is only added to the text if the sender is kept
+	From        string
+	Attachments []Attachment
+	// Automatic is set for auto replies, bounces and mailing lists, which must not be answered
+	Automatic bool
+}
+
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+func parseMessage(data []byte) (*Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := wordDecoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	result := &Message{
+		Subject: strings.TrimSpace(strings.Join(strings.Fields(subject), " ")),
+	}
+
+	if from, err := msg.Header.AddressList("From"); err == nil && len(from) > 0 {
+		result.From = from[0].Address
+	}
+
+	autoSubmitted := strings.ToLower(msg.Header.Get("Auto-Submitted"))
+	precedence := strings.ToLower(msg.Header.Get("Precedence"))
+	result.Automatic = (autoSubmitted != "" && autoSubmitted != "no") ||
+		precedence == "bulk" || precedence == "list" || precedence == "junk" ||
+		msg.Header.Get("List-Id") != ""
+
+	var htmlText string
+	if err := result.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0, &htmlText); err != nil {
+		return nil, err
+	}
+	if result.Text == "" && htmlText != "" {
+		result.Text = htmlToText(htmlText)
+	}
+	result.Text = normalizeText(result.Text)
+
+	return result, nil
+}
+
+// walk collects the first plain text part, the first HTML part as fallback and the images
+func (m *Message) walk(header textproto.MIMEHeader, body io.Reader, depth int, htmlText *string) error {
+	if depth > maxPartDepth {
+		return nil
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.walk(part.Header, part, depth+1, htmlText); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+
+	switch {
+	case mediaType == "text/plain" && disposition != "attachment" && m.Text == "":
+		text, err := readText(body, params["charset"])
+		if err != nil {
+			return err
+		}
+		m.Text = text
+	case mediaType == "text/html" && disposition != "attachment" && *htmlText == "":
+		text, err := readText(body, params["charset"])
+		if err != nil {
+			return err
+		}
+		*htmlText = text
+	case mediaType == "image/jpeg" || mediaType == "image/png":
+		// larger images are rejected by the validation of the ticket anyway
+		data, err := io.ReadAll(io.LimitReader(body, 10<<20))
+		if err != nil {
+			return err
+		}
+		fileName := dispositionParams["filename"]
+		if fileName == "" {
+			fileName = params["name"]
+		}
+		m.Attachments = append(m.Attachments, Attachment{FileName: fileName, ContentType: mediaType, Data: data})
+	}
+
+	return nil
+}
+
+func readText(body io.Reader, charset string) (string, error) {
+	reader, err := charsetReader(charset, body)
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(data), "�"), nil
+}
+
+// charsetReader converts the charsets mail clients still use besides UTF-8 to UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		// like browsers, latin1 is read as windows-1252, mails labelled latin1 often use its quotes and dashes
+		return charmap.Windows1252.NewDecoder().Reader(input), nil
+	case "iso-8859-15", "latin9":
+		return charmap.ISO8859_15.NewDecoder().Reader(input), nil
+	default:
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+}
+
+var (
+	htmlInvisible = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+	htmlBreaks    = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6])[^>]*>`)
+	htmlTags      = regexp.MustCompile(`<[^>]*>`)
+)
+
+func htmlToText(text string) string {
+	text = htmlInvisible.ReplaceAllString(text, "")
+	text = htmlBreaks.ReplaceAllString(text, "\n")
+	text = htmlTags.ReplaceAllString(text, "")
+	return html.UnescapeString(text)
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = strings.Join(lines, "\n")
+
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n"))
+}
+
+// stripSignature removes everything after the signature delimiter "-- ",
+// which usually holds the name and contact details of the sender
+func stripSignature(text string) string {
+	if strings.HasPrefix(text, "--\n") || text == "--" {
+		return ""
+	}
+	if i := strings.Index(text, "\n--\n"); i >= 0 {
+		text = text[:i]
+	}
+	if strings.HasSuffix(text, "\n--") {
+		text = strings.TrimSuffix(text, "\n--")
+	}
+	return strings.TrimSpace(text)
+}
+
+// truncate shortens text to at most max bytes without splitting a character
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+
+	const ellipsis = "…"
+	text = text[:max-len(ellipsis)]
+	for !utf8.ValidString(text) {
+		text = text[:len(text)-1]
+	}
+	return strings.TrimSpace(text) + ellipsis
+}
@@ -0,0 +1,209 @@
+package inbound
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCharsetReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{name: "no charset", charset: "", data: "Grüße", want: "Grüße"},
+		{name: "utf-8", charset: "UTF-8", data: "Grüße", want: "Grüße"},
+		{name: "latin1 umlauts", charset: "iso-8859-1", data: "Gr\xfc\xdfe", want: "Grüße"},
+		{name: "windows-1252 euro", charset: "windows-1252", data: "5 \x80", want: "5 €"},
+		{name: "windows-1252 quotes and dashes", charset: "windows-1252", data: "\x84Hallo\x93 \x96 \x91ok\x92 \x85", want: "„Hallo“ – ‘ok’ …"},
+		{name: "latin1 labelled windows-1252", charset: "ISO-8859-1", data: "\x93Zitat\x94", want: "“Zitat”"},
+		{name: "latin9 euro", charset: "iso-8859-15", data: "5 \xa4", want: "5 €"},
+		{name: "unsupported", charset: "koi8-r", data: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := charsetReader(tt.charset, strings.NewReader(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		subject   string
+		text      string
+		from      string
+		images    []string
+		automatic bool
+	}{
+		{
+			name:    "plain text",
+			data:    "From: Student <student@example.org>\r\nSubject: Beamer\r\n\r\nDer Beamer   \r\nist kaputt.\r\n\r\n\r\n\r\nDanke\r\n",
+			subject: "Beamer",
+			text:    "Der Beamer\nist kaputt.\n\nDanke",
+			from:    "student@example.org",
+		},
+		{
+			name:    "encoded subject and quoted-printable latin1",
+			data:    "Subject: =?ISO-8859-1?Q?Pr=FCfung?= =?UTF-8?B?w7xiZXI=?=\r\nContent-Type: text/plain; charset=iso-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nDie Pr=FCfung war =\r\nzu schwer.\r\n",
+			subject: "Prüfungüber",
+			text:    "Die Prüfung war zu schwer.",
+		},
+		{
+			name:    "base64 utf-8",
+			data:    "Subject: Test\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\nR3LDvMOfZQ==\r\n",
+			subject: "Test",
+			text:    "Grüße",
+		},
+		{
+			name: "alternative prefers plain text",
+			data: "Subject: Test\r\nContent-Type: multipart/alternative; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/html\r\n\r\n<p>html</p>\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nplain\r\n--b--\r\n",
+			subject: "Test",
+			text:    "plain",
+		},
+		{
+			name: "html only",
+			data: "Subject: Test\r\nContent-Type: multipart/alternative; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<html><head><style>p{}</style></head><body><p>Erste&nbsp;Zeile</p><div>zweite <b>Zeile</b> &amp; mehr</div><script>x()</script></body></html>\r\n--b--\r\n",
+			subject: "Test",
+			text:    "Erste Zeile\nzweite Zeile & mehr",
+		},
+		{
+			name: "images and other attachments",
+			data: "Subject: Fotos\r\nContent-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+				"--outer\r\nContent-Type: text/plain\r\n\r\nsiehe Anhang\r\n" +
+				"--outer\r\nContent-Type: image/png; name=raum.png\r\nContent-Transfer-Encoding: base64\r\n\r\niVBORw==\r\n" +
+				"--outer\r\nContent-Type: image/jpeg\r\nContent-Disposition: attachment; filename=\"tafel.jpg\"\r\n\r\njpeg\r\n" +
+				"--outer\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=\"plan.pdf\"\r\n\r\npdf\r\n" +
+				"--outer\r\nContent-Type: text/plain\r\nContent-Disposition: attachment; filename=\"notes.txt\"\r\n\r\nnot the text\r\n--outer--\r\n",
+			subject: "Fotos",
+			text:    "siehe Anhang",
+			images:  []string{"raum.png", "tafel.jpg"},
+		},
+		{
+			name:      "auto reply",
+			data:      "Subject: Abwesend\r\nAuto-Submitted: auto-replied\r\n\r\nIch bin im Urlaub.\r\n",
+			subject:   "Abwesend",
+			text:      "Ich bin im Urlaub.",
+			automatic: true,
+		},
+		{
+			name:      "mailing list",
+			data:      "Subject: Newsletter\r\nList-Id: <news.example.org>\r\n\r\nNeuigkeiten\r\n",
+			subject:   "Newsletter",
+			text:      "Neuigkeiten",
+			automatic: true,
+		},
+		{
+			name:    "not automatic",
+			data:    "Subject: Frage\r\nAuto-Submitted: no\r\n\r\nHallo\r\n",
+			subject: "Frage",
+			text:    "Hallo",
+		},
+		{
+			name:    "invalid utf-8",
+			data:    "Subject: Test\r\n\r\nkaputt \xff\r\n",
+			subject: "Test",
+			text:    "kaputt �",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := parseMessage([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("subject %q, want %q", msg.Subject, tt.subject)
+			}
+			if msg.Text != tt.text {
+				t.Errorf("text %q, want %q", msg.Text, tt.text)
+			}
+			if msg.From != tt.from {
+				t.Errorf("from %q, want %q", msg.From, tt.from)
+			}
+			if msg.Automatic != tt.automatic {
+				t.Errorf("automatic %v, want %v", msg.Automatic, tt.automatic)
+			}
+
+			var images []string
+			for _, a := range msg.Attachments {
+				images = append(images, a.FileName)
+			}
+			if strings.Join(images, ",") != strings.Join(tt.images, ",") {
+				t.Errorf("images %v, want %v", images, tt.images)
+			}
+		})
+	}
+}
+
+func TestParseMessageUnsupportedCharset(t *testing.T) {
+	if _, err := parseMessage([]byte("Subject: Test\r\nContent-Type: text/plain; charset=koi8-r\r\n\r\ntext\r\n")); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestStripSignature(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hallo\n\nGrüße", "Hallo\n\nGrüße"},
+		{"Hallo\n--\nMax Mustermann\nTel. 123", "Hallo"},
+		{"Hallo\n--", "Hallo"},
+		{"--\nnur Signatur", ""},
+		// dashes inside a line are no delimiter
+		{"Vorher -- nachher", "Vorher -- nachher"},
+	}
+
+	for _, tt := range tests {
+		if got := stripSignature(tt.text); got != tt.want {
+			t.Errorf("stripSignature(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"kurz", 10, "kurz"},
+		{"genau zehn", 10, "genau zehn"},
+		{"etwas zu lang", 10, "etwas z…"},
+		// the ü must not be split
+		{"Prüfungsamt", 6, "Pr…"},
+	}
+
+	for _, tt := range tests {
+		got := truncate(tt.text, tt.max)
+		if got != tt.want || len(got) > tt.max {
+			t.Errorf("truncate(%q, %v) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
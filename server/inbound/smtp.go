@@ -0,0 +1,329 @@
+package inbound
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	commandTimeout = 5 * time.Minute
+	dataTimeout    = 10 * time.Minute
+	maxLineLength  = 1000
+	maxRecipients  = 10
+	maxErrors      = 10
+	maxConnections = 20
+)
+
+// Envelope is what the sending server told about a message besides its content
+type Envelope struct {
+	RemoteIP string
+	From     string
+	To       []string
+}
+
+// Error is returned to the sending server with its code, other errors are reported as temporary failures
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Server is a minimal SMTP server, which only accepts mails to the recipients it knows
+// and hands them to Handler. It never relays messages.
+type Server struct {
+	Addr     string
+	Hostname string
+	// TLSConfig enables STARTTLS
+	TLSConfig *tls.Config
+	MaxSize   int64
+	Recipient func(address string) bool
+	Handler   func(ctx context.Context, envelope Envelope, data []byte) error
+}
+
+// ListenAndServe accepts connections until ctx is done
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	connections := make(chan struct{}, maxConnections)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		select {
+		case connections <- struct{}{}:
+		default:
+			_, _ = fmt.Fprintf(conn, "421 4.7.0 %s too many connections, try again later\r\n", s.Hostname)
+			_ = conn.Close()
+			continue
+		}
+
+		go func() {
+			defer func() { <-connections }()
+			s.serve(ctx, conn)
+		}()
+	}
+}
+
+type session struct {
+	server   *Server
+	conn     net.Conn
+	reader   *bufio.Reader
+	tls      bool
+	greeted  bool
+	envelope *Envelope
+}
+
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	sess := &session{
+		server: s,
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, maxLineLength),
+	}
+
+	// a bug in the handling of one mail must not take down the whole server
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("Panic while handling inbound mail: %v\n%s", err, debug.Stack())
+			sess.reply(451, "4.3.0 internal error")
+		}
+	}()
+
+	sess.reply(220, s.Hostname+" ESMTP Kummerkasten")
+
+	errorCount := 0
+	for errorCount < maxErrors {
+		_ = conn.SetDeadline(time.Now().Add(commandTimeout))
+
+		line, err := sess.readLine()
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				sess.reply(500, "5.5.2 line too long")
+			}
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		code, quit := sess.handle(ctx, strings.ToUpper(verb), strings.TrimSpace(arg))
+		if quit {
+			return
+		}
+		if code >= 500 {
+			errorCount++
+		}
+	}
+
+	sess.reply(421, "4.7.0 too many errors")
+}
+
+func (sess *session) readLine() (string, error) {
+	line, err := sess.reader.ReadSlice('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (sess *session) reply(code int, lines ...string) int {
+	var b strings.Builder
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		fmt.Fprintf(&b, "%d%s%s\r\n", code, separator, line)
+	}
+	_, _ = io.WriteString(sess.conn, b.String())
+	return code
+}
+
+// handle runs one command and returns the reply code, quit ends the session
+func (sess *session) handle(ctx context.Context, verb, arg string) (code int, quit bool) {
+	s := sess.server
+
+	switch verb {
+	case "HELO", "EHLO":
+		sess.greeted = true
+		sess.envelope = nil
+
+		if verb == "HELO" {
+			return sess.reply(250, s.Hostname), false
+		}
+
+		extensions := []string{s.Hostname, "8BITMIME", "SIZE " + strconv.FormatInt(s.MaxSize, 10)}
+		if s.TLSConfig != nil && !sess.tls {
+			extensions = append(extensions, "STARTTLS")
+		}
+		return sess.reply(250, extensions...), false
+
+	case "STARTTLS":
+		if s.TLSConfig == nil || sess.tls {
+			return sess.reply(502, "5.5.1 STARTTLS not available"), false
+		}
+		sess.reply(220, "2.0.0 ready to start TLS")
+
+		tlsConn := tls.Server(sess.conn, s.TLSConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			log.Printf("Inbound mail TLS handshake failed: %v", err)
+			return 0, true
+		}
+
+		// the client has to greet again after the handshake
+		sess.conn = tlsConn
+		sess.reader = bufio.NewReaderSize(tlsConn, maxLineLength)
+		sess.tls = true
+		sess.greeted = false
+		sess.envelope = nil
+		return 220, false
+
+	case "MAIL":
+		if !sess.greeted {
+			return sess.reply(503, "5.5.1 say hello first"), false
+		}
+		if sess.envelope != nil {
+			return sess.reply(503, "5.5.1 nested MAIL command"), false
+		}
+
+		from, params, ok := parsePath(arg, "FROM:")
+		if !ok {
+			return sess.reply(501, "5.5.4 syntax: MAIL FROM:<address>"), false
+		}
+		for _, param := range strings.Fields(params) {
+			if size, ok := strings.CutPrefix(strings.ToUpper(param), "SIZE="); ok {
+				if n, err := strconv.ParseInt(size, 10, 64); err == nil && n > s.MaxSize {
+					return sess.reply(552, "5.3.4 message too big"), false
+				}
+			}
+		}
+
+		sess.envelope = &Envelope{RemoteIP: remoteIP(sess.conn), From: from}
+		return sess.reply(250, "2.1.0 ok"), false
+
+	case "RCPT":
+		if sess.envelope == nil {
+			return sess.reply(503, "5.5.1 need MAIL first"), false
+		}
+		if len(sess.envelope.To) >= maxRecipients {
+			return sess.reply(452, "4.5.3 too many recipients"), false
+		}
+
+		to, _, ok := parsePath(arg, "TO:")
+		if !ok || to == "" {
+			return sess.reply(501, "5.5.4 syntax: RCPT TO:<address>"), false
+		}
+		if !s.Recipient(to) {
+			return sess.reply(550, "5.1.1 unknown recipient"), false
+		}
+
+		sess.envelope.To = append(sess.envelope.To, to)
+		return sess.reply(250, "2.1.5 ok"), false
+
+	case "DATA":
+		if sess.envelope == nil || len(sess.envelope.To) == 0 {
+			return sess.reply(503, "5.5.1 need RCPT first"), false
+		}
+		sess.reply(354, "end data with <CR><LF>.<CR><LF>")
+		_ = sess.conn.SetDeadline(time.Now().Add(dataTimeout))
+
+		envelope := *sess.envelope
+		sess.envelope = nil
+
+		var data bytes.Buffer
+		dotReader := textproto.NewReader(sess.reader).DotReader()
+		if _, err := io.Copy(&data, io.LimitReader(dotReader, s.MaxSize+1)); err != nil {
+			return 0, true
+		}
+		if int64(data.Len()) > s.MaxSize {
+			if _, err := io.Copy(io.Discard, dotReader); err != nil {
+				return 0, true
+			}
+			return sess.reply(552, "5.3.4 message too big"), false
+		}
+
+		if err := s.Handler(ctx, envelope, data.Bytes()); err != nil {
+			var smtpErr *Error
+			if errors.As(err, &smtpErr) {
+				return sess.reply(smtpErr.Code, smtpErr.Message), false
+			}
+			log.Printf("Failed to handle inbound mail: %v", err)
+			return sess.reply(451, "4.3.0 temporary failure, try again later"), false
+		}
+		return sess.reply(250, "2.0.0 ok"), false
+
+	case "RSET":
+		sess.envelope = nil
+		return sess.reply(250, "2.0.0 ok"), false
+
+	case "NOOP":
+		return sess.reply(250, "2.0.0 ok"), false
+
+	case "VRFY":
+		return sess.reply(252, "2.5.0 cannot verify"), false
+
+	case "QUIT":
+		sess.reply(221, "2.0.0 bye")
+		return 221, true
+
+	default:
+		return sess.reply(500, "5.5.2 unknown command"), false
+	}
+}
+
+// parsePath reads "FROM:<address> params", the address may be empty for bounces
+func parsePath(arg, prefix string) (address, params string, ok bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+
+	if !strings.HasPrefix(arg, "<") {
+		return "", "", false
+	}
+	end := strings.Index(arg, ">")
+	if end < 0 {
+		return "", "", false
+	}
+
+	return arg[1:end], strings.TrimSpace(arg[end+1:]), true
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
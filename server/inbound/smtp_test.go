@@ -0,0 +1,233 @@
+package inbound
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+type smtpStep struct {
+	// send is written as one line, or as the message after DATA if it contains line breaks.
+	// Nothing is sent if it is empty, only the next reply is read.
+	send string
+	code int
+}
+
+func runSMTP(t *testing.T, handler func(ctx context.Context, envelope Envelope, data []byte) error, steps []smtpStep) {
+	t.Helper()
+
+	server := &Server{
+		Hostname:  "kummerkasten.example.org",
+		MaxSize:   1024,
+		Recipient: func(address string) bool { return strings.HasPrefix(address, "kummerkasten") },
+		Handler:   handler,
+	}
+
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.serve(context.Background(), serverConn)
+	}()
+	defer func() {
+		_ = clientConn.Close()
+		<-done
+	}()
+	_ = clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	client := textproto.NewConn(clientConn)
+	if _, _, err := client.ReadResponse(220); err != nil {
+		t.Fatalf("greeting: %v", err)
+	}
+
+	for _, step := range steps {
+		var err error
+		switch {
+		case step.send == "":
+		case strings.Contains(step.send, "\n"):
+			w := client.DotWriter()
+			_, err = w.Write([]byte(step.send))
+			if err == nil {
+				err = w.Close()
+			}
+		default:
+			err = client.PrintfLine("%s", step.send)
+		}
+		if err != nil {
+			t.Fatalf("sending %q: %v", step.send, err)
+		}
+
+		code, message, err := client.ReadResponse(0)
+		if code != step.code {
+			t.Fatalf("%q got %v %v (%v), want %v", step.send, code, message, err, step.code)
+		}
+	}
+}
+
+func TestSMTPSession(t *testing.T) {
+	var envelopes []Envelope
+	var messages []string
+	handler := func(_ context.Context, envelope Envelope, data []byte) error {
+		if strings.Contains(string(data), "reject me") {
+			return &Error{Code: 550, Message: "5.7.1 rejected"}
+		}
+		if strings.Contains(string(data), "break me") {
+			return context.DeadlineExceeded
+		}
+		envelopes = append(envelopes, envelope)
+		messages = append(messages, string(data))
+		return nil
+	}
+
+	tests := []struct {
+		name  string
+		steps []smtpStep
+		want  []string
+	}{
+		{
+			name: "delivery",
+			steps: []smtpStep{
+				{"EHLO client.example.org", 250},
+				{"MAIL FROM:<student@example.org> SIZE=100", 250},
+				{"RCPT TO:<kummerkasten@example.org>", 250},
+				{"DATA", 354},
+				{"Subject: Test\r\n\r\nHallo\r\n.Punkt\r\n", 250},
+				{"QUIT", 221},
+			},
+			// the dots doubled by the client are removed and the lines end with LF
+			want: []string{"Subject: Test\n\nHallo\n.Punkt\n"},
+		},
+		{
+			name: "order of commands",
+			steps: []smtpStep{
+				{"MAIL FROM:<student@example.org>", 503},
+				{"HELO client.example.org", 250},
+				{"RCPT TO:<kummerkasten@example.org>", 503},
+				{"DATA", 503},
+				{"MAIL FROM:<student@example.org>", 250},
+				{"MAIL FROM:<student@example.org>", 503},
+				{"DATA", 503},
+				{"RSET", 250},
+				{"RCPT TO:<kummerkasten@example.org>", 503},
+				{"QUIT", 221},
+			},
+		},
+		{
+			name: "recipients and syntax",
+			steps: []smtpStep{
+				{"EHLO client.example.org", 250},
+				{"MAIL FROM:student@example.org", 501},
+				{"MAIL FROM:<>", 250},
+				{"RCPT TO:<someone@example.org>", 550},
+				{"RCPT TO:<>", 501},
+				{"RCPT TO:<kummerkasten+abc@example.org>", 250},
+				{"DATA", 354},
+				{"Subject: Bounce\r\n\r\n", 250},
+				{"VRFY kummerkasten", 252},
+				{"NOOP", 250},
+				{"FOO", 500},
+				{"QUIT", 221},
+			},
+			want: []string{"Subject: Bounce\n\n"},
+		},
+		{
+			name: "size limit",
+			steps: []smtpStep{
+				{"EHLO client.example.org", 250},
+				{"MAIL FROM:<student@example.org> SIZE=2048", 552},
+				{"MAIL FROM:<student@example.org>", 250},
+				{"RCPT TO:<kummerkasten@example.org>", 250},
+				{"DATA", 354},
+				{"Subject: Big\r\n\r\n" + strings.Repeat("x\r\n", 600), 552},
+				// the session goes on after the rejected message
+				{"NOOP", 250},
+				{"QUIT", 221},
+			},
+		},
+		{
+			name: "handler errors",
+			steps: []smtpStep{
+				{"EHLO client.example.org", 250},
+				{"MAIL FROM:<student@example.org>", 250},
+				{"RCPT TO:<kummerkasten@example.org>", 250},
+				{"DATA", 354},
+				{"Subject: No\r\n\r\nreject me\r\n", 550},
+				{"MAIL FROM:<student@example.org>", 250},
+				{"RCPT TO:<kummerkasten@example.org>", 250},
+				{"DATA", 354},
+				{"Subject: Later\r\n\r\nbreak me\r\n", 451},
+				{"QUIT", 221},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelopes, messages = nil, nil
+			runSMTP(t, handler, tt.steps)
+
+			if strings.Join(messages, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("handled %q, want %q", messages, tt.want)
+			}
+		})
+	}
+}
+
+func TestSMTPEnvelope(t *testing.T) {
+	var got Envelope
+	runSMTP(t, func(_ context.Context, envelope Envelope, _ []byte) error {
+		got = envelope
+		return nil
+	}, []smtpStep{
+		{"EHLO client.example.org", 250},
+		{"MAIL FROM:<student@example.org>", 250},
+		{"RCPT TO:<kummerkasten@example.org>", 250},
+		{"RCPT TO:<kummerkasten+abc@example.org>", 250},
+		{"DATA", 354},
+		{"Subject: Test\r\n\r\n", 250},
+		{"QUIT", 221},
+	})
+
+	if got.From != "student@example.org" || strings.Join(got.To, ",") != "kummerkasten@example.org,kummerkasten+abc@example.org" {
+		t.Errorf("envelope %+v", got)
+	}
+}
+
+func TestSMTPTooManyErrors(t *testing.T) {
+	steps := []smtpStep{{"EHLO client.example.org", 250}}
+	for i := 0; i < maxErrors-1; i++ {
+		steps = append(steps, smtpStep{"FOO", 500})
+	}
+	// the last error closes the session
+	steps = append(steps, smtpStep{"FOO", 500}, smtpStep{"", 421})
+
+	runSMTP(t, nil, steps)
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		arg     string
+		prefix  string
+		address string
+		params  string
+		ok      bool
+	}{
+		{"FROM:<a@example.org>", "FROM:", "a@example.org", "", true},
+		{"from: <a@example.org> SIZE=10 BODY=8BITMIME", "FROM:", "a@example.org", "SIZE=10 BODY=8BITMIME", true},
+		{"FROM:<>", "FROM:", "", "", true},
+		{"TO:<a@example.org>", "FROM:", "", "", false},
+		{"FROM:a@example.org", "FROM:", "", "", false},
+		{"FROM:<a@example.org", "FROM:", "", "", false},
+		{"FR", "FROM:", "", "", false},
+	}
+
+	for _, tt := range tests {
+		address, params, ok := parsePath(tt.arg, tt.prefix)
+		if address != tt.address || params != tt.params || ok != tt.ok {
+			t.Errorf("parsePath(%q) = %q, %q, %v, want %q, %q, %v", tt.arg, address, params, ok, tt.address, tt.params, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package inbound
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// spfResult is the outcome of an SPF check, see RFC 7208 section 2.6
+type spfResult int
+
+const (
+	spfNone spfResult = iota
+	spfNeutral
+	spfPass
+	spfFail
+	spfSoftFail
+	spfTempError
+	spfPermError
+)
+
+const (
+	// spfMaxLookups is the limit of mechanisms causing DNS queries per check
+	spfMaxLookups = 10
+	spfMaxMX      = 10
+)
+
+// dnsResolver is implemented by net.Resolver
+type dnsResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// senderVerified reports whether the SPF record of the domain of the envelope sender
+// allows the remote server to send its mails. Only then the sender is answered, as the
+// addresses of a mail can be forged by anyone.
+func senderVerified(ctx context.Context, resolver dnsResolver, envelope Envelope) bool {
+	_, domain, ok := strings.Cut(envelope.From, "@")
+	ip := net.ParseIP(envelope.RemoteIP)
+	if !ok || domain == "" || ip == nil {
+		return false
+	}
+
+	check := &spfCheck{resolver: resolver, ip: ip}
+	return check.run(ctx, strings.ToLower(domain)) == spfPass
+}
+
+// spfCheck evaluates the records of a domain and those they include. Macros and the
+// deprecated ptr mechanism are not supported, records using them never pass.
+type spfCheck struct {
+	resolver dnsResolver
+	ip       net.IP
+	lookups  int
+}
+
+func (c *spfCheck) run(ctx context.Context, domain string) spfResult {
+	record, result := c.record(ctx, domain)
+	if record == "" {
+		return result
+	}
+
+	var redirect string
+	for _, term := range strings.Fields(record)[1:] {
+		term = strings.ToLower(term)
+
+		if name, value, ok := strings.Cut(term, "="); ok && !strings.ContainsAny(name, ":/") {
+			if name == "redirect" {
+				redirect = value
+			}
+			// other modifiers like exp do not change the result
+			continue
+		}
+
+		qualifier := spfPass
+		switch term[0] {
+		case '+':
+			term = term[1:]
+		case '-':
+			qualifier, term = spfFail, term[1:]
+		case '~':
+			qualifier, term = spfSoftFail, term[1:]
+		case '?':
+			qualifier, term = spfNeutral, term[1:]
+		}
+
+		match, result := c.match(ctx, domain, term)
+		if result != spfNone {
+			return result
+		}
+		if match {
+			return qualifier
+		}
+	}
+
+	if redirect != "" {
+		if !c.lookup() || strings.Contains(redirect, "%") {
+			return spfPermError
+		}
+		if result := c.run(ctx, redirect); result != spfNone {
+			return result
+		}
+		return spfPermError
+	}
+
+	return spfNeutral
+}
+
+// record returns the SPF record of the domain, or the result if there is none
+func (c *spfCheck) record(ctx context.Context, domain string) (string, spfResult) {
+	txts, err := c.resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return "", spfNone
+		}
+		return "", spfTempError
+	}
+
+	var record string
+	for _, txt := range txts {
+		if lower := strings.ToLower(txt); lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+			if record != "" {
+				return "", spfPermError
+			}
+			record = txt
+		}
+	}
+	return record, spfNone
+}
+
+// match tells if the mechanism matches the remote server, the result is set if the check has to stop
+func (c *spfCheck) match(ctx context.Context, domain, mechanism string) (bool, spfResult) {
+	// the value follows a colon, a and mx may be followed by the prefix lengths directly, like a/24
+	name, value := mechanism, ""
+	if i := strings.IndexAny(mechanism, ":/"); i >= 0 {
+		name, value = mechanism[:i], strings.TrimPrefix(mechanism[i:], ":")
+	}
+	if strings.Contains(value, "%") {
+		return false, spfPermError
+	}
+
+	switch name {
+	case "all":
+		return true, spfNone
+
+	case "include":
+		if value == "" || !c.lookup() {
+			return false, spfPermError
+		}
+		switch c.run(ctx, value) {
+		case spfPass:
+			return true, spfNone
+		case spfFail, spfSoftFail, spfNeutral:
+			return false, spfNone
+		case spfTempError:
+			return false, spfTempError
+		default:
+			return false, spfPermError
+		}
+
+	case "ip4", "ip6":
+		network, ok := parseCIDR(value, name == "ip4")
+		if !ok {
+			return false, spfPermError
+		}
+		return network.Contains(c.ip), spfNone
+
+	case "a", "mx":
+		host, ip4Bits, ip6Bits, ok := splitDualCIDR(value)
+		if !ok || !c.lookup() {
+			return false, spfPermError
+		}
+		if host == "" {
+			host = domain
+		}
+
+		hosts := []string{host}
+		if name == "mx" {
+			mxs, err := c.resolver.LookupMX(ctx, host)
+			if err != nil && !isNotFound(err) {
+				return false, spfTempError
+			}
+			if len(mxs) > spfMaxMX {
+				return false, spfPermError
+			}
+			hosts = hosts[:0]
+			for _, mx := range mxs {
+				hosts = append(hosts, mx.Host)
+			}
+		}
+
+		for _, h := range hosts {
+			addrs, err := c.resolver.LookupIPAddr(ctx, h)
+			if err != nil && !isNotFound(err) {
+				return false, spfTempError
+			}
+			for _, addr := range addrs {
+				bits := ip6Bits
+				if addr.IP.To4() != nil {
+					bits = ip4Bits
+				}
+				if sameNetwork(addr.IP, c.ip, bits) {
+					return true, spfNone
+				}
+			}
+		}
+		return false, spfNone
+
+	case "exists":
+		if value == "" || !c.lookup() {
+			return false, spfPermError
+		}
+		addrs, err := c.resolver.LookupIPAddr(ctx, value)
+		if err != nil && !isNotFound(err) {
+			return false, spfTempError
+		}
+		return len(addrs) > 0, spfNone
+
+	default:
+		// ptr and unknown mechanisms
+		return false, spfPermError
+	}
+}
+
+// lookup counts a mechanism causing DNS queries and reports whether the limit allows it
+func (c *spfCheck) lookup() bool {
+	c.lookups++
+	return c.lookups <= spfMaxLookups
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// parseCIDR parses the value of ip4 and ip6 mechanisms, the prefix length is optional
+func parseCIDR(value string, ip4 bool) (*net.IPNet, bool) {
+	if !strings.Contains(value, "/") {
+		if ip4 {
+			value += "/32"
+		} else {
+			value += "/128"
+		}
+	}
+
+	ip, network, err := net.ParseCIDR(value)
+	if err != nil || (ip.To4() != nil) != ip4 {
+		return nil, false
+	}
+	return network, true
+}
+
+// splitDualCIDR splits "example.org/24//64" into the host and both prefix lengths
+func splitDualCIDR(value string) (host string, ip4Bits, ip6Bits int, ok bool) {
+	ip4Bits, ip6Bits = 32, 128
+
+	host, ip6, hasIP6 := strings.Cut(value, "//")
+	host, ip4, hasIP4 := strings.Cut(host, "/")
+
+	var err error
+	if hasIP4 {
+		if ip4Bits, err = strconv.Atoi(ip4); err != nil || ip4Bits < 0 || ip4Bits > 32 {
+			return "", 0, 0, false
+		}
+	}
+	if hasIP6 {
+		if ip6Bits, err = strconv.Atoi(ip6); err != nil || ip6Bits < 0 || ip6Bits > 128 {
+			return "", 0, 0, false
+		}
+	}
+	return host, ip4Bits, ip6Bits, true
+}
+
+func sameNetwork(a, b net.IP, bits int) bool {
+	if a4, b4 := a.To4(), b.To4(); a4 != nil || b4 != nil {
+		if a4 == nil || b4 == nil {
+			return false
+		}
+		mask := net.CIDRMask(bits, 32)
+		return a4.Mask(mask).Equal(b4.Mask(mask))
+	}
+
+	mask := net.CIDRMask(bits, 128)
+	return a.Mask(mask).Equal(b.Mask(mask))
+}
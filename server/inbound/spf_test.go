@@ -0,0 +1,130 @@
+package inbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+)
+
+type fakeDNS struct {
+	txt map[string][]string
+	ips map[string][]string
+	mx  map[string][]string
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (f fakeDNS) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if name == "broken.example" {
+		return nil, &net.DNSError{Err: "server failure", Name: name, IsTemporary: true}
+	}
+	if txt, ok := f.txt[name]; ok {
+		return txt, nil
+	}
+	return nil, notFound(name)
+}
+
+func (f fakeDNS) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := f.ips[host]
+	if !ok {
+		return nil, notFound(host)
+	}
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func (f fakeDNS) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	hosts, ok := f.mx[name]
+	if !ok {
+		return nil, notFound(name)
+	}
+	var mxs []*net.MX
+	for _, host := range hosts {
+		mxs = append(mxs, &net.MX{Host: host, Pref: 10})
+	}
+	return mxs, nil
+}
+
+func TestSenderVerified(t *testing.T) {
+	loop := fakeDNS{txt: map[string][]string{}}
+	for i := 0; i < 12; i++ {
+		loop.txt[fmt.Sprintf("loop%d.example", i)] = []string{fmt.Sprintf("v=spf1 include:loop%d.example -all", i+1)}
+	}
+	loop.txt["loop12.example"] = []string{"v=spf1 +all"}
+
+	dns := fakeDNS{
+		txt: map[string][]string{
+			"ip4.example":      {"some verification token", "v=spf1 ip4:192.0.2.0/24 -all"},
+			"ip6.example":      {"v=spf1 ip6:2001:db8::/32 -all"},
+			"a.example":        {"v=spf1 a/24 -all"},
+			"mx.example":       {"v=spf1 mx -all"},
+			"include.example":  {"v=spf1 include:ip4.example -all"},
+			"redirect.example": {"v=spf1 redirect=ip4.example"},
+			"softfail.example": {"v=spf1 ~all"},
+			"neutral.example":  {"v=spf1 ?all"},
+			"empty.example":    {"v=spf1"},
+			"twice.example":    {"v=spf1 +all", "v=spf1 -all"},
+			"macro.example":    {"v=spf1 exists:%{i}.spf.example -all"},
+			"ptr.example":      {"v=spf1 ptr -all"},
+			"upper.example":    {"V=SPF1 IP4:192.0.2.1 -ALL"},
+			"nospf.example":    {"google-site-verification=abc"},
+		},
+		ips: map[string][]string{
+			"a.example":    {"198.51.100.1"},
+			"mail.example": {"203.0.113.7", "2001:db8::25"},
+		},
+		mx: map[string][]string{
+			"mx.example": {"mail.example"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		dns      fakeDNS
+		from     string
+		remoteIP string
+		want     bool
+	}{
+		{"ip4 in network", dns, "student@ip4.example", "192.0.2.55", true},
+		{"ip4 outside network", dns, "student@ip4.example", "198.51.100.1", false},
+		{"ip6 in network", dns, "student@ip6.example", "2001:db8:1::1", true},
+		{"ip4 against ip6 record", dns, "student@ip6.example", "192.0.2.1", false},
+		{"a with prefix", dns, "student@a.example", "198.51.100.200", true},
+		{"a outside prefix", dns, "student@a.example", "198.51.101.1", false},
+		{"mx", dns, "student@mx.example", "203.0.113.7", true},
+		{"mx ip6", dns, "student@mx.example", "2001:db8::25", true},
+		{"mx other server", dns, "student@mx.example", "203.0.113.8", false},
+		{"include", dns, "student@include.example", "192.0.2.1", true},
+		{"include not matching", dns, "student@include.example", "203.0.113.1", false},
+		{"redirect", dns, "student@redirect.example", "192.0.2.1", true},
+		{"softfail", dns, "student@softfail.example", "192.0.2.1", false},
+		{"neutral", dns, "student@neutral.example", "192.0.2.1", false},
+		{"no mechanism", dns, "student@empty.example", "192.0.2.1", false},
+		{"two records", dns, "student@twice.example", "192.0.2.1", false},
+		{"macro", dns, "student@macro.example", "192.0.2.1", false},
+		{"ptr", dns, "student@ptr.example", "192.0.2.1", false},
+		{"upper case", dns, "student@UPPER.example", "192.0.2.1", true},
+		{"no spf record", dns, "student@nospf.example", "192.0.2.1", false},
+		{"no txt records", dns, "student@unknown.example", "192.0.2.1", false},
+		{"dns failure", dns, "student@broken.example", "192.0.2.1", false},
+		{"empty sender", dns, "", "192.0.2.1", false},
+		{"sender without domain", dns, "student", "192.0.2.1", false},
+		{"invalid remote ip", dns, "student@ip4.example", "unknown", false},
+		{"too many lookups", loop, "student@loop0.example", "192.0.2.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope := Envelope{RemoteIP: tt.remoteIP, From: tt.from}
+			if got := senderVerified(context.Background(), tt.dns, envelope); got != tt.want {
+				t.Errorf("senderVerified(%q, %q) = %v, want %v", tt.from, tt.remoteIP, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package maintenance
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/mailer"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/notifications"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultDigestStaleDays = 7
+
+	// a digest is skipped if the last one is more recent, e.g. after a restart on the same day
+	dailyDigestMinInterval  = 20 * time.Hour
+	weeklyDigestMinInterval = 6 * 24 * time.Hour
+)
+
+// SendDigests mails the users their daily digest, and on mondays the weekly one,
+// of new tickets, tickets stale in NEW and tickets closed since their last digest
+func SendDigests(ctx context.Context, r *graph.Resolver) error {
+	if r.Mailer == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	frequencies := []model.DigestFrequency{model.DigestFrequencyDaily}
+	if now.Weekday() == time.Monday {
+		frequencies = append(frequencies, model.DigestFrequencyWeekly)
+	}
+
+	var users []*models.User
+	if err := r.DB.NewSelect().Model(&users).
+		Where("digest_frequency IN (?)", bun.In(frequencies)).
+		Scan(ctx); err != nil {
+		log.Printf("Error fetching digest recipients: %v", err)
+		return err
+	}
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	var settings []*models.Setting
+	if err := r.DB.NewSelect().Model(&settings).
+		Where("key IN (?)", bun.In([]string{models.DigestTemplateKey, models.DigestStaleDaysKey})).
+		Scan(ctx); err != nil {
+		log.Printf("Error fetching digest settings: %v", err)
+		return err
+	}
+
+	templateText := ""
+	staleDays := defaultDigestStaleDays
+	for _, s := range settings {
+		switch s.Key {
+		case models.DigestTemplateKey:
+			templateText = s.Value
+		case models.DigestStaleDaysKey:
+			if days, err := strconv.Atoi(s.Value); err == nil && days > 0 {
+				staleDays = days
+			}
+		}
+	}
+
+	tmpl, err := notifications.ParseDigestTemplate(templateText)
+	if err != nil {
+		log.Printf("Invalid digest template, using the default one: %v", err)
+		if tmpl, err = notifications.ParseDigestTemplate(""); err != nil {
+			return err
+		}
+	}
+
+	for _, user := range users {
+		interval := dailyDigestMinInterval
+		since := now.AddDate(0, 0, -1)
+		if user.DigestFrequency == model.DigestFrequencyWeekly {
+			interval = weeklyDigestMinInterval
+			since = now.AddDate(0, 0, -7)
+		}
+
+		if !user.LastDigestAt.IsZero() {
+			if now.Sub(user.LastDigestAt) < interval {
+				continue
+			}
+			since = user.LastDigestAt
+		}
+
+		data, err := digestData(ctx, r, user, since, staleDays)
+		if err != nil {
+			log.Printf("Error collecting digest of user %v: %v", user.ID, err)
+			continue
+		}
+
+		if !data.Empty() {
+			var body strings.Builder
+			if err := tmpl.Execute(&body, data); err != nil {
+				log.Printf("Error rendering digest of user %v: %v", user.ID, err)
+				continue
+			}
+
+			r.Mailer.Enqueue(mailer.Message{
+				To:      user.Mail,
+				Subject: "Kummerkasten-Zusammenfassung",
+				Body:    body.String(),
+			})
+		}
+
+		if _, err := r.DB.NewUpdate().Model((*models.User)(nil)).
+			Set("last_digest_at = ?", now).
+			Where("id = ?", user.ID).
+			Exec(ctx); err != nil {
+			log.Printf("Error saving digest time of user %v: %v", user.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func digestData(ctx context.Context, r *graph.Resolver, user *models.User, since time.Time, staleDays int) (notifications.DigestData, error) {
+	data := notifications.DigestData{
+		Firstname:   user.Firstname,
+		Frequency:   string(user.DigestFrequency),
+		Since:       since,
+		StaleDays:   staleDays,
+		OverviewURL: utils.EnvConfig.PublicURL("/tickets"),
+	}
+
+	// only admins may see quarantined tickets
+	visible := func(q *bun.SelectQuery) *bun.SelectQuery {
+		q = q.Relation("Labels").Order("ticket.created_at ASC")
+		if user.Role != model.UserRoleAdmin {
+			q = q.Where("ticket.state != ?", model.TicketStateQuarantine)
+		}
+		return q
+	}
+
+	var newTickets, staleTickets, closedTickets []*models.Ticket
+
+	if err := r.DB.NewSelect().Model(&newTickets).
+		Apply(visible).
+		Where("ticket.created_at >= ?", since).
+		Scan(ctx); err != nil {
+		return data, err
+	}
+
+	if err := r.DB.NewSelect().Model(&staleTickets).
+		Apply(visible).
+		Where("ticket.state = ?", model.TicketStateNew).
+		Where("ticket.created_at < ?", time.Now().AddDate(0, 0, -staleDays)).
+		Scan(ctx); err != nil {
+		return data, err
+	}
+
+	if err := r.DB.NewSelect().Model(&closedTickets).
+		Apply(visible).
+		Where("ticket.state = ?", model.TicketStateClosed).
+		Where("ticket.id IN (?)", r.DB.NewSelect().Model((*models.TicketEvent)(nil)).
+			Column("ticket_id").
+			Where("type = ?", model.TicketEventTypeStateChanged).
+			Where("new_value = ?", model.TicketStateClosed).
+			Where("created_at >= ?", since)).
+		Scan(ctx); err != nil {
+		return data, err
+	}
+
+	data.New = toDigestTickets(newTickets)
+	data.Stale = toDigestTickets(staleTickets)
+	data.Closed = toDigestTickets(closedTickets)
+
+	return data, nil
+}
+
+func toDigestTickets(tickets []*models.Ticket) []notifications.DigestTicket {
+	var digestTickets []notifications.DigestTicket
+	for _, t := range tickets {
+		var labels []string
+		for _, l := range t.Labels {
+			labels = append(labels, l.Name)
+		}
+
+		digestTickets = append(digestTickets, notifications.DigestTicket{
+			Title:     t.Title,
+			State:     string(t.State),
+			Labels:    labels,
+			CreatedAt: t.CreatedAt,
+			URL:       utils.EnvConfig.PublicURL("/tickets/" + t.ID),
+		})
+	}
+	return digestTickets
+}
@@ -0,0 +1,115 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/utils"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+// PurgeTrashedTickets permanently deletes tickets which are in the trash for
+// longer than configured in the TRASH_RETENTION_DAYS setting
+func PurgeTrashedTickets(ctx context.Context, r *graph.Resolver) error {
+	var settings []*models.Setting
+	if err := r.DB.NewSelect().Model(&settings).
+		Where("key = ?", models.TrashRetentionDaysKey).
+		Scan(ctx); err != nil {
+		log.Printf("Error fetching trash retention: %v", err)
+		return err
+	}
+
+	if len(settings) == 0 {
+		return fmt.Errorf("setting %v is missing, not purging the trash", models.TrashRetentionDaysKey)
+	}
+
+	days, err := strconv.Atoi(settings[0].Value)
+	if err != nil || days < 1 {
+		return fmt.Errorf("setting %v has to be a positive number of days, not purging the trash", models.TrashRetentionDaysKey)
+	}
+
+	var ids []string
+	if err := r.DB.NewSelect().Model((*models.Ticket)(nil)).
+		Column("id").
+		WhereDeleted().
+		Where("deleted_at < ?", time.Now().AddDate(0, 0, -days)).
+		Scan(ctx, &ids); err != nil {
+		log.Printf("Error fetching trashed tickets: %v", err)
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := purgeTickets(ctx, r, ids); err != nil {
+		return err
+	}
+
+	log.Printf("Purged %v tickets from the trash", len(ids))
+
+	return nil
+}
+
+// purgeTickets deletes the tickets including everything referencing them and their attachment files
+func purgeTickets(ctx context.Context, r *graph.Resolver, ids []string) error {
+	storageKeys, err := attachmentStorageKeys(ctx, r, ids)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := utils.DeleteTicketDependents(ctx, tx, ids); err != nil {
+		log.Printf("Error deleting dependents of tickets: %v", err)
+		return err
+	}
+
+	if _, err := tx.NewDelete().Model((*models.Ticket)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		ForceDelete().
+		Exec(ctx); err != nil {
+		log.Printf("Error purging tickets: %v", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing ticket purge: %v", err)
+		return err
+	}
+
+	deleteAttachmentFiles(ctx, r, storageKeys)
+
+	return nil
+}
+
+func attachmentStorageKeys(ctx context.Context, r *graph.Resolver, ids []string) ([]string, error) {
+	var storageKeys []string
+	if err := r.DB.NewSelect().Model((*models.TicketAttachment)(nil)).
+		Column("storage_key").
+		Where("ticket_id IN (?)", bun.In(ids)).
+		Scan(ctx, &storageKeys); err != nil {
+		log.Printf("Error fetching attachments of tickets: %v", err)
+		return nil, err
+	}
+	return storageKeys, nil
+}
+
+// deleteAttachmentFiles runs after the rows are gone, a failure only leaves unreferenced files behind
+func deleteAttachmentFiles(ctx context.Context, r *graph.Resolver, storageKeys []string) {
+	for _, key := range storageKeys {
+		if err := r.Storage.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting attachment %v from storage: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package maintenance
+
+import (
+	"context"
+	"log"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/utils"
+)
+
+// ApplyRetentionPolicy deletes or anonymizes closed tickets which were not modified for longer
+// than configured in the RETENTION_DAYS setting, unless they have one of the exempt labels
+func ApplyRetentionPolicy(ctx context.Context, r *graph.Resolver) error {
+	policy, err := r.RetentionPolicy(ctx)
+	if err != nil {
+		log.Printf("Not applying the retention policy: %v", err)
+		return err
+	}
+
+	ids, err := r.RetentionCandidates(ctx, policy)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if policy.Action == model.RetentionActionDelete {
+		if err := purgeTickets(ctx, r, ids); err != nil {
+			return err
+		}
+
+		log.Printf("Deleted %v tickets due to the retention policy", len(ids))
+		return nil
+	}
+
+	storageKeys, err := attachmentStorageKeys(ctx, r, ids)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := utils.AnonymizeTickets(ctx, tx, ids); err != nil {
+		log.Printf("Error anonymizing tickets: %v", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing anonymization: %v", err)
+		return err
+	}
+
+	deleteAttachmentFiles(ctx, r, storageKeys)
+
+	log.Printf("Anonymized %v tickets due to the retention policy", len(ids))
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package maintenance
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+// webhookDeliveryRetention is how long finished deliveries stay in the delivery log
+const webhookDeliveryRetention = 30 * 24 * time.Hour
+
+func ClearWebhookDeliveries(ctx context.Context, r *graph.Resolver) error {
+	if _, err := r.DB.NewDelete().Model((*models.WebhookDelivery)(nil)).
+		Where("status != ?", model.WebhookDeliveryStatusPending).
+		Where("created_at < ?", time.Now().Add(-webhookDeliveryRetention)).
+		Exec(ctx); err != nil {
+		log.Printf("Error clearing webhook deliveries: %v", err)
+		return err
+	}
+
+	return nil
+}
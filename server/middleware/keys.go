@@ -5,4 +5,5 @@ type ctxKey string
 const (
 	WriterKey ctxKey = "writer"
 	UserKey   ctxKey = "user"
+	ClientKey ctxKey = "client"
 )
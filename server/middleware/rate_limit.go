@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/uptrace/bun"
+)
+
+// RateLimitSettingPrefix is prepended to the operation name to get the key of
+// the setting holding its limit, e.g. RATE_LIMIT_LOGIN with a value of "10/15m"
+const RateLimitSettingPrefix = "RATE_LIMIT_"
+
+const (
+	// the salt is replaced regularly, so hashes cannot be linked to clients over longer periods
+	saltRotationInterval = 24 * time.Hour
+	limitCacheDuration   = time.Minute
+	pruneInterval        = time.Minute
+)
+
+type rateLimit struct {
+	requests int
+	window   time.Duration
+	loadedAt time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimiter counts requests per client and operation in fixed windows.
+// Clients are only known by a salted hash of their IP, which is never stored.
+type RateLimiter struct {
+	db           *bun.DB
+	realIPHeader string
+
+	mu        sync.Mutex
+	salt      []byte
+	saltSince time.Time
+	lastPrune time.Time
+	limits    map[string]rateLimit
+	windows   map[string]*rateWindow
+}
+
+// NewRateLimiter creates a limiter reading its limits from the settings. If
+// realIPHeader is set, the client IP is taken from that header set by a reverse proxy.
+func NewRateLimiter(db *bun.DB, realIPHeader string) *RateLimiter {
+	return &RateLimiter{
+		db:           db,
+		realIPHeader: realIPHeader,
+		limits:       make(map[string]rateLimit),
+		windows:      make(map[string]*rateWindow),
+	}
+}
+
+// IdentifyClient stores the hashed client IP in the context for Allow
+func (l *RateLimiter) IdentifyClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(l.WithClient(r.Context(), l.clientIP(r))))
+	})
+}
+
+// WithClient stores the hashed IP in the context for Allow, for clients not connecting through HTTP
+func (l *RateLimiter) WithClient(ctx context.Context, ip string) context.Context {
+	client, err := l.hashClient(ip)
+	if err != nil {
+		log.Printf("Error hashing client: %v", err)
+		return ctx
+	}
+
+	return context.WithValue(ctx, ClientKey, client)
+}
+
+// Allow counts a request of the client to the operation and reports whether it is within the limit.
+// Operations without a valid limit setting are not limited.
+func (l *RateLimiter) Allow(ctx context.Context, operation string) bool {
+	client, ok := ctx.Value(ClientKey).(string)
+	if !ok || client == "" {
+		return true
+	}
+
+	limit, err := l.limit(ctx, operation)
+	if err != nil {
+		log.Printf("Error loading rate limit of %v: %v", operation, err)
+		return true
+	}
+	if limit.requests <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	key := operation + ":" + client
+	window, ok := l.windows[key]
+	if !ok || now.Sub(window.start) >= limit.window {
+		window = &rateWindow{start: now}
+		l.windows[key] = window
+	}
+
+	window.count++
+
+	return window.count <= limit.requests
+}
+
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.realIPHeader != "" {
+		// proxies append to the header, so the last entry is the one our proxy saw
+		values := strings.Split(r.Header.Get(l.realIPHeader), ",")
+		if ip := strings.TrimSpace(values[len(values)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (l *RateLimiter) hashClient(ip string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.salt == nil || time.Since(l.saltSince) > saltRotationInterval {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		// the old hashes cannot be matched anymore, so their windows are useless
+		l.salt = salt
+		l.saltSince = time.Now()
+		l.windows = make(map[string]*rateWindow)
+	}
+
+	mac := hmac.New(sha256.New, l.salt)
+	mac.Write([]byte(ip))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (l *RateLimiter) limit(ctx context.Context, operation string) (rateLimit, error) {
+	l.mu.Lock()
+	limit, ok := l.limits[operation]
+	l.mu.Unlock()
+
+	if ok && time.Since(limit.loadedAt) < limitCacheDuration {
+		return limit, nil
+	}
+
+	var settings []*models.Setting
+	if err := l.db.NewSelect().Model(&settings).
+		Where("key = ?", RateLimitSettingPrefix+operation).
+		Scan(ctx); err != nil {
+		return rateLimit{}, err
+	}
+
+	limit = rateLimit{loadedAt: time.Now()}
+	if len(settings) > 0 {
+		requests, window, err := parseRateLimit(settings[0].Value)
+		if err != nil {
+			return rateLimit{}, err
+		}
+		limit.requests = requests
+		limit.window = window
+	}
+
+	l.mu.Lock()
+	l.limits[operation] = limit
+	l.mu.Unlock()
+
+	return limit, nil
+}
+
+// ValidateRateLimit checks a setting value before it is stored
+func ValidateRateLimit(value string) error {
+	_, _, err := parseRateLimit(value)
+	return err
+}
+
+// parseRateLimit reads limits in the form "<requests>/<window>", e.g. "5/1h"
+func parseRateLimit(value string) (int, time.Duration, error) {
+	requests, window, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return 0, 0, fmt.Errorf("rate limit %q is not in the form <requests>/<window>", value)
+	}
+
+	count, err := strconv.Atoi(requests)
+	if err != nil {
+		return 0, 0, fmt.Errorf("rate limit %q has an invalid number of requests: %w", value, err)
+	}
+
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration <= 0 {
+		return 0, 0, fmt.Errorf("rate limit %q has an invalid window", value)
+	}
+
+	return count, duration, nil
+}
+
+// prune drops windows which ended, the caller has to hold the lock
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	var longestWindow time.Duration
+	for _, limit := range l.limits {
+		longestWindow = max(longestWindow, limit.window)
+	}
+
+	for key, window := range l.windows {
+		if now.Sub(window.start) >= longestWindow {
+			delete(l.windows, key)
+		}
+	}
+}
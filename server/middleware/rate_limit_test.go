@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value    string
+		requests int
+		window   time.Duration
+		wantErr  bool
+	}{
+		{value: "10/15m", requests: 10, window: 15 * time.Minute},
+		{value: " 5/1h ", requests: 5, window: time.Hour},
+		{value: "0/1s", requests: 0, window: time.Second},
+		{value: "10", wantErr: true},
+		{value: "ten/1h", wantErr: true},
+		{value: "10/hour", wantErr: true},
+		{value: "10/0s", wantErr: true},
+		{value: "10/-1m", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		requests, window, err := parseRateLimit(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRateLimit(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if requests != tt.requests || window != tt.window {
+			t.Errorf("parseRateLimit(%q) = %v, %v, want %v, %v", tt.value, requests, window, tt.requests, tt.window)
+		}
+	}
+}
+
+// newTestLimiter has cached limits, so it never reads the settings
+func newTestLimiter(limits map[string]rateLimit) *RateLimiter {
+	l := NewRateLimiter(nil, "")
+	for operation, limit := range limits {
+		limit.loadedAt = time.Now()
+		l.limits[operation] = limit
+	}
+	return l
+}
+
+func TestAllow(t *testing.T) {
+	l := newTestLimiter(map[string]rateLimit{
+		"LOGIN":  {requests: 2, window: time.Hour},
+		"SUBMIT": {requests: 0, window: time.Hour},
+	})
+
+	alice := l.WithClient(context.Background(), "192.0.2.1")
+	bob := l.WithClient(context.Background(), "192.0.2.2")
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		operation string
+		want      bool
+	}{
+		{"first request", alice, "LOGIN", true},
+		{"second request", alice, "LOGIN", true},
+		{"over the limit", alice, "LOGIN", false},
+		{"still over the limit", alice, "LOGIN", false},
+		{"other client", bob, "LOGIN", true},
+		{"limit of zero is no limit", alice, "SUBMIT", true},
+		{"unknown client", context.Background(), "LOGIN", true},
+	}
+
+	for _, tt := range tests {
+		if got := l.Allow(tt.ctx, tt.operation); got != tt.want {
+			t.Errorf("%v: Allow() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// a new window starts once the old one ended
+	for _, window := range l.windows {
+		window.start = window.start.Add(-time.Hour)
+	}
+	if !l.Allow(alice, "LOGIN") {
+		t.Error("the limit was not reset after the window")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l := newTestLimiter(map[string]rateLimit{"LOGIN": {requests: 1, window: time.Minute}})
+
+	now := time.Now()
+	l.windows["LOGIN:old"] = &rateWindow{start: now.Add(-2 * time.Minute), count: 1}
+	l.windows["LOGIN:new"] = &rateWindow{start: now.Add(-time.Second), count: 1}
+
+	l.prune(now)
+
+	if _, ok := l.windows["LOGIN:old"]; ok {
+		t.Error("the ended window was kept")
+	}
+	if _, ok := l.windows["LOGIN:new"]; !ok {
+		t.Error("the running window was dropped")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		remoteAddr string
+		want       string
+	}{
+		{name: "remote address", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "ip6 remote address", remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		{name: "header ignored without setting", value: "203.0.113.1", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "header", header: "X-Real-IP", value: "203.0.113.1", remoteAddr: "192.0.2.1:1234", want: "203.0.113.1"},
+		// clients may send the header themselves, the proxy appends what it saw
+		{name: "forged entries", header: "X-Forwarded-For", value: "198.51.100.1, 203.0.113.1", remoteAddr: "192.0.2.1:1234", want: "203.0.113.1"},
+		{name: "missing header", header: "X-Real-IP", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(nil, tt.header)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.value != "" {
+				r.Header.Set("X-Real-IP", tt.value)
+				r.Header.Set("X-Forwarded-For", tt.value)
+			}
+
+			if got := l.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashClient(t *testing.T) {
+	l := NewRateLimiter(nil, "")
+
+	first, _ := l.hashClient("192.0.2.1")
+	again, _ := l.hashClient("192.0.2.1")
+	other, _ := l.hashClient("192.0.2.2")
+
+	if first != again || first == other {
+		t.Errorf("hashes %v, %v and %v do not identify the clients", first, again, other)
+	}
+	if strings.Contains(first, "192.0.2.1") {
+		t.Error("the hash contains the IP")
+	}
+
+	// rotating the salt forgets the clients and their windows
+	l.windows["LOGIN:"+first] = &rateWindow{start: time.Now(), count: 1}
+	l.saltSince = time.Now().Add(-saltRotationInterval - time.Minute)
+	if rotated, _ := l.hashClient("192.0.2.1"); rotated == first {
+		t.Error("the hash did not change with the salt")
+	}
+	if len(l.windows) != 0 {
+		t.Error("the windows were kept after rotating the salt")
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/uptrace/bun"
+)
+
+// ChatChannel is a Matrix room or an incoming webhook of Mattermost or Slack,
+// it gets the tickets with its label or all tickets if LabelID is not set
+type ChatChannel struct {
+	bun.BaseModel `bun:"table:chat_channels"`
+
+	ID       string             `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	Name     string             `bun:",notnull"`
+	Platform model.ChatPlatform `bun:",notnull"`
+	// URL is encrypted, as the incoming webhooks of Mattermost and Slack accept anything sent to them
+	URL    EncryptedString `bun:",notnull"`
+	RoomID string          `bun:",nullzero"`
+	// AccessToken is only used by Matrix
+	AccessToken EncryptedString `bun:",nullzero"`
+	LabelID     string          `bun:",type:uuid,nullzero"`
+	// Events holds the model.NotificationType values the channel is posted to on
+	Events    []string  `bun:",array,notnull"`
+	Active    bool      `bun:",notnull,default:true"`
+	CreatedAt time.Time `bun:",notnull,default:current_timestamp"`
+	Label     *Label    `bun:"rel:belongs-to,join:label_id=id"`
+}
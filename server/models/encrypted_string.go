@@ -0,0 +1,39 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/encryption"
+)
+
+// EncryptedString is stored encrypted with AES-GCM and decrypted when it is read,
+// so the contents of tickets do not end up in plaintext in database dumps
+type EncryptedString string
+
+func (s EncryptedString) Value() (driver.Value, error) {
+	return encryption.Encrypt(string(s))
+}
+
+func (s *EncryptedString) Scan(src any) error {
+	var value string
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into an encrypted string", src)
+	}
+
+	plaintext, err := encryption.Decrypt(value)
+	if err != nil {
+		return err
+	}
+
+	*s = EncryptedString(plaintext)
+	return nil
+}
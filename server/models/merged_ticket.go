@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// MergedTicket remembers a ticket which was merged into another one, so that its text
+// is kept and its submitter can still follow the conversation with the old tracking code
+type MergedTicket struct {
+	bun.BaseModel `bun:"table:merged_tickets"`
+
+	ID               string          `bun:",pk,type:uuid"`
+	TicketID         string          `bun:",type:uuid,notnull"`
+	OriginalTitle    string          `bun:",notnull"`
+	Text             EncryptedString `bun:",notnull,default:''"`
+	TrackingCodeHash string          `bun:",unique,nullzero"`
+	MergedByID       string          `bun:",type:uuid,nullzero"`
+	// CreatedAt is when the merged ticket was submitted, unknown for merges of earlier releases
+	CreatedAt time.Time `bun:",nullzero"`
+	MergedAt  time.Time `bun:",notnull,default:current_timestamp"`
+}
+
+func (*MergedTicket) AfterCreateTable(ctx context.Context, query *bun.CreateTableQuery) error {
+	_, err := query.DB().NewCreateIndex().IfNotExists().
+		Model((*MergedTicket)(nil)).
+		Index("merged_tickets_ticket_id_idx").
+		Column("ticket_id").
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/uptrace/bun"
+)
+
+// NotificationSubscription lets a staff member receive mails about ticket changes,
+// LabelID is only set for subscriptions of type LABEL
+type NotificationSubscription struct {
+	bun.BaseModel `bun:"table:notification_subscriptions"`
+
+	ID        string                 `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	UserID    string                 `bun:",type:uuid,notnull"`
+	Type      model.NotificationType `bun:",notnull"`
+	LabelID   string                 `bun:",type:uuid,nullzero"`
+	CreatedAt time.Time              `bun:",notnull,default:current_timestamp"`
+	User      *User                  `bun:"rel:belongs-to,join:user_id=id"`
+	Label     *Label                 `bun:"rel:belongs-to,join:label_id=id"`
+}
+
+func (*NotificationSubscription) AfterCreateTable(ctx context.Context, query *bun.CreateTableQuery) error {
+	_, err := query.DB().NewCreateIndex().IfNotExists().
+		Model((*NotificationSubscription)(nil)).
+		Index("notification_subscriptions_user_id_idx").
+		Column("user_id").
+		Exec(ctx)
+	return err
+}
@@ -2,6 +2,31 @@ package models
 
 import "github.com/uptrace/bun"
 
+// TrashRetentionDaysKey is the setting holding how many days deleted tickets
+// stay in the trash before they are purged for good
+const TrashRetentionDaysKey = "TRASH_RETENTION_DAYS"
+
+// SubmissionChallengeDifficultyKey is the setting holding the number of leading
+// zero bits the proof-of-work of the submission form has to produce
+const SubmissionChallengeDifficultyKey = "SUBMISSION_CHALLENGE_DIFFICULTY"
+
+// Settings of the retention policy for closed tickets, see maintenance.ApplyRetentionPolicy
+const (
+	RetentionDaysKey         = "RETENTION_DAYS"
+	RetentionActionKey       = "RETENTION_ACTION"
+	RetentionExemptLabelsKey = "RETENTION_EXEMPT_LABELS"
+)
+
+// Settings of the digest mails, see maintenance.SendDigests
+const (
+	DigestTemplateKey  = "DIGEST_TEMPLATE"
+	DigestStaleDaysKey = "DIGEST_STALE_DAYS"
+)
+
+// InboundMailLabelsKey is the setting mapping the tags of plus-addresses to labels,
+// one "tag=label name" per line
+const InboundMailLabelsKey = "INBOUND_MAIL_LABELS"
+
 type Setting struct {
 	bun.BaseModel `bun:"table:settings"`
 
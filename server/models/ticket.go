@@ -1,8 +1,10 @@
 package models
 
 import (
+	"context"
 	"time"
 
+	"github.com/FachschaftMathPhysInfo/kummerkasten/encryption"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
 	"github.com/uptrace/bun"
 )
@@ -10,15 +12,28 @@ import (
 type Ticket struct {
 	bun.BaseModel `bun:"table:tickets"`
 
-	ID            string            `bun:",pk,default:gen_random_UUID(),type:uuid"`
-	OriginalTitle string            `bun:",notnull"`
-	Title         string            `bun:",notnull"`
-	Text          string            `bun:",notnull"`
-	Note          string            `bun:""`
-	State         model.TicketState `bun:",notnull,default:'NEW'"`
-	CreatedAt     time.Time         `bun:",notnull,default:current_timestamp"`
-	LastModified  time.Time         `bun:",notnull,default:current_timestamp"`
-	Labels        []*Label          `bun:"m2m:labels_to_tickets"`
+	ID               string              `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	OriginalTitle    string              `bun:",notnull"`
+	Title            string              `bun:",notnull"`
+	Text             EncryptedString     `bun:",notnull"`
+	Note             EncryptedString     `bun:""`
+	TextTokens       []string            `bun:",array"`
+	NoteTokens       []string            `bun:",array"`
+	TrackingCodeHash string              `bun:",unique,nullzero"`
+	State            model.TicketState   `bun:",notnull,default:'NEW'"`
+	AssigneeID       string              `bun:",type:uuid,nullzero"`
+	CreatedAt        time.Time           `bun:",notnull,default:current_timestamp"`
+	LastModified     time.Time           `bun:",notnull,default:current_timestamp"`
+	DeletedAt        time.Time           `bun:",soft_delete,nullzero"`
+	AnonymizedAt     time.Time           `bun:",nullzero"`
+	Source           model.TicketSource  `bun:",notnull,default:'FORM'"`
+	Assignee         *User               `bun:"rel:belongs-to,join:assignee_id=id"`
+	Labels           []*Label            `bun:"m2m:labels_to_tickets"`
+	Comments         []*TicketComment    `bun:"rel:has-many,join:id=ticket_id"`
+	Messages         []*TicketMessage    `bun:"rel:has-many,join:id=ticket_id"`
+	Events           []*TicketEvent      `bun:"rel:has-many,join:id=ticket_id"`
+	Attachments      []*TicketAttachment `bun:"rel:has-many,join:id=ticket_id"`
+	MergedTickets    []*MergedTicket     `bun:"rel:has-many,join:id=ticket_id"`
 }
 
 type LabelsToTickets struct {
@@ -29,3 +44,29 @@ type LabelsToTickets struct {
 	Ticket   *Ticket `bun:"rel:belongs-to,join:ticket_id=id"`
 	Label    *Label  `bun:"rel:belongs-to,join:label_id=id"`
 }
+
+// BeforeAppendModel keeps the blind indexes of text and note up to date whenever a ticket is
+// inserted or updated from the struct, see encryption.BlindIndex. Tickets written by releases
+// without them have NULL tokens until db.IndexTicketTexts ran.
+func (t *Ticket) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery, *bun.UpdateQuery:
+	default:
+		return nil
+	}
+
+	var err error
+	if t.TextTokens, err = encryption.BlindIndex(string(t.Text)); err != nil {
+		return err
+	}
+	t.NoteTokens, err = encryption.BlindIndex(string(t.Note))
+	return err
+}
+
+// TicketSearchVectorDefinition is the generated full-text search column of tickets.
+// It is not part of the struct, as postgres computes it on every write. Text and note
+// are encrypted, they are searched through their blind indexes instead.
+const TicketSearchVectorDefinition = `title_search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('german', coalesce(title, '')), 'A') ||
+	setweight(to_tsvector('german', coalesce(original_title, '')), 'B')
+) STORED`
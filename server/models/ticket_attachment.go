@@ -0,0 +1,30 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// TicketAttachment is a file uploaded together with a ticket, its content is kept in the storage
+type TicketAttachment struct {
+	bun.BaseModel `bun:"table:ticket_attachments"`
+
+	ID          string    `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	TicketID    string    `bun:",type:uuid,notnull"`
+	FileName    string    `bun:",notnull"`
+	ContentType string    `bun:",notnull"`
+	Size        int64     `bun:",notnull"`
+	StorageKey  string    `bun:",notnull,unique"`
+	CreatedAt   time.Time `bun:",notnull,default:current_timestamp"`
+}
+
+func (*TicketAttachment) AfterCreateTable(ctx context.Context, query *bun.CreateTableQuery) error {
+	_, err := query.DB().NewCreateIndex().IfNotExists().
+		Model((*TicketAttachment)(nil)).
+		Index("ticket_attachments_ticket_id_idx").
+		Column("ticket_id").
+		Exec(ctx)
+	return err
+}
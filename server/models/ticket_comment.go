@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type TicketComment struct {
+	bun.BaseModel `bun:"table:ticket_comments"`
+
+	ID           string                   `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	TicketID     string                   `bun:",type:uuid,notnull"`
+	AuthorID     string                   `bun:",type:uuid,nullzero"`
+	Text         EncryptedString          `bun:",notnull"`
+	CreatedAt    time.Time                `bun:",notnull,default:current_timestamp"`
+	LastModified time.Time                `bun:",notnull,default:current_timestamp"`
+	Author       *User                    `bun:"rel:belongs-to,join:author_id=id"`
+	Revisions    []*TicketCommentRevision `bun:"rel:has-many,join:id=comment_id"`
+}
+
+// TicketCommentRevision keeps the text a comment had before it was edited
+type TicketCommentRevision struct {
+	bun.BaseModel `bun:"table:ticket_comment_revisions"`
+
+	ID        string          `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	CommentID string          `bun:",type:uuid,notnull"`
+	Text      EncryptedString `bun:",notnull"`
+	EditedAt  time.Time       `bun:",notnull,default:current_timestamp"`
+}
+
+func (*TicketComment) AfterCreateTable(ctx context.Context, query *bun.CreateTableQuery) error {
+	_, err := query.DB().NewCreateIndex().IfNotExists().
+		Model((*TicketComment)(nil)).
+		Index("ticket_comments_ticket_id_idx").
+		Column("ticket_id").
+		Exec(ctx)
+	return err
+}
+
+func (*TicketCommentRevision) AfterCreateTable(ctx context.Context, query *bun.CreateTableQuery) error {
+	_, err := query.DB().NewCreateIndex().IfNotExists().
+		Model((*TicketCommentRevision)(nil)).
+		Index("ticket_comment_revisions_comment_id_idx").
+		Column("comment_id").
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/uptrace/bun"
+)
+
+// TicketEvent records a single change of a ticket for its history
+type TicketEvent struct {
+	bun.BaseModel `bun:"table:ticket_events"`
+
+	ID        string                `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	TicketID  string                `bun:",type:uuid,notnull"`
+	Type      model.TicketEventType `bun:",notnull"`
+	ActorID   string                `bun:",type:uuid,nullzero"`
+	OldValue  string                `bun:",nullzero"`
+	NewValue  string                `bun:",nullzero"`
+	CreatedAt time.Time             `bun:",notnull,default:current_timestamp"`
+	Actor     *User                 `bun:"rel:belongs-to,join:actor_id=id"`
+	Ticket    *Ticket               `bun:"rel:belongs-to,join:ticket_id=id"`
+}
+
+func (*TicketEvent) AfterCreateTable(ctx context.Context, query *bun.CreateTableQuery) error {
+	_, err := query.DB().NewCreateIndex().IfNotExists().
+		Model((*TicketEvent)(nil)).
+		Index("ticket_events_ticket_id_idx").
+		Column("ticket_id").
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// TicketMessage is part of the conversation between staff and the anonymous
+// submitter of a ticket, who takes part through the ticket's tracking code
+type TicketMessage struct {
+	bun.BaseModel `bun:"table:ticket_messages"`
+
+	ID            string          `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	TicketID      string          `bun:",type:uuid,notnull"`
+	AuthorID      string          `bun:",type:uuid,nullzero"`
+	FromSubmitter bool            `bun:",notnull,default:false"`
+	Text          EncryptedString `bun:",notnull"`
+	CreatedAt     time.Time       `bun:",notnull,default:current_timestamp"`
+	// MergedTicketID is set on the conversation with the submitter of a ticket merged into this one,
+	// each submitter only sees their own conversation
+	MergedTicketID string `bun:",type:uuid,nullzero"`
+	Author         *User  `bun:"rel:belongs-to,join:author_id=id"`
+}
+
+func (*TicketMessage) AfterCreateTable(ctx context.Context, query *bun.CreateTableQuery) error {
+	_, err := query.DB().NewCreateIndex().IfNotExists().
+		Model((*TicketMessage)(nil)).
+		Index("ticket_messages_ticket_id_idx").
+		Column("ticket_id").
+		Exec(ctx)
+	return err
+}
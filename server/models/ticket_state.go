@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/uptrace/bun"
+)
+
+// TicketStateDefinition is a state of the ticket workflow configured by admins
+type TicketStateDefinition struct {
+	bun.BaseModel `bun:"table:ticket_states,alias:ticket_state"`
+
+	Key         model.TicketState        `bun:",pk,type:varchar(30)"`
+	Name        string                   `bun:",notnull,unique"`
+	Color       string                   `bun:"type:varchar(8),default:'#7a7777'"`
+	Position    int32                    `bun:",notnull,default:0"`
+	Transitions []*TicketStateTransition `bun:"rel:has-many,join:key=from_key"`
+}
+
+// TicketStateTransition allows tickets to move from one state to another
+type TicketStateTransition struct {
+	bun.BaseModel `bun:"table:ticket_state_transitions"`
+
+	FromKey model.TicketState `bun:",pk,type:varchar(30)"`
+	ToKey   model.TicketState `bun:",pk,type:varchar(30)"`
+}
@@ -19,4 +19,10 @@ type User struct {
 	CreatedAt    time.Time      `bun:",notnull"`
 	LastModified time.Time      `bun:",notnull"`
 	LastLogin    time.Time
+	// DigestFrequency is how often the user gets digest mails, LastDigestAt when the last one was sent
+	DigestFrequency model.DigestFrequency `bun:",notnull,default:'NONE'"`
+	LastDigestAt    time.Time             `bun:",nullzero"`
+	// FeedTokenHash authenticates the feed, it is replaced to revoke the old token
+	FeedTokenHash      string    `bun:",unique,nullzero"`
+	FeedTokenCreatedAt time.Time `bun:",nullzero"`
 }
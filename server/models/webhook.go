@@ -0,0 +1,51 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/uptrace/bun"
+)
+
+type Webhook struct {
+	bun.BaseModel `bun:"table:webhooks"`
+
+	ID     string          `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	URL    string          `bun:",notnull"`
+	Secret EncryptedString `bun:",notnull"`
+	// Events holds the model.WebhookEvent values the webhook is called on
+	Events    []string  `bun:",array,notnull"`
+	Active    bool      `bun:",notnull,default:true"`
+	CreatedAt time.Time `bun:",notnull,default:current_timestamp"`
+}
+
+// WebhookDelivery is a queued call of a webhook and its log entry afterwards,
+// the payload is built when the event happens so that retries send the same content
+type WebhookDelivery struct {
+	bun.BaseModel `bun:"table:webhook_deliveries"`
+
+	ID             string                      `bun:",pk,default:gen_random_UUID(),type:uuid"`
+	WebhookID      string                      `bun:",type:uuid,notnull"`
+	Event          model.WebhookEvent          `bun:",notnull"`
+	TicketID       string                      `bun:",type:uuid,notnull"`
+	Payload        json.RawMessage             `bun:",type:jsonb,notnull"`
+	Status         model.WebhookDeliveryStatus `bun:",notnull"`
+	Attempts       int                         `bun:",notnull,default:0"`
+	ResponseStatus int                         `bun:",nullzero"`
+	Error          string                      `bun:",nullzero"`
+	CreatedAt      time.Time                   `bun:",notnull,default:current_timestamp"`
+	LastAttemptAt  time.Time                   `bun:",nullzero"`
+	NextAttemptAt  time.Time                   `bun:",nullzero"`
+}
+
+func (*WebhookDelivery) AfterCreateTable(ctx context.Context, query *bun.CreateTableQuery) error {
+	_, err := query.DB().NewCreateIndex().IfNotExists().
+		Model((*WebhookDelivery)(nil)).
+		Index("webhook_deliveries_pending_idx").
+		Column("next_attempt_at").
+		Where("status = ?", model.WebhookDeliveryStatusPending).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,92 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultDigestTemplate is used if the setting DIGEST_TEMPLATE is missing or empty
+const DefaultDigestTemplate = `Hallo {{.Firstname}},
+
+hier ist deine Zusammenfassung des Kummerkastens seit {{.Since.Format "02.01.2006 15:04"}}.
+{{if .New}}
+Neue Tickets ({{len .New}}):
+{{range .New}}- {{.Title}}{{if .Labels}} [{{join .Labels ", "}}]{{end}}
+  {{.URL}}
+{{end}}{{end}}{{if .Stale}}
+Seit mindestens {{.StaleDays}} Tagen unbearbeitet ({{len .Stale}}):
+{{range .Stale}}- {{.Title}}, eingegangen am {{.CreatedAt.Format "02.01.2006"}}
+  {{.URL}}
+{{end}}{{end}}{{if .Closed}}
+Geschlossen ({{len .Closed}}):
+{{range .Closed}}- {{.Title}}
+{{end}}{{end}}
+Alle Tickets: {{.OverviewURL}}
+
+Die Häufigkeit dieser Mails kannst du in deinem Account einstellen.
+`
+
+type DigestTicket struct {
+	Title     string
+	State     string
+	Labels    []string
+	CreatedAt time.Time
+	URL       string
+}
+
+// DigestData is what the digest template is executed with
+type DigestData struct {
+	Firstname   string
+	Frequency   string
+	Since       time.Time
+	StaleDays   int
+	New         []DigestTicket
+	Stale       []DigestTicket
+	Closed      []DigestTicket
+	OverviewURL string
+}
+
+// Empty is true if there is nothing to tell about
+func (d DigestData) Empty() bool {
+	return len(d.New) == 0 && len(d.Stale) == 0 && len(d.Closed) == 0
+}
+
+var digestFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// ParseDigestTemplate parses a digest template, the default one if text is empty
+func ParseDigestTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultDigestTemplate
+	}
+	return template.New("digest").Funcs(digestFuncs).Option("missingkey=error").Parse(text)
+}
+
+// ValidateDigestTemplate parses the template and executes it with example data,
+// so that mistakes show up when saving the setting instead of when sending the digests
+func ValidateDigestTemplate(text string) error {
+	tmpl, err := ParseDigestTemplate(text)
+	if err != nil {
+		return err
+	}
+
+	example := DigestTicket{Title: "Beispiel", State: "NEW", Labels: []string{"Label"}, CreatedAt: time.Now(), URL: "https://example.org"}
+	data := DigestData{
+		Firstname:   "Name",
+		Frequency:   "DAILY",
+		Since:       time.Now(),
+		StaleDays:   7,
+		New:         []DigestTicket{example},
+		Stale:       []DigestTicket{example},
+		Closed:      []DigestTicket{example},
+		OverviewURL: "https://example.org",
+	}
+
+	if err := tmpl.Execute(&strings.Builder{}, data); err != nil {
+		return fmt.Errorf("template cannot be rendered: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/mailer"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+	"github.com/uptrace/bun"
+)
+
+// Notifier mails staff members about the ticket events they subscribed to
+type Notifier struct {
+	DB     *bun.DB
+	Events *events.Bus
+	Mailer *mailer.Mailer
+}
+
+// Run handles ticket events until ctx is done, the mails are sent in the background by the mailer
+func (n *Notifier) Run(ctx context.Context) {
+	if n.Mailer == nil {
+		log.Print("No SMTP server configured, mail notifications are disabled")
+		return
+	}
+
+	for event := range n.Events.Subscribe(ctx) {
+		if err := n.handle(ctx, event); err != nil {
+			log.Printf("Failed to send notifications for ticket %v: %v", event.TicketID, err)
+		}
+	}
+}
+
+func (n *Notifier) handle(ctx context.Context, event events.TicketEvent) error {
+	switch event.Type {
+	case events.TicketCreated, events.TicketStateChanged, events.TicketLabelled:
+	default:
+		return nil
+	}
+
+	// admins are notified about quarantined tickets, the other recipients are skipped below
+	ticket, err := events.Ticket(ctx, n.DB, event.TicketID, true)
+	if err != nil || ticket == nil {
+		return err
+	}
+
+	var subject, intro string
+	var subscriptions []*models.NotificationSubscription
+
+	switch event.Type {
+	case events.TicketCreated:
+		var labelIDs []string
+		for _, l := range ticket.Labels {
+			labelIDs = append(labelIDs, l.ID)
+		}
+
+		subject = "Neues Ticket: " + ticket.Title
+		intro = "im Kummerkasten ist ein neues Ticket eingegangen:"
+		subscriptions, err = n.subscriptions(ctx, model.NotificationTypeNewTicket, labelIDs)
+	case events.TicketStateChanged:
+		subject = fmt.Sprintf("Ticket %v: %v", ticket.Title, event.NewState)
+		intro = fmt.Sprintf("der Status eines Tickets hat sich von %v zu %v geändert:", event.OldState, event.NewState)
+		subscriptions, err = n.subscriptions(ctx, model.NotificationTypeStateChanged, nil)
+	case events.TicketLabelled:
+		subject = "Neues Label an Ticket: " + ticket.Title
+		intro = "ein Ticket hat ein Label bekommen, das du abonniert hast:"
+		subscriptions, err = n.subscriptions(ctx, "", event.LabelIDs)
+	}
+	if err != nil {
+		return err
+	}
+
+	n.mail(ticket, subscriptions, subject, intro)
+	return nil
+}
+
+// mail queues one mail for every subscribed user, quarantined tickets are only mailed to admins
+func (n *Notifier) mail(ticket *models.Ticket, subscriptions []*models.NotificationSubscription, subject, intro string) {
+	var labelNames []string
+	for _, l := range ticket.Labels {
+		labelNames = append(labelNames, l.Name)
+	}
+
+	notified := make(map[string]struct{})
+	for _, s := range subscriptions {
+		if s.User == nil {
+			continue
+		}
+		if _, ok := notified[s.UserID]; ok {
+			continue
+		}
+		if ticket.State == model.TicketStateQuarantine && s.User.Role != model.UserRoleAdmin {
+			continue
+		}
+		notified[s.UserID] = struct{}{}
+
+		n.Mailer.Enqueue(mailer.Message{
+			To:      s.User.Mail,
+			Subject: subject,
+			Body:    body(s.User.Firstname, intro, ticket, labelNames),
+		})
+	}
+}
+
+// subscriptions returns the subscriptions of the type and the label subscriptions of the labels
+func (n *Notifier) subscriptions(ctx context.Context, subscriptionType model.NotificationType, labelIDs []string) ([]*models.NotificationSubscription, error) {
+	if subscriptionType == "" && len(labelIDs) == 0 {
+		return nil, nil
+	}
+
+	var subscriptions []*models.NotificationSubscription
+	err := n.DB.NewSelect().Model(&subscriptions).
+		Relation("User").
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			if subscriptionType != "" {
+				q = q.WhereOr("notification_subscription.type = ?", subscriptionType)
+			}
+			if len(labelIDs) > 0 {
+				q = q.WhereOr("notification_subscription.type = ? AND notification_subscription.label_id IN (?)", model.NotificationTypeLabel, bun.In(labelIDs))
+			}
+			return q
+		}).
+		Scan(ctx)
+
+	return subscriptions, err
+}
+
+// body links to the ticket instead of including its text, see events.Ticket
+func body(firstname, intro string, ticket *models.Ticket, labelNames []string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Hallo %v,\n\n%v\n\n", firstname, intro)
+	fmt.Fprintf(&b, "%v\n", ticket.Title)
+	if len(labelNames) > 0 {
+		fmt.Fprintf(&b, "Labels: %v\n", strings.Join(labelNames, ", "))
+	}
+	fmt.Fprintf(&b, "\n%v\n\n", utils.EnvConfig.PublicURL("/tickets/"+ticket.ID))
+	b.WriteString("Du bekommst diese Mail, weil du Benachrichtigungen im Kummerkasten abonniert hast.\n")
+
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package notifications
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/mailer"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+type receivedMail struct {
+	to   []string
+	data string
+}
+
+// smtpServer accepts mails on a local port and hands them to the returned channel
+func smtpServer(t *testing.T) (string, <-chan receivedMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	mails := make(chan receivedMail, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, mails)
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port, mails
+}
+
+func serveSMTP(conn net.Conn, mails chan<- receivedMail) {
+	text := textproto.NewConn(conn)
+	defer func() { _ = text.Close() }()
+
+	_ = text.PrintfLine("220 localhost")
+	var mail receivedMail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"), strings.HasPrefix(command, "MAIL FROM:"):
+			_ = text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			_ = text.PrintfLine("250 OK")
+		case command == "DATA":
+			_ = text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.data = string(data)
+			mails <- mail
+			mail = receivedMail{}
+			_ = text.PrintfLine("250 OK")
+		case command == "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestMailRecipients(t *testing.T) {
+	port, mails := smtpServer(t)
+
+	m, err := mailer.New(mailer.Config{Host: "127.0.0.1", Port: port, From: "kummerkasten@example.org", TLS: mailer.TLSNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	n := &Notifier{Mailer: m}
+
+	admin := &models.User{ID: "admin", Mail: "admin@example.org", Firstname: "Ada", Role: model.UserRoleAdmin}
+	member := &models.User{ID: "member", Mail: "member@example.org", Firstname: "Max", Role: model.UserRoleUser}
+	subscriptions := []*models.NotificationSubscription{
+		{UserID: admin.ID, User: admin, Type: model.NotificationTypeNewTicket},
+		{UserID: member.ID, User: member, Type: model.NotificationTypeNewTicket},
+		// a label subscription of the same user does not send a second mail
+		{UserID: member.ID, User: member, Type: model.NotificationTypeLabel},
+		// the user was deleted
+		{UserID: "gone", Type: model.NotificationTypeNewTicket},
+	}
+
+	tests := []struct {
+		name  string
+		state model.TicketState
+		want  []string
+	}{
+		{"new ticket", model.TicketStateNew, []string{"admin@example.org", "member@example.org"}},
+		{"quarantined ticket", model.TicketStateQuarantine, []string{"admin@example.org"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := &models.Ticket{
+				ID:    "3f1c2a9e-0000-4000-8000-000000000001",
+				Title: "Beamer kaputt",
+				Text:  "Der Text bleibt im Kummerkasten",
+				State: tt.state,
+				Labels: []*models.Label{
+					{ID: "label", Name: "Hörsaal"},
+				},
+			}
+
+			n.mail(ticket, subscriptions, "Neues Ticket: "+ticket.Title, "im Kummerkasten ist ein neues Ticket eingegangen:")
+			// the mailer works through its queue in order, so no mail comes after the end marker
+			m.Enqueue(mailer.Message{To: "end@example.org", Subject: "end"})
+
+			var got []string
+			for {
+				var mail receivedMail
+				select {
+				case mail = <-mails:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("timed out, got mails to %v", got)
+				}
+				if slices.Equal(mail.to, []string{"end@example.org"}) {
+					break
+				}
+				if len(mail.to) != 1 {
+					t.Errorf("mail to %v, want one recipient per mail", mail.to)
+				}
+				got = append(got, mail.to...)
+
+				if !strings.Contains(mail.data, "Subject: Neues Ticket: Beamer kaputt") || !strings.Contains(mail.data, "/tickets/"+ticket.ID) {
+					t.Errorf("mail to %v lacks the subject or link:\n%v", mail.to, mail.data)
+				}
+				if strings.Contains(mail.data, string(ticket.Text)) {
+					t.Errorf("mail to %v contains the ticket text", mail.to)
+				}
+			}
+
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("mailed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
 	"github.com/gorilla/websocket"
 	"github.com/robfig/cron"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -20,11 +25,20 @@ import (
 	"net/http/httputil"
 	"net/url"
 
+	"github.com/FachschaftMathPhysInfo/kummerkasten/chat"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/db"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/graph"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/directives"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/importer"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/inbound"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/mailer"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/maintenance"
 	"github.com/FachschaftMathPhysInfo/kummerkasten/middleware"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/notifications"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/storage"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/webhooks"
 	_ "github.com/lib/pq"
 )
 
@@ -37,6 +51,7 @@ var (
 	cronjob        *cron.Cron
 	srv            *handler.Server
 	resolver       *graph.Resolver
+	limiter        *middleware.RateLimiter
 	ctx            = context.Background()
 	DB             *bun.DB
 	c              *cors.Cors
@@ -51,7 +66,26 @@ func main() {
 
 	log.Print("starting database initialization...")
 	_, DB = db.Init(ctx)
+
+	// graphql-server rotate-encryption-key re-encrypts all rows with ENCRYPTION_KEY and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-encryption-key" {
+		if err := db.RotateEncryptionKey(ctx, DB); err != nil {
+			log.Fatal("Error rotating encryption key: ", err)
+		}
+		log.Print("All values are encrypted with the current key, OLD_ENCRYPTION_KEYS can be removed")
+		return
+	}
+
+	// graphql-server import-tickets [-dry-run] FILE creates tickets from a CSV or JSON file and exits
+	if len(os.Args) > 1 && os.Args[1] == "import-tickets" {
+		importTickets(os.Args[2:])
+		return
+	}
+
 	initGraphQL()
+	initNotifications()
+	initWebhooks()
+	initInboundMail()
 	initCors()
 
 	log.Print("setting up cronjobs")
@@ -64,6 +98,7 @@ func main() {
 	router.Use(c.Handler)
 
 	router.Mount("/api", getAPIRouter())
+	router.Get(graph.FeedPath, resolver.ServeFeed)
 
 	if envConf.Env == "DEV" {
 		router.Handle("/playground", playground.Handler("GraphQL playground", "/api"))
@@ -75,26 +110,173 @@ func main() {
 	log.Fatal(http.ListenAndServe(":"+port, router))
 }
 
+func importTickets(args []string) {
+	flags := flag.NewFlagSet("import-tickets", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only validate the file")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatal("Usage: import-tickets [-dry-run] FILE")
+	}
+
+	format, err := importer.FormatOf(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal("Error opening import file: ", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// the rows refer to labels and states, which are only created by seeding if the server never ran
+	if err := db.SeedData(ctx, DB); err != nil {
+		log.Fatal("seed failed: ", err)
+	}
+
+	report, err := (&graph.Resolver{DB: DB}).ImportTicketFile(ctx, format, file, *dryRun)
+	if err != nil {
+		log.Fatal("Error importing tickets: ", err)
+	}
+
+	for _, rejected := range report.Rejected {
+		log.Printf("Rejected row %v: %v", rejected.Row, rejected.Reason)
+	}
+	// real imports are logged by the resolver
+	if report.DryRun {
+		log.Printf("%v tickets are valid, %v rows were rejected", report.Imported, len(report.Rejected))
+	}
+}
+
 func initGraphQL() {
+	fileStorage, err := storage.New(envConf)
+	if err != nil {
+		log.Fatal("Error setting up file storage: ", err)
+	}
+
 	resolver = &graph.Resolver{
-		DB: DB,
+		DB:      DB,
+		Events:  events.NewBus(),
+		Storage: fileStorage,
 	}
 
+	limiter = middleware.NewRateLimiter(DB, envConf.RealIPHeader)
+
 	config := graph.Config{
 		Resolvers: resolver,
 		Directives: graph.DirectiveRoot{
-			HasRole:  directives.HasRole,
-			OnlySelf: directives.OnlySelf,
+			HasRole:   directives.HasRole,
+			OnlySelf:  directives.OnlySelf,
+			RateLimit: directives.RateLimit(limiter),
 		},
 	}
 
 	srv = handler.New(graph.NewExecutableSchema(config))
 	srv.AddTransport(transport.POST{})
-	srv.AddTransport(transport.Websocket{})
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: graph.MaxAttachments*graph.MaxAttachmentSize + 1<<20,
+		MaxMemory:     32 << 20,
+	})
+	// the default upgrader only accepts same origin requests, which keeps
+	// other sites from opening subscriptions with the session cookie
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc: func(ctx context.Context, _ transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+			if user, ok := ctx.Value(middleware.UserKey).(*model.User); !ok || user == nil {
+				return nil, nil, fmt.Errorf("access denied")
+			}
+			return ctx, nil, nil
+		},
+	})
 	srv.AddTransport(transport.GET{})
 	srv.Use(extension.Introspection{})
 }
 
+func initNotifications() {
+	mail, err := mailer.New(mailer.Config{
+		Host:     envConf.SMTPHost,
+		Port:     envConf.SMTPPort,
+		Username: envConf.SMTPUsername,
+		Password: envConf.SMTPPassword,
+		From:     envConf.SMTPFrom,
+		TLS:      envConf.SMTPTLS,
+	})
+	if err != nil {
+		log.Fatal("Error setting up mailer: ", err)
+	}
+
+	resolver.Mailer = mail
+
+	notifier := &notifications.Notifier{
+		DB:     DB,
+		Events: resolver.Events,
+		Mailer: mail,
+	}
+
+	chatNotifier := &chat.Notifier{
+		DB:     DB,
+		Events: resolver.Events,
+	}
+
+	go mail.Run(ctx)
+	go notifier.Run(ctx)
+	go chatNotifier.Run(ctx)
+}
+
+func initWebhooks() {
+	dispatcher := &webhooks.Dispatcher{
+		DB:     DB,
+		Events: resolver.Events,
+	}
+
+	go dispatcher.Run(ctx)
+}
+
+func initInboundMail() {
+	if envConf.InboundSMTPAddr == "" {
+		return
+	}
+
+	if _, domain, ok := strings.Cut(envConf.InboundMail, "@"); !ok || domain == "" {
+		log.Fatalf("%v has to be set to the address mails are sent to, e.g. kummerkasten@example.org", utils.EnvInboundMail)
+	}
+
+	var tlsConfig *tls.Config
+	if envConf.InboundTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(envConf.InboundTLSCert, envConf.InboundTLSKey)
+		if err != nil {
+			log.Fatal("Error loading inbound mail certificate: ", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	gateway := &inbound.Gateway{
+		Resolver:   resolver,
+		Limiter:    limiter,
+		Address:    envConf.InboundMail,
+		KeepSender: envConf.InboundKeepSender,
+	}
+
+	_, domain, _ := strings.Cut(envConf.InboundMail, "@")
+	server := &inbound.Server{
+		Addr:      envConf.InboundSMTPAddr,
+		Hostname:  domain,
+		TLSConfig: tlsConfig,
+		// room for base64 encoded attachments
+		MaxSize:   graph.MaxAttachments*graph.MaxAttachmentSize*4/3 + 1<<20,
+		Recipient: gateway.Recipient,
+		Handler:   gateway.Handle,
+	}
+
+	go func() {
+		log.Printf("accepting mails to %v on %v", envConf.InboundMail, envConf.InboundSMTPAddr)
+		if err := server.ListenAndServe(ctx); err != nil {
+			log.Printf("Inbound mail server stopped: %v", err)
+		}
+	}()
+}
+
 func initCors() {
 	var allowedOrigins = []string{envConf.PublicDomain}
 
@@ -119,6 +301,35 @@ func initCron() {
 	}); err != nil {
 		log.Printf("failed setting up cronjob: %v", err)
 	}
+	if err := cronjob.AddFunc("@daily", func() {
+		if err := maintenance.PurgeTrashedTickets(ctx, resolver); err != nil {
+			log.Printf("failed cronjob: %v", err)
+		}
+	}); err != nil {
+		log.Printf("failed setting up cronjob: %v", err)
+	}
+	if err := cronjob.AddFunc("@daily", func() {
+		if err := maintenance.ApplyRetentionPolicy(ctx, resolver); err != nil {
+			log.Printf("failed cronjob: %v", err)
+		}
+	}); err != nil {
+		log.Printf("failed setting up cronjob: %v", err)
+	}
+	if err := cronjob.AddFunc("@hourly", func() {
+		if err := maintenance.ClearWebhookDeliveries(ctx, resolver); err != nil {
+			log.Printf("failed cronjob: %v", err)
+		}
+	}); err != nil {
+		log.Printf("failed setting up cronjob: %v", err)
+	}
+	// digests are sent every morning at 7
+	if err := cronjob.AddFunc("0 0 7 * * *", func() {
+		if err := maintenance.SendDigests(ctx, resolver); err != nil {
+			log.Printf("failed cronjob: %v", err)
+		}
+	}); err != nil {
+		log.Printf("failed setting up cronjob: %v", err)
+	}
 
 	cronjob.Start()
 	defer cronjob.Stop()
@@ -156,7 +367,10 @@ func initCron() {
 func getAPIRouter() *chi.Mux {
 	api := chi.NewRouter()
 	api.Use(middleware.InjectWriter)
+	api.Use(limiter.IdentifyClient)
 	api.Use(middleware.Auth(DB))
+	api.Get("/attachments/{id}", resolver.ServeAttachment)
+	api.Get(strings.TrimPrefix(graph.ExportPath, "/api"), resolver.ServeExport)
 	api.Handle("/", srv)
 	api.Handle("/*", srv)
 	return api
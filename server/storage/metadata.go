@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// StripMetadata removes EXIF, XMP, IPTC, comments and text chunks from JPEG and PNG images,
+// which may contain the camera, location or author and could identify anonymous submitters.
+// The image data itself is copied unchanged, anything appended after the image is dropped.
+func StripMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	default:
+		return nil, fmt.Errorf("cannot strip metadata of %v", contentType)
+	}
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("not a jpeg image")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	pos := 2
+	scanned := false
+
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("invalid jpeg marker at %v", pos)
+		}
+
+		// markers may be padded with any number of 0xFF bytes
+		for pos+1 < len(data) && data[pos+1] == 0xFF {
+			pos++
+		}
+		if pos+1 >= len(data) {
+			return nil, fmt.Errorf("truncated jpeg image")
+		}
+
+		marker := data[pos+1]
+
+		// anything after the end of image, like the preview images of MPF with their own EXIF, is dropped
+		if marker == 0xD9 {
+			if !scanned {
+				return nil, fmt.Errorf("jpeg image without image data")
+			}
+			out.Write(data[pos : pos+2])
+			return out.Bytes(), nil
+		}
+
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[pos : pos+2])
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("truncated jpeg image")
+		}
+		// the length includes its own two bytes
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 {
+			return nil, fmt.Errorf("invalid jpeg segment at %v", pos)
+		}
+		end := pos + 2 + length
+		if end > len(data) {
+			return nil, fmt.Errorf("truncated jpeg image")
+		}
+
+		segment := data[pos:end]
+		if keepJPEGSegment(marker, segment[4:]) {
+			out.Write(segment)
+		}
+		pos = end
+
+		// the entropy coded image data follows the start of scan, it is copied as it is up to the next
+		// marker. Progressive images have several scans with other segments in between.
+		if marker == 0xDA {
+			scanned = true
+			start := pos
+			pos = nextJPEGMarker(data, pos)
+			out.Write(data[start:pos])
+		}
+	}
+
+	if !scanned {
+		return nil, fmt.Errorf("jpeg image without image data")
+	}
+	// images without end of image marker are cut off, but viewers still show them
+	return out.Bytes(), nil
+}
+
+// nextJPEGMarker returns the position of the marker ending the entropy coded data starting at pos,
+// or the end of the data. 0xFF is followed by 0x00 for a literal 0xFF and by restart markers inside it.
+func nextJPEGMarker(data []byte, pos int) int {
+	for pos+1 < len(data) {
+		if data[pos] == 0xFF && data[pos+1] != 0x00 && (data[pos+1] < 0xD0 || data[pos+1] > 0xD7) {
+			return pos
+		}
+		if data[pos] == 0xFF {
+			pos += 2
+		} else {
+			pos++
+		}
+	}
+	return len(data)
+}
+
+// keepJPEGSegment drops comments and all application segments except the
+// ones needed to display the image correctly
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xFE:
+		return false
+	case marker == 0xE0:
+		return bytes.HasPrefix(payload, []byte("JFIF\x00"))
+	case marker == 0xE2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker == 0xEE:
+		return bytes.HasPrefix(payload, []byte("Adobe"))
+	case marker >= 0xE1 && marker <= 0xEF:
+		return false
+	default:
+		return true
+	}
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// chunks which hold metadata instead of image data
+var pngMetadataChunks = map[string]struct{}{
+	"eXIf": {},
+	"tEXt": {},
+	"zTXt": {},
+	"iTXt": {},
+	"tIME": {},
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("not a png image")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	pos := len(pngSignature)
+
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+
+		// length, type, data and crc
+		if length < 0 || length > len(data)-pos-12 {
+			return nil, fmt.Errorf("truncated png image")
+		}
+		end := pos + 12 + length
+
+		if _, metadata := pngMetadataChunks[chunkType]; !metadata {
+			out.Write(data[pos:end])
+		}
+		pos = end
+
+		if chunkType == "IEND" {
+			return out.Bytes(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("png image without end")
+}
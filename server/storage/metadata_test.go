@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStripMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		want        []byte
+		wantErr     bool
+	}{
+		{
+			name:        "jpeg without metadata",
+			contentType: "image/jpeg",
+			data:        []byte("\xFF\xD8\xFF\xDB\x00\x03\x01\xFF\xDA\x00\x02\x12\x34\xFF\xD9"),
+			want:        []byte("\xFF\xD8\xFF\xDB\x00\x03\x01\xFF\xDA\x00\x02\x12\x34\xFF\xD9"),
+		},
+		{
+			name:        "jpeg with exif and comment",
+			contentType: "image/jpeg",
+			data:        []byte("\xFF\xD8\xFF\xE1\x00\x08Exif\x00\x00\xFF\xFE\x00\x04hi\xFF\xDA\x00\x02\xFF\xD9"),
+			want:        []byte("\xFF\xD8\xFF\xDA\x00\x02\xFF\xD9"),
+		},
+		{
+			name:        "jpeg with an appended image",
+			contentType: "image/jpeg",
+			data:        []byte("\xFF\xD8\xFF\xDA\x00\x02\x12\xFF\xD9\xFF\xD8\xFF\xE1\x00\x08Exif\x00\x00\xFF\xDA\x00\x02\x34\xFF\xD9"),
+			want:        []byte("\xFF\xD8\xFF\xDA\x00\x02\x12\xFF\xD9"),
+		},
+		{
+			name:        "jpeg with stuffed bytes and restart markers",
+			contentType: "image/jpeg",
+			data:        []byte("\xFF\xD8\xFF\xDA\x00\x02\x12\xFF\x00\x34\xFF\xD0\x56\xFF\xD9"),
+			want:        []byte("\xFF\xD8\xFF\xDA\x00\x02\x12\xFF\x00\x34\xFF\xD0\x56\xFF\xD9"),
+		},
+		{
+			name:        "progressive jpeg with metadata between scans",
+			contentType: "image/jpeg",
+			data:        []byte("\xFF\xD8\xFF\xDA\x00\x02\x12\xFF\xC4\x00\x03\x01\xFF\xE1\x00\x08Exif\x00\x00\xFF\xDA\x00\x02\x34\xFF\xD9"),
+			want:        []byte("\xFF\xD8\xFF\xDA\x00\x02\x12\xFF\xC4\x00\x03\x01\xFF\xDA\x00\x02\x34\xFF\xD9"),
+		},
+		{
+			name:        "jpeg without end of image",
+			contentType: "image/jpeg",
+			data:        []byte("\xFF\xD8\xFF\xFE\x00\x04hi\xFF\xDA\x00\x02\x12\x34"),
+			want:        []byte("\xFF\xD8\xFF\xDA\x00\x02\x12\x34"),
+		},
+		{name: "jpeg end of image before any scan", contentType: "image/jpeg", data: []byte("\xFF\xD8\xFF\xD9"), wantErr: true},
+		{name: "jpeg segment length 0", contentType: "image/jpeg", data: []byte("\xFF\xD8\xFF\xE1\x00\x00\x00"), wantErr: true},
+		{name: "jpeg segment length 1", contentType: "image/jpeg", data: []byte("\xFF\xD8\xFF\xE1\x00\x01\x00"), wantErr: true},
+		{name: "jpeg segment longer than image", contentType: "image/jpeg", data: []byte("\xFF\xD8\xFF\xE1\xFF\xFF\x00"), wantErr: true},
+		{name: "jpeg truncated length", contentType: "image/jpeg", data: []byte("\xFF\xD8\xFF\xE1\x00"), wantErr: true},
+		{name: "jpeg only padding", contentType: "image/jpeg", data: []byte("\xFF\xD8\xFF\xFF\xFF"), wantErr: true},
+		{name: "jpeg invalid marker", contentType: "image/jpeg", data: []byte("\xFF\xD8\x00\x00"), wantErr: true},
+		{name: "jpeg without image data", contentType: "image/jpeg", data: []byte("\xFF\xD8\xFF\xD0"), wantErr: true},
+		{name: "not a jpeg", contentType: "image/jpeg", data: []byte("GIF89a"), wantErr: true},
+		{
+			name:        "png with text chunk",
+			contentType: "image/png",
+			data:        []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x01tEXtx\x00\x00\x00\x00\x00\x00\x00\x00IEND\x00\x00\x00\x00"),
+			want:        []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x00IEND\x00\x00\x00\x00"),
+		},
+		{name: "png chunk longer than image", contentType: "image/png", data: []byte("\x89PNG\r\n\x1a\n\xFF\xFF\xFF\xFFIDAT\x00"), wantErr: true},
+		{name: "png truncated chunk", contentType: "image/png", data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x01IDAT"), wantErr: true},
+		{name: "png without end", contentType: "image/png", data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x00IDAT\x00\x00\x00\x00"), wantErr: true},
+		{name: "not a png", contentType: "image/png", data: []byte("\x89PNG"), wantErr: true},
+		{name: "unsupported type", contentType: "image/gif", data: []byte("GIF89a"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripMetadata(tt.contentType, tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
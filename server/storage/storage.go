@@ -0,0 +1,40 @@
+// Package storage keeps the files attached to tickets
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+// Storage is a flat key value store for files, keys are generated by the server
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns ErrNotFound if there is no file with the key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete does not fail if there is no file with the key
+	Delete(ctx context.Context, key string) error
+}
+
+// New sets up the backend selected in the environment
+func New(config *utils.Config) (Storage, error) {
+	switch config.StorageBackend {
+	case "", "local":
+		return NewLocal(config.StoragePath)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  config.S3Endpoint,
+			Region:    config.S3Region,
+			Bucket:    config.S3Bucket,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.StorageBackend)
+	}
+}
@@ -3,55 +3,137 @@ package utils
 import (
 	"log"
 	"os"
+	"strings"
+	"testing"
 )
 
 const (
-	EnvPostgresUser     = "POSTGRES_USER"
-	EnvPostgresPassword = "POSTGRES_PASSWORD"
-	EnvPostgresDB       = "POSTGRES_DB"
-	EnvPostgresPort     = "POSTGRES_PORT"
-	EnvPostgresHost     = "POSTGRES_HOST"
-	EnvAdminMail        = "ADMIN_MAIL"
-	EnvAdminPassword    = "ADMIN_PASSWORD"
-	EnvPepper           = "PEPPER"
-	EnvPublicDomain     = "PUBLIC_DOMAIN"
-	EnvEnv              = "ENV"
+	EnvPostgresUser      = "POSTGRES_USER"
+	EnvPostgresPassword  = "POSTGRES_PASSWORD"
+	EnvPostgresDB        = "POSTGRES_DB"
+	EnvPostgresPort      = "POSTGRES_PORT"
+	EnvPostgresHost      = "POSTGRES_HOST"
+	EnvAdminMail         = "ADMIN_MAIL"
+	EnvAdminPassword     = "ADMIN_PASSWORD"
+	EnvPepper            = "PEPPER"
+	EnvPublicDomain      = "PUBLIC_DOMAIN"
+	EnvEnv               = "ENV"
+	EnvRealIPHeader      = "REAL_IP_HEADER"
+	EnvStorageBackend    = "STORAGE_BACKEND"
+	EnvStoragePath       = "STORAGE_PATH"
+	EnvS3Endpoint        = "S3_ENDPOINT"
+	EnvS3Region          = "S3_REGION"
+	EnvS3Bucket          = "S3_BUCKET"
+	EnvS3AccessKey       = "S3_ACCESS_KEY"
+	EnvS3SecretKey       = "S3_SECRET_KEY"
+	EnvQueryLog          = "QUERY_LOG"
+	EnvSlowQuery         = "SLOW_QUERY_THRESHOLD"
+	EnvEncryptionKey     = "ENCRYPTION_KEY"
+	EnvOldEncryptionKeys = "OLD_ENCRYPTION_KEYS"
+	EnvSMTPHost          = "SMTP_HOST"
+	EnvSMTPPort          = "SMTP_PORT"
+	EnvSMTPUsername      = "SMTP_USERNAME"
+	EnvSMTPPassword      = "SMTP_PASSWORD"
+	EnvSMTPFrom          = "SMTP_FROM"
+	EnvSMTPTLS           = "SMTP_TLS"
+	EnvInboundSMTPAddr   = "INBOUND_SMTP_ADDR"
+	EnvInboundMail       = "INBOUND_MAIL_ADDRESS"
+	EnvInboundTLSCert    = "INBOUND_SMTP_TLS_CERT"
+	EnvInboundTLSKey     = "INBOUND_SMTP_TLS_KEY"
+	EnvInboundKeepSender = "INBOUND_MAIL_KEEP_SENDER"
 )
 
 type Config struct {
-	PostgresUser     string
-	PostgresPassword string
-	PostgresDB       string
-	PostgresPort     string
-	PostgresHost     string
-	AdminMail        string
-	AdminPassword    string
-	Pepper           string
-	PublicDomain     string
-	Env              string
+	PostgresUser      string
+	PostgresPassword  string
+	PostgresDB        string
+	PostgresPort      string
+	PostgresHost      string
+	AdminMail         string
+	AdminPassword     string
+	Pepper            string
+	PublicDomain      string
+	Env               string
+	RealIPHeader      string
+	StorageBackend    string
+	StoragePath       string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKey       string
+	S3SecretKey       string
+	QueryLog          string
+	SlowQuery         string
+	EncryptionKey     string
+	OldEncryptionKeys string
+	SMTPHost          string
+	SMTPPort          string
+	SMTPUsername      string
+	SMTPPassword      string
+	SMTPFrom          string
+	SMTPTLS           string
+	InboundSMTPAddr   string
+	InboundMail       string
+	InboundTLSCert    string
+	InboundTLSKey     string
+	InboundKeepSender bool
 }
 
 func loadEnvConfig() *Config {
 	cfg := &Config{
-		PostgresUser:     mustGet(EnvPostgresUser),
-		PostgresPassword: mustGet(EnvPostgresPassword),
-		PostgresDB:       mustGet(EnvPostgresDB),
-		PostgresPort:     mustGet(EnvPostgresPort),
-		PostgresHost:     mustGet(EnvPostgresHost),
-		AdminMail:        os.Getenv(EnvAdminMail),
-		AdminPassword:    os.Getenv(EnvAdminPassword),
-		Pepper:           os.Getenv(EnvPepper),
-		PublicDomain:     mustGet(EnvPublicDomain),
-		Env:              mustGet(EnvEnv),
+		PostgresUser:      mustGet(EnvPostgresUser),
+		PostgresPassword:  mustGet(EnvPostgresPassword),
+		PostgresDB:        mustGet(EnvPostgresDB),
+		PostgresPort:      mustGet(EnvPostgresPort),
+		PostgresHost:      mustGet(EnvPostgresHost),
+		AdminMail:         os.Getenv(EnvAdminMail),
+		AdminPassword:     os.Getenv(EnvAdminPassword),
+		Pepper:            os.Getenv(EnvPepper),
+		PublicDomain:      mustGet(EnvPublicDomain),
+		Env:               mustGet(EnvEnv),
+		RealIPHeader:      os.Getenv(EnvRealIPHeader),
+		StorageBackend:    os.Getenv(EnvStorageBackend),
+		StoragePath:       os.Getenv(EnvStoragePath),
+		S3Endpoint:        os.Getenv(EnvS3Endpoint),
+		S3Region:          os.Getenv(EnvS3Region),
+		S3Bucket:          os.Getenv(EnvS3Bucket),
+		S3AccessKey:       os.Getenv(EnvS3AccessKey),
+		S3SecretKey:       os.Getenv(EnvS3SecretKey),
+		QueryLog:          os.Getenv(EnvQueryLog),
+		SlowQuery:         os.Getenv(EnvSlowQuery),
+		EncryptionKey:     mustGet(EnvEncryptionKey),
+		OldEncryptionKeys: os.Getenv(EnvOldEncryptionKeys),
+		SMTPHost:          os.Getenv(EnvSMTPHost),
+		SMTPPort:          os.Getenv(EnvSMTPPort),
+		SMTPUsername:      os.Getenv(EnvSMTPUsername),
+		SMTPPassword:      os.Getenv(EnvSMTPPassword),
+		SMTPFrom:          os.Getenv(EnvSMTPFrom),
+		SMTPTLS:           os.Getenv(EnvSMTPTLS),
+		InboundSMTPAddr:   os.Getenv(EnvInboundSMTPAddr),
+		InboundMail:       os.Getenv(EnvInboundMail),
+		InboundTLSCert:    os.Getenv(EnvInboundTLSCert),
+		InboundTLSKey:     os.Getenv(EnvInboundTLSKey),
+		InboundKeepSender: os.Getenv(EnvInboundKeepSender) == "true",
 	}
 
 	return cfg
 }
 
+// PublicURL returns the absolute URL of the path on the public domain, https is assumed
+// unless the domain includes a scheme
+func (c *Config) PublicURL(path string) string {
+	base := c.PublicDomain
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
 func mustGet(key string) string {
 	value := os.Getenv(key)
 
-	if value == "" {
+	// tests of packages using the config run without a database or domain
+	if value == "" && !testing.Testing() {
 		log.Fatalf("entry missing but required for environment variable: %s", key)
 	}
 
@@ -0,0 +1,182 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+const (
+	MaxAttempts = 10
+
+	pollInterval   = 10 * time.Second
+	batchSize      = 20
+	requestTimeout = 10 * time.Second
+	// claimed deliveries are not picked up again before the lease expires, e.g. by a second instance.
+	// It outlasts a batch whose requests all time out, so a batch is sent before others may claim it.
+	leaseDuration = batchSize*requestTimeout + time.Minute
+
+	initialBackoff = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+
+	maxErrorLength = 500
+)
+
+var client = &http.Client{Timeout: requestTimeout}
+
+// Sign returns the value of the X-Kummerkasten-Signature header,
+// receivers compute the HMAC of "<X-Kummerkasten-Timestamp>.<body>" with the secret and compare
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before the next attempt after attempts failed ones
+func Backoff(attempts int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
+
+func (d *Dispatcher) deliver(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			deliveries, err := d.claim(ctx)
+			if err != nil {
+				log.Printf("Failed to fetch pending webhook deliveries: %v", err)
+				break
+			}
+
+			for _, delivery := range deliveries {
+				d.attempt(ctx, delivery)
+			}
+
+			if len(deliveries) < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim leases the due deliveries, so that they are not sent twice
+func (d *Dispatcher) claim(ctx context.Context) ([]*models.WebhookDelivery, error) {
+	due := d.DB.NewSelect().Model((*models.WebhookDelivery)(nil)).
+		Column("id").
+		Where("status = ?", model.WebhookDeliveryStatusPending).
+		Where("next_attempt_at <= now()").
+		Order("next_attempt_at ASC").
+		Limit(batchSize).
+		For("UPDATE SKIP LOCKED")
+
+	var deliveries []*models.WebhookDelivery
+	if _, err := d.DB.NewUpdate().Model((*models.WebhookDelivery)(nil)).
+		Set("next_attempt_at = ?", time.Now().Add(leaseDuration)).
+		Where("id IN (?)", due).
+		Returning("*").
+		Exec(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	webhook := &models.Webhook{}
+	if err := d.DB.NewSelect().Model(webhook).Where("id = ?", delivery.WebhookID).Scan(ctx); err != nil {
+		log.Printf("Failed to fetch webhook %v of delivery %v: %v", delivery.WebhookID, delivery.ID, err)
+		return
+	}
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = time.Now()
+	delivery.ResponseStatus = 0
+	delivery.Error = ""
+
+	if !webhook.Active {
+		delivery.Error = "the webhook was deactivated"
+	} else if status, err := send(ctx, webhook, delivery); err != nil {
+		delivery.ResponseStatus = status
+		delivery.Error = err.Error()
+	} else {
+		delivery.ResponseStatus = status
+	}
+
+	switch {
+	case delivery.Error == "":
+		delivery.Status = model.WebhookDeliveryStatusSucceeded
+		delivery.NextAttemptAt = time.Time{}
+	case !webhook.Active || delivery.Attempts >= MaxAttempts:
+		delivery.Status = model.WebhookDeliveryStatusFailed
+		delivery.NextAttemptAt = time.Time{}
+	default:
+		delivery.NextAttemptAt = delivery.LastAttemptAt.Add(Backoff(delivery.Attempts))
+	}
+
+	if len(delivery.Error) > maxErrorLength {
+		delivery.Error = delivery.Error[:maxErrorLength]
+	}
+
+	if _, err := d.DB.NewUpdate().Model(delivery).
+		Column("status", "attempts", "response_status", "error", "last_attempt_at", "next_attempt_at").
+		WherePK().
+		Exec(ctx); err != nil {
+		log.Printf("Failed to save webhook delivery %v: %v", delivery.ID, err)
+	}
+}
+
+// send posts the payload and returns the HTTP status, responses other than 2xx are errors
+func send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Kummerkasten-Webhook")
+	req.Header.Set("X-Kummerkasten-Event", string(delivery.Event))
+	req.Header.Set("X-Kummerkasten-Delivery", delivery.ID)
+	req.Header.Set("X-Kummerkasten-Timestamp", timestamp)
+	req.Header.Set("X-Kummerkasten-Signature", Sign(string(webhook.Secret), timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response %v", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
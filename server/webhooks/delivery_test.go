@@ -0,0 +1,106 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{"secret", "1700000000", `{"event":"TICKET_CREATED"}`, "sha256=3a74989f79f6c1eea7ccf0ce8a9a1860fc73113cfc1d68486d43a3f68c7a05ad"},
+		{"", "0", "", "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+		// the timestamp is separated by the first dot only, dots in the body are part of it
+		{"k", "1", "a.b", "sha256=3a291a6ef707d00430135e613ee22385a140c627f418cc26d716d44b467630b0"},
+	}
+
+	for _, tt := range tests {
+		if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %q, %q) = %v, want %v", tt.secret, tt.timestamp, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{9, 128 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%v) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestLeaseOutlastsBatch(t *testing.T) {
+	if leaseDuration <= batchSize*requestTimeout {
+		t.Errorf("a lease of %v expires before a batch of %v requests timing out after %v", leaseDuration, batchSize, requestTimeout)
+	}
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	body := `{"event":"TICKET_CREATED"}`
+
+	var got *http.Request
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook := &models.Webhook{URL: server.URL, Secret: "secret"}
+	delivery := &models.WebhookDelivery{ID: "delivery", Event: model.WebhookEventTicketCreated, Payload: []byte(body)}
+
+	status, err := send(context.Background(), webhook, delivery)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("send() = %v, %v", status, err)
+	}
+
+	if string(gotBody) != body {
+		t.Errorf("body %q, want %q", gotBody, body)
+	}
+	timestamp := got.Header.Get("X-Kummerkasten-Timestamp")
+	if want := Sign("secret", timestamp, []byte(body)); got.Header.Get("X-Kummerkasten-Signature") != want {
+		t.Errorf("signature %q, want %q", got.Header.Get("X-Kummerkasten-Signature"), want)
+	}
+	if got.Header.Get("X-Kummerkasten-Event") != string(model.WebhookEventTicketCreated) || got.Header.Get("X-Kummerkasten-Delivery") != "delivery" {
+		t.Errorf("unexpected headers %v", got.Header)
+	}
+}
+
+func TestSendRejectsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	status, err := send(context.Background(), &models.Webhook{URL: server.URL}, &models.WebhookDelivery{Payload: []byte("{}")})
+	if err == nil || status != http.StatusServiceUnavailable {
+		t.Errorf("send() = %v, %v, want the status and an error", status, err)
+	}
+}
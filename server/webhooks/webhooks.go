@@ -0,0 +1,150 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/FachschaftMathPhysInfo/kummerkasten/events"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/graph/model"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/models"
+	"github.com/FachschaftMathPhysInfo/kummerkasten/utils"
+	"github.com/uptrace/bun"
+)
+
+// Payload is the JSON body posted to the webhooks, it only contains what is needed
+// to look the ticket up, see events.Ticket
+type Payload struct {
+	Event      model.WebhookEvent `json:"event"`
+	OccurredAt time.Time          `json:"occurredAt"`
+	Ticket     PayloadTicket      `json:"ticket"`
+	OldState   model.TicketState  `json:"oldState,omitempty"`
+	NewState   model.TicketState  `json:"newState,omitempty"`
+	// AddedLabels are the names of the labels added on TICKET_LABELLED
+	AddedLabels []string `json:"addedLabels,omitempty"`
+}
+
+type PayloadTicket struct {
+	ID        string            `json:"id"`
+	Title     string            `json:"title"`
+	State     model.TicketState `json:"state"`
+	Labels    []string          `json:"labels"`
+	CreatedAt time.Time         `json:"createdAt"`
+	URL       string            `json:"url"`
+}
+
+// Dispatcher queues a delivery for every active webhook subscribed to a ticket event
+// and sends the queued deliveries, so they survive restarts and are retried with backoff
+type Dispatcher struct {
+	DB     *bun.DB
+	Events *events.Bus
+}
+
+// Run queues and sends deliveries until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	go d.deliver(ctx)
+
+	// the deliveries are only persisted once they are queued, so no event may be dropped
+	for event := range d.Events.SubscribeBlocking(ctx) {
+		if err := d.enqueue(ctx, event); err != nil {
+			log.Printf("Failed to queue webhook deliveries for ticket %v: %v", event.TicketID, err)
+		}
+	}
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, event events.TicketEvent) error {
+	var webhooks []*models.Webhook
+	if err := d.DB.NewSelect().Model(&webhooks).
+		Where("active").
+		Where("? = ANY(events)", string(event.Type)).
+		Scan(ctx); err != nil {
+		return err
+	}
+
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	ticket, err := events.Ticket(ctx, d.DB, event.TicketID, false)
+	if err != nil || ticket == nil {
+		return err
+	}
+
+	payload := Payload{
+		Event:      model.WebhookEvent(event.Type),
+		OccurredAt: time.Now(),
+		Ticket: PayloadTicket{
+			ID:        ticket.ID,
+			Title:     ticket.Title,
+			State:     ticket.State,
+			Labels:    []string{},
+			CreatedAt: ticket.CreatedAt,
+			URL:       utils.EnvConfig.PublicURL("/tickets/" + ticket.ID),
+		},
+		OldState: event.OldState,
+		NewState: event.NewState,
+	}
+
+	added := make(map[string]struct{})
+	for _, id := range event.LabelIDs {
+		added[id] = struct{}{}
+	}
+	for _, l := range ticket.Labels {
+		payload.Ticket.Labels = append(payload.Ticket.Labels, l.Name)
+		if _, ok := added[l.ID]; ok {
+			payload.AddedLabels = append(payload.AddedLabels, l.Name)
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]*models.WebhookDelivery, 0, len(webhooks))
+	for _, w := range webhooks {
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			WebhookID:     w.ID,
+			Event:         payload.Event,
+			TicketID:      ticket.ID,
+			Payload:       body,
+			Status:        model.WebhookDeliveryStatusPending,
+			NextAttemptAt: payload.OccurredAt,
+		})
+	}
+
+	if _, err := d.DB.NewInsert().Model(&deliveries).Exec(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateURL only accepts absolute http and https URLs
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("the url must start with http:// or https://")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("the url must contain a host")
+	}
+	return nil
+}
+
+// NewSecret returns a random key for signing the payloads of a webhook
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}